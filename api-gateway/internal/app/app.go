@@ -3,111 +3,233 @@ package app
 import (
 	"api-gateway/internal/config"
 	"api-gateway/internal/lib/auth"
-	geturl "api-gateway/internal/lib/handlers/getUrl"
+	"api-gateway/internal/lib/cache"
+	"api-gateway/internal/lib/handlers/response"
+	"api-gateway/internal/lib/policy"
+	"api-gateway/internal/lib/ratelimit"
+	"api-gateway/internal/lib/tracing"
+	"api-gateway/internal/routing"
 	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"net/http/httputil"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type App struct {
-	httpServer *http.Server
-	log        *slog.Logger
-	routes     map[string]string
+	httpServer     *http.Server
+	log            *slog.Logger
+	routes         *routing.Store
+	reloadInterval time.Duration
+	healthClient   *http.Client
+	limiter        *ratelimit.Memory
+	invalidation   *kafka.Reader
+	responseCache  *cache.LRU
+
+	shutdownTracing func(context.Context) error
+	stopWatch       context.CancelFunc
 }
 
 func NewApp(log *slog.Logger, cfg *config.Config) *App {
-	routes := cfg.GetRoutes()
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Error("Failed to set up tracing", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	routes, err := routing.NewStore(log, cfg.Routes.File)
+	if err != nil {
+		log.Error("Failed to load routes", slog.String("file", cfg.Routes.File), slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	verifier := newVerifier(log, cfg)
+
+	identitySecret := []byte(cfg.IdentitySecret)
+	if len(identitySecret) == 0 {
+		log.Warn("SECRET is not set, services will reject user headers")
+	}
+
+	limiter := ratelimit.NewMemory()
+
+	var responseCache *cache.LRU
+	if cfg.Cache.MaxBytes > 0 {
+		responseCache = cache.NewLRU(cfg.Cache.MaxBytes)
+	}
+
+	transport := newTransport()
+	// Каждая попытка запроса к сервису — дочерний спан с traceparent
+	proxy := newReverseProxy(log, otelhttp.NewTransport(transport), identitySecret)
 
 	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 
+	router.Get("/gateway/upstreams", adminOnly(log, verifier, UpstreamsStatus(log, routes)))
+
 	router.HandleFunc("/proxy/*", func(w http.ResponseWriter, r *http.Request) {
 		requestPath := r.URL.Path
 		targetPath := strings.TrimPrefix(requestPath, "/proxy")
 
 		r.URL.Path = targetPath
 
-		serviceURL, matchedPrefix, err := geturl.GetServiceURL(r, routes)
-		if err != nil {
-			log.Error("Servce not found",
-				slog.String("path", targetPath),
-				slog.Any("error", err))
-			http.Error(w, "Servce not found", http.StatusBadGateway)
+		// По X-Request-Id клиент и сервисы находят запрос в логах
+		w.Header().Set(middleware.RequestIDHeader, middleware.GetReqID(r.Context()))
+
+		// Снимок таблицы берётся один раз: перезагрузка не влияет на запрос
+		route, ok := routes.Table().Match(targetPath)
+		if !ok {
+			log.Error("Route not found",
+				slog.String("path", targetPath))
+			response.RespondWithError(w, log, http.StatusNotFound, "route not found")
 			return
 		}
 
-		reverseProxy := httputil.NewSingleHostReverseProxy(serviceURL)
-
-		reverseProxy.Director = func(req *http.Request) {
-			req.URL.Scheme = serviceURL.Scheme
-			req.URL.Host = serviceURL.Host
-			req.URL.Path = targetPath
-			req.URL.RawQuery = r.URL.RawQuery
-			req.Header = r.Header.Clone()
-
-			if strings.HasPrefix(matchedPrefix, "/product") ||
-				strings.HasPrefix(matchedPrefix, "/products") ||
-				strings.HasPrefix(matchedPrefix, "/search") ||
-				strings.HasPrefix(matchedPrefix, "/comment") ||
-				strings.HasPrefix(matchedPrefix, "/comments") {
-
-				authHeader := r.Header.Get("Authorization")
-				if authHeader != "" {
-					tokenInfo, verifyErr := auth.VerifyToken(routes["/auth"], authHeader)
-					if verifyErr == nil && tokenInfo != nil {
-						log.Info("Adding user info to request",
-							slog.String("user_id", tokenInfo.UserID),
-							slog.String("username", tokenInfo.Username))
-						req.Header.Set("X-User-ID", tokenInfo.UserID)
-						req.Header.Set("X-Username", tokenInfo.Username)
-					} else {
-						log.Warn("Failed to verify token",
-							slog.String("error", verifyErr.Error()))
-					}
+		rule := route.Rule(r.Method)
+
+		var tokenInfo *auth.TokenInfo
+		if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+			info, verifyErr := verifier.VerifyToken(r.Context(), authHeader)
+			if verifyErr != nil {
+				log.Warn("Failed to verify token",
+					slog.String("error", verifyErr.Error()))
+
+				// Публичный маршрут доступен и с недействительным токеном,
+				// запрос уходит как анонимный
+				if rule.Access != policy.Public {
+					response.RespondWithError(w, log, http.StatusUnauthorized, verifyErr.Error())
+					return
 				}
+			} else {
+				tokenInfo = info
 			}
+		}
 
-			log.Info("request to service",
-				slog.String("host", serviceURL.Host),
-				slog.String("path", targetPath))
+		if !allowRequest(w, r, log, limiter, route, tokenInfo) {
+			return
+		}
+
+		if err := rule.Authorize(tokenInfo); err != nil {
+			log.Info("Request rejected by policy",
+				slog.String("method", r.Method),
+				slog.String("path", targetPath),
+				slog.String("error", err.Error()))
+			response.RespondWithError(w, log, policy.Status(err), err.Error())
+			return
+		}
+
+		fetch := func(w http.ResponseWriter) {
+			forward(w, r, log, proxy, route, route.TargetPath(targetPath), tokenInfo)
 		}
 
-		reverseProxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-			log.Error("error ", slog.Any("error", err))
-			http.Error(w, fmt.Sprintf("error: %v", err), http.StatusBadGateway)
+		if responseCache != nil && route.Cache != nil && r.Method == http.MethodGet {
+			serveCached(w, r, responseCache, cfg.Cache.MaxEntryBytes, route.Cache, fetch)
+			return
 		}
 
-		reverseProxy.ServeHTTP(w, r)
+		fetch(w)
 	})
 
 	srv := &http.Server{
 		Addr:         fmt.Sprintf("0.0.0.0:%s", cfg.ProxyPort),
-		Handler:      router,
+		Handler:      otelhttp.NewHandler(router, "api-gateway", otelhttp.WithSpanNameFormatter(spanName)),
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  15 * time.Second,
 	}
 
 	return &App{
-		httpServer: srv,
-		log:        log,
-		routes:     routes,
+		httpServer:     srv,
+		log:            log,
+		routes:         routes,
+		reloadInterval: cfg.Routes.ReloadInterval,
+		healthClient:   &http.Client{Transport: transport},
+		limiter:        limiter,
+		invalidation:   newInvalidationReader(log, cfg, responseCache),
+		responseCache:  responseCache,
+
+		shutdownTracing: shutdownTracing,
+	}
+}
+
+func spanName(_ string, r *http.Request) string {
+	return r.Method + " " + r.URL.Path
+}
+
+// newInvalidationReader читает топики, по которым сбрасывается кэш; nil,
+// если кэш выключен или Kafka не настроена
+func newInvalidationReader(log *slog.Logger, cfg *config.Config, responseCache *cache.LRU) *kafka.Reader {
+	if responseCache == nil {
+		return nil
+	}
+	if cfg.Cache.KafkaBrokers == "" {
+		log.Warn("KAFKA_BOOTSTRAP_SERVERS is not set, cached responses expire only by ttl")
+		return nil
+	}
+
+	group := cfg.Cache.InvalidationGroup
+	if group == "" {
+		hostname, _ := os.Hostname()
+		group = "api-gateway-cache-" + hostname
+	}
+
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     strings.Split(cfg.Cache.KafkaBrokers, ","),
+		GroupID:     group,
+		GroupTopics: strings.Split(cfg.Cache.InvalidationTopics, ","),
+		// Старые события не нужны: кэш после старта пуст
+		StartOffset: kafka.LastOffset,
+	})
+}
+
+func newVerifier(log *slog.Logger, cfg *config.Config) *auth.Verifier {
+	authCfg := auth.Config{
+		Secret:   []byte(cfg.Auth.JWTSecret),
+		Issuer:   cfg.Auth.JWTIssuer,
+		Audience: cfg.Auth.JWTAudience,
+		Leeway:   cfg.Auth.JWTLeeway,
 	}
+	if cfg.Auth.JWKSURL != "" {
+		authCfg.JWKS = auth.NewJWKS(cfg.Auth.JWKSURL, cfg.Auth.JWKSTTL)
+	}
+	if len(authCfg.Secret) == 0 && authCfg.JWKS == nil {
+		log.Warn("Neither JWT_SECRET_KEY nor JWKS_URL is set, all tokens will be rejected")
+	}
+
+	return auth.NewVerifier(authCfg)
 }
 
 func (a *App) Run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.stopWatch = cancel
+	go a.routes.Watch(ctx, a.reloadInterval)
+	go a.routes.CheckHealth(ctx, a.healthClient)
+	go a.limiter.Run(ctx, time.Minute)
+	if a.invalidation != nil {
+		go invalidateCache(ctx, a.log, a.invalidation, a.responseCache)
+	}
+
 	a.log.Info("Start api-gateway", slog.String("address", a.httpServer.Addr))
 	return a.httpServer.ListenAndServe()
 }
 
 func (a *App) Shutdown(ctx context.Context) error {
-	return a.httpServer.Shutdown(ctx)
+	if a.stopWatch != nil {
+		a.stopWatch()
+	}
+	err := a.httpServer.Shutdown(ctx)
+
+	if tracingErr := a.shutdownTracing(ctx); tracingErr != nil {
+		a.log.Error("Failed to flush traces", slog.Any("error", tracingErr))
+	}
+
+	return err
 }
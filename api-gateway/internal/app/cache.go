@@ -0,0 +1,157 @@
+package app
+
+import (
+	"api-gateway/internal/lib/cache"
+	"api-gateway/internal/routing"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// serveCached отдаёт GET-ответ маршрута из кэша или получает его через
+// fetch и сохраняет. Ответ, не поместившийся в maxEntry, отдаётся
+// потоком и не кэшируется.
+func serveCached(w http.ResponseWriter, r *http.Request, lru *cache.LRU, maxEntry int, policy *routing.CachePolicy, fetch func(http.ResponseWriter)) {
+	// Сжатый и несжатый ответы — разные записи
+	key := cache.Key(r, append([]string{"Accept-Encoding"}, policy.Vary...))
+	now := time.Now()
+
+	if !cache.Bypass(r) {
+		if entry, ok := lru.Get(key, now); ok {
+			writeEntry(w, r, entry, now, "HIT")
+			return
+		}
+	}
+
+	generation := lru.Generation()
+	// Заголовки, выставленные шлюзом до запроса (X-RateLimit-*), относятся
+	// к этому клиенту и в запись не попадают
+	own := w.Header().Clone()
+
+	bw := &bufferedWriter{ResponseWriter: w, limit: maxEntry}
+	fetch(bw)
+
+	if bw.streaming {
+		return
+	}
+
+	status := bw.statusCode()
+	if !cache.Storable(status, w.Header()) {
+		bw.flush()
+		return
+	}
+
+	header := make(http.Header)
+	for k, vs := range w.Header() {
+		if _, ok := own[k]; !ok {
+			header[k] = vs
+		}
+	}
+	// Date выставит сервер шлюза при каждой отдаче
+	header.Del("Date")
+
+	entry := &cache.Entry{
+		Status:   status,
+		Header:   header,
+		Body:     bw.buf.Bytes(),
+		ETag:     header.Get("ETag"),
+		StoredAt: now,
+		Expires:  now.Add(policy.TTL),
+		Tags:     policy.InvalidateOn,
+	}
+	if entry.ETag == "" {
+		entry.ETag = cache.ETag(entry.Body)
+	}
+
+	lru.Set(key, entry, generation)
+	writeEntry(w, r, entry, now, "MISS")
+}
+
+// writeEntry отдаёт запись; при совпадении If-None-Match — 304 без тела
+func writeEntry(w http.ResponseWriter, r *http.Request, entry *cache.Entry, now time.Time, state string) {
+	h := w.Header()
+	for k, vs := range entry.Header {
+		h[k] = vs
+	}
+	h.Set("ETag", entry.ETag)
+	h.Set("X-Cache", state)
+	h.Set("Age", strconv.Itoa(int(now.Sub(entry.StoredAt).Seconds())))
+	if h.Get("Cache-Control") == "" {
+		h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(entry.Expires.Sub(now).Seconds())))
+	}
+
+	if cache.NotModified(r, entry.ETag) {
+		h.Del("Content-Length")
+		h.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.Set("Content-Length", strconv.Itoa(len(entry.Body)))
+	w.WriteHeader(entry.Status)
+	//nolint:errcheck
+	w.Write(entry.Body)
+}
+
+// bufferedWriter придерживает ответ, пока он помещается в limit
+type bufferedWriter struct {
+	http.ResponseWriter
+	limit     int
+	status    int
+	buf       bytes.Buffer
+	streaming bool
+}
+
+func (b *bufferedWriter) WriteHeader(code int) {
+	if b.status == 0 {
+		b.status = code
+	}
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	if !b.streaming && b.buf.Len()+len(p) > b.limit {
+		b.flush()
+		b.streaming = true
+	}
+	if b.streaming {
+		return b.ResponseWriter.Write(p)
+	}
+	return b.buf.Write(p)
+}
+
+// Flush нужен прокси для потоковых ответов; пока ответ придерживается,
+// отправлять нечего
+func (b *bufferedWriter) Flush() {
+	if !b.streaming {
+		return
+	}
+	if f, ok := b.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (b *bufferedWriter) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
+
+func (b *bufferedWriter) statusCode() int {
+	if b.status == 0 {
+		return http.StatusOK
+	}
+	return b.status
+}
+
+// flush отправляет придержанный ответ как есть
+func (b *bufferedWriter) flush() {
+	b.ResponseWriter.WriteHeader(b.statusCode())
+	if b.buf.Len() > 0 {
+		//nolint:errcheck
+		b.ResponseWriter.Write(b.buf.Bytes())
+	}
+	b.buf.Reset()
+}
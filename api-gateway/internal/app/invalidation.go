@@ -0,0 +1,41 @@
+package app
+
+import (
+	"api-gateway/internal/lib/cache"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// invalidateCache сбрасывает записи кэша по событиям из Kafka: запись
+// с тегом-топиком удаляется при любом сообщении в этот топик. У каждого
+// экземпляра шлюза своя группа, чтобы все они получали все события.
+func invalidateCache(ctx context.Context, log *slog.Logger, reader *kafka.Reader, lru *cache.LRU) {
+	defer reader.Close()
+
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+				return
+			}
+			log.Error("Failed to read invalidation event", slog.Any("error", err))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		removed := lru.InvalidateTag(msg.Topic)
+		log.Debug("Cache invalidated",
+			slog.String("topic", msg.Topic),
+			slog.String("key", string(msg.Key)),
+			slog.Int("entries", removed))
+	}
+}
@@ -0,0 +1,281 @@
+package app
+
+import (
+	"api-gateway/internal/lib/auth"
+	"api-gateway/internal/lib/handlers/response"
+	"api-gateway/internal/lib/identity"
+	"api-gateway/internal/routing"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// maxRetryBody — тело запроса больше этого размера не буферизуется,
+// и запрос уходит без повторов
+const maxRetryBody = 1 << 20
+
+// errRetryableStatus — сервис ответил 502/503/504, ответ отброшен ради
+// повтора
+var errRetryableStatus = errors.New("retryable upstream status")
+
+// proxyTarget — одна попытка запроса: куда и от чьего имени он уходит.
+// Обработчик кладёт её в контекст, общий прокси читает и записывает итог.
+type proxyTarget struct {
+	upstream  *routing.Upstream
+	path      string
+	tokenInfo *auth.TokenInfo
+	// final — последняя попытка: ответ сервиса отдаётся клиенту как есть
+	final bool
+
+	status int
+	err    error
+}
+
+type proxyTargetKey struct{}
+
+func withProxyTarget(ctx context.Context, target *proxyTarget) context.Context {
+	return context.WithValue(ctx, proxyTargetKey{}, target)
+}
+
+func targetFrom(ctx context.Context) *proxyTarget {
+	return ctx.Value(proxyTargetKey{}).(*proxyTarget)
+}
+
+// newTransport — общий пул соединений ко всем сервисам. У стандартного
+// транспорта всего 2 простаивающих соединения на хост, под нагрузкой
+// шлюз открывал бы новое соединение почти на каждый запрос.
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.MaxIdleConns = 256
+	transport.MaxIdleConnsPerHost = 64
+	transport.IdleConnTimeout = 90 * time.Second
+
+	return transport
+}
+
+// newReverseProxy создаёт общий прокси. Ошибки он клиенту не пишет, а
+// сохраняет в proxyTarget: решение о повторе и ответ клиенту за forward.
+func newReverseProxy(log *slog.Logger, transport http.RoundTripper, identitySecret []byte) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Transport: transport,
+		Director: func(req *http.Request) {
+			target := targetFrom(req.Context())
+			serviceURL := target.upstream.URL
+
+			req.URL.Scheme = serviceURL.Scheme
+			req.URL.Host = serviceURL.Host
+			req.URL.Path = target.path
+			req.URL.RawPath = ""
+
+			// Заголовки пользователя выставляет только шлюз
+			identity.Strip(req.Header)
+
+			if reqID := middleware.GetReqID(req.Context()); reqID != "" {
+				req.Header.Set(middleware.RequestIDHeader, reqID)
+			}
+
+			if target.tokenInfo != nil {
+				// Подпись покрывает тело: читаем его целиком и отдаём дальше
+				body, err := readBody(req)
+				if err != nil {
+					log.Warn("Failed to read request body, user info is not added", slog.Any("error", err))
+				} else {
+					log.Info("Adding user info to request",
+						slog.String("user_id", target.tokenInfo.UserID),
+						slog.String("username", target.tokenInfo.Username))
+					identity.Set(req.Header, identitySecret, target.tokenInfo, req.Method, req.URL.Path, req.URL.RawQuery, body, time.Now())
+				}
+			}
+
+			log.Info("request to service",
+				slog.String("host", serviceURL.Host),
+				slog.String("path", target.path))
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			target := targetFrom(resp.Request.Context())
+			target.status = resp.StatusCode
+
+			if retryableStatus(resp.StatusCode) && !target.final {
+				return errRetryableStatus
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			targetFrom(r.Context()).err = err
+		},
+	}
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusBadGateway ||
+		code == http.StatusServiceUnavailable ||
+		code == http.StatusGatewayTimeout
+}
+
+// forward отправляет запрос в сервис маршрута: выбирает экземпляр,
+// учитывает автомат защиты и повторяет идемпотентные запросы с паузой
+func forward(w http.ResponseWriter, r *http.Request, log *slog.Logger, proxy *httputil.ReverseProxy, route *routing.Route, path string, tokenInfo *auth.TokenInfo) {
+	attempts := route.Retry.AttemptsFor(r.Method)
+
+	body, ok := bufferBody(r, attempts)
+	if !ok {
+		attempts = 1
+	}
+
+	ctx := r.Context()
+	if route.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, route.Timeout)
+		defer cancel()
+	}
+
+	var (
+		tried   []*routing.Upstream
+		lastErr error
+	)
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 && !sleepCtx(ctx, route.Retry.Delay(attempt-1)) {
+			lastErr = ctx.Err()
+			break
+		}
+
+		upstream, ok := route.Upstream(tried...)
+		if !ok {
+			break
+		}
+		tried = append(tried, upstream)
+
+		try, ok := upstream.Begin()
+		if !ok {
+			continue
+		}
+
+		target := &proxyTarget{
+			upstream:  upstream,
+			path:      path,
+			tokenInfo: tokenInfo,
+			final:     attempt == attempts,
+		}
+
+		tryCtx, cancelTry := ctx, context.CancelFunc(func() {})
+		if route.Retry.PerTryTimeout > 0 {
+			tryCtx, cancelTry = context.WithTimeout(ctx, route.Retry.PerTryTimeout)
+		}
+
+		req := r.WithContext(withProxyTarget(tryCtx, target))
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		proxy.ServeHTTP(w, req)
+		cancelTry()
+
+		result := routing.Success
+		switch {
+		case r.Context().Err() != nil:
+			result = routing.Aborted
+		case target.err != nil || retryableStatus(target.status):
+			result = routing.Failure
+		}
+		if state, changed := try.Finish(result); changed {
+			log.Warn("Upstream circuit state changed",
+				slog.String("upstream", upstream.URL.String()),
+				slog.String("state", string(state)))
+		}
+
+		if target.err == nil {
+			// Ответ сервиса уже отдан клиенту
+			return
+		}
+
+		log.Warn("Upstream request failed",
+			slog.String("upstream", upstream.URL.String()),
+			slog.String("path", path),
+			slog.Int("attempt", attempt),
+			slog.Any("error", target.err))
+
+		if result == routing.Aborted {
+			return
+		}
+		lastErr = target.err
+	}
+
+	respondUpstreamError(w, log, route, lastErr)
+}
+
+// respondUpstreamError отвечает клиенту без подробностей: адреса сервисов
+// и текст ошибок остаются в логах шлюза
+func respondUpstreamError(w http.ResponseWriter, log *slog.Logger, route *routing.Route, err error) {
+	switch {
+	case err == nil:
+		log.Error("No available upstream", slog.String("route", route.Prefix))
+		response.RespondWithError(w, log, http.StatusServiceUnavailable, "service unavailable")
+	case errors.Is(err, context.DeadlineExceeded):
+		response.RespondWithError(w, log, http.StatusGatewayTimeout, "upstream timeout")
+	case errors.Is(err, errRetryableStatus):
+		response.RespondWithError(w, log, http.StatusServiceUnavailable, "service unavailable")
+	default:
+		response.RespondWithError(w, log, http.StatusBadGateway, "bad gateway")
+	}
+}
+
+// bufferBody читает тело запроса, чтобы его можно было отправить повторно.
+// false означает, что повторять запрос нельзя: тело слишком большое.
+func bufferBody(r *http.Request, attempts int) ([]byte, bool) {
+	if attempts <= 1 || r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxRetryBody+1))
+	if err != nil || len(buf) > maxRetryBody {
+		// Уже прочитанное уходит вместе с остатком тела
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+		return nil, false
+	}
+
+	return buf, true
+}
+
+// readBody читает тело запроса и подменяет его прочитанной копией
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"api-gateway/internal/lib/auth"
+	"api-gateway/internal/lib/handlers/response"
+	"api-gateway/internal/lib/ratelimit"
+	"api-gateway/internal/routing"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// allowRequest берёт токен из корзины маршрута для пользователя или, для
+// анонимного запроса, для IP клиента. Отклонённый запрос получает 429.
+// Если хранилище недоступно, запрос пропускается.
+func allowRequest(w http.ResponseWriter, r *http.Request, log *slog.Logger, limiter ratelimit.Backend, route *routing.Route, tokenInfo *auth.TokenInfo) bool {
+	if route.RateLimit == nil {
+		return true
+	}
+
+	key := route.Prefix + "|" + clientKey(r, tokenInfo)
+
+	decision, err := limiter.Take(r.Context(), key, *route.RateLimit, time.Now())
+	if err != nil {
+		log.Error("Rate limiter failed, request allowed", slog.Any("error", err))
+		return true
+	}
+
+	ratelimit.SetHeaders(w.Header(), *route.RateLimit, decision)
+
+	if !decision.Allowed {
+		log.Info("Rate limit exceeded",
+			slog.String("route", route.Prefix),
+			slog.String("key", key))
+		response.RespondWithError(w, log, http.StatusTooManyRequests, "too many requests")
+		return false
+	}
+
+	return true
+}
+
+// clientKey — пользователь из проверенного токена или IP соединения.
+// X-Forwarded-For не учитывается: шлюз стоит первым, и клиент мог бы
+// подставить в заголовок любой адрес.
+func clientKey(r *http.Request, tokenInfo *auth.TokenInfo) string {
+	if tokenInfo != nil {
+		return "user:" + tokenInfo.UserID
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"api-gateway/internal/lib/auth"
+	"api-gateway/internal/lib/handlers/response"
+	"api-gateway/internal/lib/policy"
+	"api-gateway/internal/routing"
+	"log/slog"
+	"net/http"
+)
+
+// adminRule — доступ к служебным эндпоинтам шлюза: в ответах адреса
+// сервисов и тексты их ошибок
+var adminRule = policy.Rule{Access: policy.Roles, Roles: []string{"admin"}}
+
+// adminOnly пропускает к next только запросы с токеном администратора
+func adminOnly(log *slog.Logger, verifier *auth.Verifier, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var info *auth.TokenInfo
+		if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+			verified, err := verifier.VerifyToken(r.Context(), authHeader)
+			if err != nil {
+				response.RespondWithError(w, log, http.StatusUnauthorized, err.Error())
+				return
+			}
+			info = verified
+		}
+
+		if err := adminRule.Authorize(info); err != nil {
+			log.Info("Gateway endpoint rejected by policy",
+				slog.String("path", r.URL.Path),
+				slog.String("error", err.Error()))
+			response.RespondWithError(w, log, policy.Status(err), err.Error())
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+type routeStatus struct {
+	Prefix    string                   `json:"prefix"`
+	Balancing routing.Balancing        `json:"balancing"`
+	Upstreams []routing.UpstreamStatus `json:"upstreams"`
+}
+
+type upstreamsStatus struct {
+	Routes []routeStatus `json:"routes"`
+}
+
+// UpstreamsStatus показывает экземпляры сервисов по маршрутам: доступность,
+// результат последней проверки и число запросов в обработке
+func UpstreamsStatus(log *slog.Logger, store *routing.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		routes := store.Table().Routes()
+
+		status := upstreamsStatus{Routes: make([]routeStatus, 0, len(routes))}
+		for _, route := range routes {
+			rs := routeStatus{
+				Prefix:    route.Prefix,
+				Balancing: route.Balancing,
+				Upstreams: make([]routing.UpstreamStatus, 0, len(route.Upstreams)),
+			}
+			for _, u := range route.Upstreams {
+				rs.Upstreams = append(rs.Upstreams, u.Status())
+			}
+			status.Routes = append(status.Routes, rs)
+		}
+
+		response.RespondWithJSON(w, log, http.StatusOK, status)
+	}
+}
@@ -1,20 +1,50 @@
 package config
 
 import (
+	"api-gateway/internal/lib/tracing"
 	"log"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	ProxyPort     string `env:"PROXY_PORT" default:"8000"`
-	ServiceRoutes ServiceRoutesConfig
+	ProxyPort string `env:"PROXY_PORT" default:"8000"`
+	// IdentitySecret подписывает заголовки пользователя для сервисов
+	IdentitySecret string `env:"SECRET"`
+	Routes         RoutesConfig
+	Auth           AuthConfig
+	Cache          CacheConfig
+	Tracing        tracing.Config
 }
 
-type ServiceRoutesConfig struct {
-	CatalogService string `env:"CATALOG_SERVICE_URL" default:"http://backend:8080"`
-	AuthService    string `env:"AUTH_SERVICE_URL" default:"http://auth:5000"`
+// RoutesConfig — файл маршрутов; в нём подставляются переменные
+// окружения, например ${CATALOG_SERVICE_URL}
+type RoutesConfig struct {
+	File           string        `env:"ROUTES_FILE" envDefault:"routes.yaml"`
+	ReloadInterval time.Duration `env:"ROUTES_RELOAD_INTERVAL" envDefault:"5s"`
+}
+
+// CacheConfig — кэш ответов маршрутов с cache в файле маршрутов.
+// CACHE_MAX_BYTES=0 отключает кэш. Без KAFKA_BOOTSTRAP_SERVERS записи
+// живут до истечения ttl.
+type CacheConfig struct {
+	MaxBytes           int64  `env:"CACHE_MAX_BYTES" envDefault:"67108864"`
+	MaxEntryBytes      int    `env:"CACHE_MAX_ENTRY_BYTES" envDefault:"1048576"`
+	KafkaBrokers       string `env:"KAFKA_BOOTSTRAP_SERVERS"`
+	InvalidationTopics string `env:"CACHE_INVALIDATION_TOPICS" envDefault:"product-events"`
+	// InvalidationGroup по умолчанию уникальна для экземпляра шлюза
+	InvalidationGroup string `env:"CACHE_INVALIDATION_GROUP"`
+}
+
+type AuthConfig struct {
+	JWTSecret   string        `env:"JWT_SECRET_KEY"`
+	JWKSURL     string        `env:"JWKS_URL"`
+	JWKSTTL     time.Duration `env:"JWKS_CACHE_TTL" envDefault:"10m"`
+	JWTIssuer   string        `env:"JWT_ISSUER"`
+	JWTAudience string        `env:"JWT_AUDIENCE"`
+	JWTLeeway   time.Duration `env:"JWT_LEEWAY" envDefault:"30s"`
 }
 
 func Load() *Config {
@@ -33,14 +63,3 @@ func parseConfig(cfg *Config) {
 		log.Fatalf("unable to parse environment variables: %v", err)
 	}
 }
-
-func (c *Config) GetRoutes() map[string]string {
-	return map[string]string{
-		"/search":   c.ServiceRoutes.CatalogService,
-		"/products": c.ServiceRoutes.CatalogService,
-		"/product":  c.ServiceRoutes.CatalogService,
-		"/comment":  c.ServiceRoutes.CatalogService,
-		"/comments": c.ServiceRoutes.CatalogService,
-		"/auth":     c.ServiceRoutes.AuthService,
-	}
-}
@@ -1,15 +1,27 @@
 package auth
 
 import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 )
 
 var (
-	ErrInvalidToken = errors.New("недействительный токен авторизации")
+	ErrInvalidToken         = errors.New("недействительный токен авторизации")
+	ErrInvalidSignature     = errors.New("неверная подпись токена")
+	ErrUnsupportedAlgorithm = errors.New("неподдерживаемый алгоритм подписи")
+	ErrTokenExpired         = errors.New("срок действия токена истёк")
+	ErrTokenNotYetValid     = errors.New("токен ещё не действителен")
+	ErrInvalidIssuer        = errors.New("недопустимый издатель токена")
+	ErrInvalidAudience      = errors.New("недопустимая аудитория токена")
 )
 
 type TokenInfo struct {
@@ -18,44 +30,183 @@ type TokenInfo struct {
 	Role     string `json:"role"`
 }
 
-func extractTokenInfo(token string) (*TokenInfo, error) {
+type Config struct {
+	// Secret — общий ключ для HS256, пустой отключает HS256
+	Secret []byte
+	// JWKS — ключи для RS256, nil отключает RS256
+	JWKS *JWKS
+	// Issuer и Audience проверяются, только если заданы
+	Issuer   string
+	Audience string
+	// Leeway — допустимое расхождение часов при проверке exp и nbf
+	Leeway time.Duration
+}
+
+type Verifier struct {
+	cfg Config
+	now func() time.Time
+}
+
+func NewVerifier(cfg Config) *Verifier {
+	return &Verifier{cfg: cfg, now: time.Now}
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type claims struct {
+	Sub      string   `json:"sub"`
+	Role     string   `json:"role"`
+	Username string   `json:"username"`
+	Exp      *int64   `json:"exp"`
+	Nbf      *int64   `json:"nbf"`
+	Iss      string   `json:"iss"`
+	Aud      audience `json:"aud"`
+}
+
+// audience — claim aud бывает строкой или массивом строк
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// VerifyToken проверяет подпись и срок действия токена и возвращает
+// данные пользователя. Принимает значение заголовка Authorization.
+func (v *Verifier) VerifyToken(ctx context.Context, token string) (*TokenInfo, error) {
+	token = strings.TrimPrefix(token, "Bearer ")
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
 	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
 		return nil, ErrInvalidToken
 	}
 
-	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("%w: заголовок: %v", ErrInvalidToken, err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
 	if err != nil {
-		return nil, fmt.Errorf("ошибка декодирования токена: %w", err)
+		return nil, fmt.Errorf("%w: подпись: %v", ErrInvalidToken, err)
 	}
 
-	var claims struct {
-		Sub  string `json:"sub"`
-		Role string `json:"role"`
-		Exp  int64  `json:"exp"`
+	if err := v.verifySignature(ctx, h, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
 	}
-	if err := json.Unmarshal(payload, &claims); err != nil {
-		return nil, fmt.Errorf("ошибка парсинга данных токена: %w", err)
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, fmt.Errorf("%w: данные: %v", ErrInvalidToken, err)
+	}
+
+	if err := v.validateClaims(c); err != nil {
+		return nil, err
+	}
+
+	username := c.Username
+	if username == "" {
+		username = "user"
 	}
 
 	return &TokenInfo{
-		UserID:   claims.Sub,
-		Username: "user",
-		Role:     claims.Role,
+		UserID:   c.Sub,
+		Username: username,
+		Role:     c.Role,
 	}, nil
 }
 
-func VerifyToken(authServiceURL string, token string) (*TokenInfo, error) {
-	if token == "" {
-		return nil, ErrInvalidToken
+// verifySignature выбирает ключ по алгоритму из заголовка. Алгоритм
+// допускается, только если для него настроен ключ, поэтому подмена
+// RS256 на HS256 или "none" не проходит.
+func (v *Verifier) verifySignature(ctx context.Context, h header, signed string, signature []byte) error {
+	switch h.Alg {
+	case "HS256":
+		if len(v.cfg.Secret) == 0 {
+			return fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, h.Alg)
+		}
+		mac := hmac.New(sha256.New, v.cfg.Secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case "RS256":
+		if v.cfg.JWKS == nil {
+			return fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, h.Alg)
+		}
+		key, err := v.cfg.JWKS.Key(ctx, h.Kid)
+		if err != nil {
+			return err
+		}
+		digest := sha256.Sum256([]byte(signed))
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, h.Alg)
 	}
+}
 
-	token = strings.TrimPrefix(token, "Bearer ")
+func (v *Verifier) validateClaims(c claims) error {
+	now := v.now()
+
+	if c.Sub == "" {
+		return fmt.Errorf("%w: нет sub", ErrInvalidToken)
+	}
 
-	tokenInfo, err := extractTokenInfo(token)
-	if err == nil && tokenInfo != nil {
-		return tokenInfo, nil
+	if c.Exp == nil {
+		return fmt.Errorf("%w: нет exp", ErrInvalidToken)
+	}
+	if now.After(time.Unix(*c.Exp, 0).Add(v.cfg.Leeway)) {
+		return ErrTokenExpired
+	}
+
+	if c.Nbf != nil && now.Before(time.Unix(*c.Nbf, 0).Add(-v.cfg.Leeway)) {
+		return ErrTokenNotYetValid
 	}
 
-	return nil, fmt.Errorf("не удалось извлечь информацию из токена: %w", err)
+	if v.cfg.Issuer != "" && c.Iss != v.cfg.Issuer {
+		return ErrInvalidIssuer
+	}
+
+	if v.cfg.Audience != "" {
+		found := false
+		for _, a := range c.Aud {
+			if a == v.cfg.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrInvalidAudience
+		}
+	}
+
+	return nil
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
 }
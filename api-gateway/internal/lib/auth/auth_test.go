@@ -0,0 +1,403 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+var testNow = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func segment(t *testing.T, v any) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signHS256(t *testing.T, secret string, hdr, payload map[string]any) string {
+	t.Helper()
+
+	signed := segment(t, hdr) + "." + segment(t, payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, payload map[string]any) string {
+	t.Helper()
+
+	signed := segment(t, map[string]any{"alg": "RS256", "typ": "JWT", "kid": kid}) + "." + segment(t, payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func claimsWith(overrides map[string]any) map[string]any {
+	c := map[string]any{
+		"sub":  "11111111-1111-1111-1111-111111111111",
+		"role": "user",
+		"exp":  testNow.Add(time.Hour).Unix(),
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(c, k)
+			continue
+		}
+		c[k] = v
+	}
+	return c
+}
+
+// jwksServer отдаёт набор ключей, который можно подменить во время теста
+type jwksServer struct {
+	mu       sync.Mutex
+	keys     map[string]*rsa.PublicKey
+	requests int
+}
+
+func (s *jwksServer) set(keys map[string]*rsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, k := range s.keys {
+		set.Keys = append(set.Keys, jwk{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		})
+	}
+	json.NewEncoder(w).Encode(set)
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func TestVerifyToken(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	otherKey := newRSAKey(t)
+
+	srv := &jwksServer{keys: map[string]*rsa.PublicKey{"k1": &rsaKey.PublicKey}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	hs := map[string]any{"alg": "HS256", "typ": "JWT"}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		token   string
+		wantErr error
+	}{
+		{
+			name:  "valid HS256",
+			token: "Bearer " + signHS256(t, testSecret, hs, claimsWith(nil)),
+		},
+		{
+			name:  "valid RS256",
+			token: signRS256(t, rsaKey, "k1", claimsWith(nil)),
+		},
+		{
+			name:  "valid with issuer and audience list",
+			cfg:   Config{Issuer: "auth", Audience: "marketplace"},
+			token: signHS256(t, testSecret, hs, claimsWith(map[string]any{"iss": "auth", "aud": []string{"other", "marketplace"}})),
+		},
+		{
+			name:  "expired within leeway",
+			cfg:   Config{Leeway: time.Minute},
+			token: signHS256(t, testSecret, hs, claimsWith(map[string]any{"exp": testNow.Add(-30 * time.Second).Unix()})),
+		},
+		{
+			name:    "forged with another secret",
+			token:   signHS256(t, "attacker", hs, claimsWith(nil)),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "forged with another RSA key",
+			token:   signRS256(t, otherKey, "k1", claimsWith(nil)),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "payload swapped after signing",
+			token: func() string {
+				parts := strings.Split(signHS256(t, testSecret, hs, claimsWith(nil)), ".")
+				parts[1] = segment(t, claimsWith(map[string]any{"sub": "admin"}))
+				return strings.Join(parts, ".")
+			}(),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "alg none",
+			token:   segment(t, map[string]any{"alg": "none"}) + "." + segment(t, claimsWith(nil)) + ".",
+			wantErr: ErrUnsupportedAlgorithm,
+		},
+		{
+			name:    "HS256 without configured secret",
+			cfg:     Config{Secret: []byte{}},
+			token:   signHS256(t, "", hs, claimsWith(nil)),
+			wantErr: ErrUnsupportedAlgorithm,
+		},
+		{
+			name:    "unknown kid",
+			token:   signRS256(t, rsaKey, "missing", claimsWith(nil)),
+			wantErr: ErrUnknownKey,
+		},
+		{
+			name:    "expired",
+			token:   signHS256(t, testSecret, hs, claimsWith(map[string]any{"exp": testNow.Add(-time.Minute).Unix()})),
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name:    "without exp",
+			token:   signHS256(t, testSecret, hs, claimsWith(map[string]any{"exp": nil})),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "not yet valid",
+			token:   signHS256(t, testSecret, hs, claimsWith(map[string]any{"nbf": testNow.Add(time.Hour).Unix()})),
+			wantErr: ErrTokenNotYetValid,
+		},
+		{
+			name:    "wrong issuer",
+			cfg:     Config{Issuer: "auth"},
+			token:   signHS256(t, testSecret, hs, claimsWith(map[string]any{"iss": "evil"})),
+			wantErr: ErrInvalidIssuer,
+		},
+		{
+			name:    "wrong audience",
+			cfg:     Config{Audience: "marketplace"},
+			token:   signHS256(t, testSecret, hs, claimsWith(map[string]any{"aud": "other"})),
+			wantErr: ErrInvalidAudience,
+		},
+		{
+			name:    "without sub",
+			token:   signHS256(t, testSecret, hs, claimsWith(map[string]any{"sub": nil})),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "malformed",
+			token:   "not-a-jwt",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "empty",
+			token:   "",
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			if cfg.Secret == nil {
+				cfg.Secret = []byte(testSecret)
+			}
+			cfg.JWKS = NewJWKS(ts.URL, time.Hour)
+
+			v := NewVerifier(cfg)
+			v.now = func() time.Time { return testNow }
+
+			info, err := v.VerifyToken(context.Background(), tt.token)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if info != nil {
+					t.Fatalf("expected no token info, got %+v", info)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.UserID != "11111111-1111-1111-1111-111111111111" || info.Role != "user" {
+				t.Errorf("unexpected token info: %+v", info)
+			}
+		})
+	}
+}
+
+func TestJWKSRotation(t *testing.T) {
+	oldKey := newRSAKey(t)
+	newKey := newRSAKey(t)
+
+	srv := &jwksServer{keys: map[string]*rsa.PublicKey{"old": &oldKey.PublicKey}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	now := testNow
+	jwks := NewJWKS(ts.URL, time.Hour)
+	jwks.now = func() time.Time { return now }
+
+	v := NewVerifier(Config{JWKS: jwks})
+	v.now = func() time.Time { return now }
+
+	if _, err := v.VerifyToken(context.Background(), signRS256(t, oldKey, "old", claimsWith(nil))); err != nil {
+		t.Fatalf("old key: %v", err)
+	}
+
+	// Ключи закэшированы — повторный запрос не нужен
+	if _, err := v.VerifyToken(context.Background(), signRS256(t, oldKey, "old", claimsWith(nil))); err != nil {
+		t.Fatalf("old key from cache: %v", err)
+	}
+	if srv.requests != 1 {
+		t.Fatalf("JWKS requests = %d, want 1", srv.requests)
+	}
+
+	srv.set(map[string]*rsa.PublicKey{"old": &oldKey.PublicKey, "new": &newKey.PublicKey})
+	rotated := signRS256(t, newKey, "new", claimsWith(nil))
+
+	// Сразу после загрузки неизвестный kid не вызывает повторный запрос
+	if _, err := v.VerifyToken(context.Background(), rotated); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("err = %v, want ErrUnknownKey", err)
+	}
+	if srv.requests != 1 {
+		t.Fatalf("JWKS requests = %d, want 1", srv.requests)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := v.VerifyToken(context.Background(), rotated); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if srv.requests != 2 {
+		t.Fatalf("JWKS requests = %d, want 2", srv.requests)
+	}
+
+	// Старый ключ отозван — после истечения TTL токены с ним не принимаются
+	srv.set(map[string]*rsa.PublicKey{"new": &newKey.PublicKey})
+	now = now.Add(2 * time.Hour)
+	if _, err := v.VerifyToken(context.Background(), signRS256(t, oldKey, "old", claimsWith(map[string]any{"exp": now.Add(time.Hour).Unix()}))); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("err = %v, want ErrUnknownKey", err)
+	}
+}
+
+// Недоступный сервис авторизации опрашивается не чаще minRefresh
+func TestJWKSFailedFetchBackoff(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	now := testNow
+	jwks := NewJWKS(ts.URL, time.Hour)
+	jwks.now = func() time.Time { return now }
+
+	for range 3 {
+		if _, err := jwks.Key(context.Background(), "k1"); err == nil || errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("err = %v, want fetch error", err)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("JWKS requests = %d, want 1", requests)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := jwks.Key(context.Background(), "k1"); err == nil {
+		t.Fatal("expected fetch error")
+	}
+	if requests != 2 {
+		t.Fatalf("JWKS requests = %d, want 2", requests)
+	}
+}
+
+// Пока ключи обновляются, известные ключи отдаются из кэша без ожидания
+func TestJWKSServesCachedKeysDuringFetch(t *testing.T) {
+	key := newRSAKey(t)
+	srv := &jwksServer{keys: map[string]*rsa.PublicKey{"k1": &key.PublicKey}}
+
+	var calls int
+	release := make(chan struct{})
+	blocked := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 2 {
+			close(blocked)
+			<-release
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	var mu sync.Mutex
+	now := testNow
+	jwks := NewJWKS(ts.URL, time.Hour)
+	jwks.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	if _, err := jwks.Key(context.Background(), "k1"); err != nil {
+		t.Fatalf("initial fetch: %v", err)
+	}
+
+	// Неизвестный kid запускает медленное обновление
+	mu.Lock()
+	now = now.Add(time.Minute)
+	mu.Unlock()
+	go jwks.Key(context.Background(), "k2")
+	select {
+	case <-blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("refresh was not started")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := jwks.Key(context.Background(), "k1")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("cached key: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cached key lookup waited for the JWKS request")
+	}
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrUnknownKey = errors.New("ключ подписи не найден")
+
+// JWKS — кэш открытых ключей сервиса авторизации. Ключи перечитываются по
+// истечении TTL, а также при встрече неизвестного kid (ротация ключей), но
+// не чаще одного раза в minRefresh — в том числе после неудачной попытки.
+// Запрос идёт без блокировки: пока один вызов обновляет набор, остальные
+// получают ключи из кэша или ждут только этот запрос.
+type JWKS struct {
+	url        string
+	client     *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+	now        func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	fetchErr    error
+	// fetching закрывается, когда текущий запрос ключей завершится
+	fetching chan struct{}
+}
+
+func NewJWKS(url string, ttl time.Duration) *JWKS {
+	return &JWKS{
+		url:        url,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		ttl:        ttl,
+		minRefresh: 10 * time.Second,
+		now:        time.Now,
+	}
+}
+
+// Key возвращает ключ по kid, при необходимости обновляя набор ключей
+func (j *JWKS) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+
+	now := j.now()
+	key, ok := j.keys[kid]
+	expired := now.Sub(j.fetchedAt) >= j.ttl
+
+	if ok && !expired {
+		j.mu.Unlock()
+		return key, nil
+	}
+
+	// Неизвестный kid и недоступный сервис авторизации не должны приводить
+	// к запросу на каждый токен
+	if now.Sub(j.attemptedAt) < j.minRefresh {
+		defer j.mu.Unlock()
+		return j.lookup(kid)
+	}
+
+	if j.fetching != nil {
+		// Ключи уже обновляет другой запрос: старый ключ ещё годится,
+		// неизвестного kid ждём
+		if ok {
+			j.mu.Unlock()
+			return key, nil
+		}
+		fetching := j.fetching
+		j.mu.Unlock()
+
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		return j.lookup(kid)
+	}
+
+	fetching := make(chan struct{})
+	j.fetching = fetching
+	j.attemptedAt = now
+	j.mu.Unlock()
+
+	// Результат нужен и тем, кто ждёт, поэтому отмена вызывающего запрос
+	// не прерывает; его ограничивает таймаут клиента
+	keys, err := j.fetch(context.WithoutCancel(ctx))
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.fetching = nil
+	close(fetching)
+
+	if err != nil {
+		j.fetchErr = err
+		// Сервис авторизации недоступен — работаем на старых ключах
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	j.keys = keys
+	j.fetchedAt = now
+	j.fetchErr = nil
+
+	return j.lookup(kid)
+}
+
+// lookup ищет ключ в кэше; вызывается под j.mu. Пока не удалось загрузить
+// ни одного набора, возвращает ошибку последнего запроса.
+func (j *JWKS) lookup(kid string) (*rsa.PublicKey, error) {
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	if j.keys == nil && j.fetchErr != nil {
+		return nil, j.fetchErr
+	}
+	return nil, fmt.Errorf("%w: kid %q", ErrUnknownKey, kid)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func (j *JWKS) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса JWKS: %w", err)
+	}
+
+	res, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса JWKS: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ошибка запроса JWKS: статус %d", res.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		key, err := parseRSAKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка парсинга ключа %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func parseRSAKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+
+	exp := new(big.Int).SetBytes(e)
+	if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("некорректная экспонента")
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+}
@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Entry — сохранённый ответ сервиса
+type Entry struct {
+	Status   int
+	Header   http.Header
+	Body     []byte
+	ETag     string
+	StoredAt time.Time
+	Expires  time.Time
+	// Tags — по ним запись сбрасывается (например, топик событий)
+	Tags []string
+}
+
+func (e *Entry) size() int64 {
+	n := int64(len(e.Body))
+	for k, vs := range e.Header {
+		n += int64(len(k))
+		for _, v := range vs {
+			n += int64(len(v))
+		}
+	}
+	return n
+}
+
+type item struct {
+	key   string
+	entry *Entry
+	size  int64
+}
+
+// LRU — кэш ответов с ограничением по суммарному размеру; при переполнении
+// вытесняются давно не читавшиеся записи
+type LRU struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List
+	items    map[string]*list.Element
+	tags     map[string]map[string]struct{}
+	// generation растёт при каждом сбросе: ответ, запрошенный до сброса,
+	// не должен попасть в кэш после него
+	generation uint64
+}
+
+func NewLRU(maxBytes int64) *LRU {
+	return &LRU{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+		tags:     make(map[string]map[string]struct{}),
+	}
+}
+
+// Get возвращает неистёкшую запись
+func (c *LRU) Get(key string, now time.Time) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	it := el.Value.(*item)
+	if !now.Before(it.entry.Expires) {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return it.entry, true
+}
+
+// Generation — текущее поколение; его нужно взять до запроса к сервису
+// и передать в Set
+func (c *LRU) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// Set сохраняет запись, если с поколения generation ничего не сбрасывалось
+// и запись помещается в кэш
+func (c *LRU) Set(key string, entry *Entry, generation uint64) bool {
+	size := entry.size() + int64(len(key))
+	if size > c.maxBytes {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return false
+	}
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+
+	el := c.order.PushFront(&item{key: key, entry: entry, size: size})
+	c.items[key] = el
+	c.size += size
+	for _, tag := range entry.Tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+
+	return true
+}
+
+// InvalidateTag удаляет записи с тегом и возвращает их число
+func (c *LRU) InvalidateTag(tag string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	keys := c.tags[tag]
+	n := len(keys)
+	for key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.remove(el)
+		}
+	}
+	delete(c.tags, tag)
+
+	return n
+}
+
+func (c *LRU) remove(el *list.Element) {
+	it := c.order.Remove(el).(*item)
+	delete(c.items, it.key)
+	c.size -= it.size
+
+	for _, tag := range it.entry.Tags {
+		if keys, ok := c.tags[tag]; ok {
+			delete(keys, it.key)
+			if len(keys) == 0 {
+				delete(c.tags, tag)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func entry(body string, tags ...string) *Entry {
+	return &Entry{Status: 200, Body: []byte(body), Expires: testNow.Add(time.Minute), Tags: tags}
+}
+
+func TestLRUGeneration(t *testing.T) {
+	c := NewLRU(1 << 10)
+
+	// Ответ запрошен до сброса и пришёл после него — он может быть устаревшим
+	gen := c.Generation()
+	c.InvalidateTag("product-events")
+	if c.Set("/products/", entry("stale", "product-events"), gen) {
+		t.Fatal("Set stored a response fetched before invalidation")
+	}
+	if _, ok := c.Get("/products/", testNow); ok {
+		t.Fatal("stale response is cached")
+	}
+
+	gen = c.Generation()
+	if !c.Set("/products/", entry("fresh", "product-events"), gen) {
+		t.Fatal("Set rejected a response of the current generation")
+	}
+	if e, ok := c.Get("/products/", testNow); !ok || !bytes.Equal(e.Body, []byte("fresh")) {
+		t.Fatalf("Get = %v, %v", e, ok)
+	}
+}
+
+func TestLRUInvalidateTag(t *testing.T) {
+	c := NewLRU(1 << 10)
+	gen := c.Generation()
+
+	c.Set("/products/", entry("list", "product-events"), gen)
+	c.Set("/product/?id=1", entry("one", "product-events", "comment-events"), gen)
+	c.Set("/comments/", entry("comments", "comment-events"), gen)
+
+	if n := c.InvalidateTag("product-events"); n != 2 {
+		t.Fatalf("InvalidateTag = %d, want 2", n)
+	}
+	for _, key := range []string{"/products/", "/product/?id=1"} {
+		if _, ok := c.Get(key, testNow); ok {
+			t.Errorf("%s survived invalidation", key)
+		}
+	}
+	if _, ok := c.Get("/comments/", testNow); !ok {
+		t.Error("entry with another tag was invalidated")
+	}
+
+	// Удалённая запись не остаётся в индексе другого тега
+	if n := c.InvalidateTag("comment-events"); n != 1 {
+		t.Fatalf("InvalidateTag = %d, want 1", n)
+	}
+	if len(c.tags) != 0 || len(c.items) != 0 || c.size != 0 {
+		t.Fatalf("cache is not empty: tags=%v items=%d size=%d", c.tags, len(c.items), c.size)
+	}
+}
+
+func TestLRUEviction(t *testing.T) {
+	// Ключ и тело каждой записи — 10 байт
+	c := NewLRU(30)
+	gen := c.Generation()
+
+	c.Set("k1", entry("aaaaaaaa", "t"), gen)
+	c.Set("k2", entry("bbbbbbbb"), gen)
+	c.Set("k3", entry("cccccccc"), gen)
+	c.Get("k1", testNow)
+	c.Set("k4", entry("dddddddd"), gen)
+
+	if _, ok := c.Get("k2", testNow); ok {
+		t.Error("least recently used k2 is not evicted")
+	}
+	for _, key := range []string{"k1", "k3", "k4"} {
+		if _, ok := c.Get(key, testNow); !ok {
+			t.Errorf("%s is evicted", key)
+		}
+	}
+
+	if c.Set("big", entry(string(make([]byte, 40))), gen) {
+		t.Error("Set stored an entry larger than the cache")
+	}
+
+	if _, ok := c.Get("k1", testNow.Add(time.Minute)); ok {
+		t.Error("expired entry is returned")
+	}
+	if _, ok := c.tags["t"]; ok {
+		t.Error("tag of the expired entry is kept")
+	}
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Key строится из метода, пути, запроса с упорядоченными параметрами и
+// значений заголовков vary, поэтому ?b=2&a=1 и ?a=1&b=2 дают одну запись
+func Key(r *http.Request, vary []string) string {
+	var b strings.Builder
+
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.URL.Path)
+	b.WriteByte('?')
+	b.WriteString(normalizeQuery(r.URL.Query()))
+
+	for _, name := range vary {
+		b.WriteByte('\n')
+		b.WriteString(http.CanonicalHeaderKey(name))
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+
+	return b.String()
+}
+
+func normalizeQuery(q url.Values) string {
+	for _, vs := range q {
+		slices.Sort(vs)
+	}
+	// Encode сортирует ключи
+	return q.Encode()
+}
+
+// ETag — сильный валидатор по содержимому ответа
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// NotModified сообщает, совпадает ли If-None-Match запроса с etag
+func NotModified(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || etag == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Storable сообщает, можно ли сохранить ответ: сервис не запретил это
+// через Cache-Control, ответ не ставит cookie и не зависит от всех
+// заголовков сразу (Vary: *)
+func Storable(status int, header http.Header) bool {
+	if status != http.StatusOK {
+		return false
+	}
+	if header.Get("Set-Cookie") != "" || header.Get("Vary") == "*" {
+		return false
+	}
+
+	for _, directive := range cacheControl(header) {
+		switch directive {
+		case "no-store", "private", "no-cache":
+			return false
+		}
+	}
+	return true
+}
+
+// Bypass сообщает, что клиент просит не отдавать ответ из кэша
+func Bypass(r *http.Request) bool {
+	if r.Header.Get("Pragma") == "no-cache" {
+		return true
+	}
+	for _, directive := range cacheControl(r.Header) {
+		if directive == "no-cache" || directive == "no-store" || directive == "max-age=0" {
+			return true
+		}
+	}
+	return false
+}
+
+func cacheControl(header http.Header) []string {
+	var directives []string
+	for _, v := range header.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			directives = append(directives, strings.ToLower(strings.TrimSpace(d)))
+		}
+	}
+	return directives
+}
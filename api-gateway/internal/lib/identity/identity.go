@@ -0,0 +1,59 @@
+package identity
+
+import (
+	"api-gateway/internal/lib/auth"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Заголовки, которыми шлюз передаёт пользователя сервисам. Клиент не может
+// задать их сам: Strip удаляет их из входящего запроса.
+const (
+	HeaderUserID    = "X-User-ID"
+	HeaderUsername  = "X-Username"
+	HeaderUserRole  = "X-User-Role"
+	HeaderTimestamp = "X-Identity-Timestamp"
+	HeaderSignature = "X-Identity-Signature"
+)
+
+var headers = []string{HeaderUserID, HeaderUsername, HeaderUserRole, HeaderTimestamp, HeaderSignature}
+
+// Strip удаляет заголовки идентификации из запроса
+func Strip(h http.Header) {
+	for _, name := range headers {
+		h.Del(name)
+	}
+}
+
+// Set добавляет заголовки пользователя и их подпись для запроса к сервису
+// с телом body. Формат подписи проверяет catalog/internal/middleware.HeaderAuth.
+func Set(h http.Header, secret []byte, info *auth.TokenInfo, method, path, rawQuery string, body []byte, now time.Time) {
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	h.Set(HeaderUserID, info.UserID)
+	h.Set(HeaderUsername, info.Username)
+	h.Set(HeaderUserRole, info.Role)
+	h.Set(HeaderTimestamp, ts)
+	h.Set(HeaderSignature, Sign(secret, method, path, rawQuery, BodyDigest(body), info.UserID, info.Username, info.Role, ts))
+}
+
+// Sign считает HMAC-SHA256 от метода, пути, строки запроса и хеша тела
+// запроса к сервису и значений заголовков, разделённых переводом строки.
+// Так подписанные заголовки нельзя переслать с другим запросом, пока
+// подпись не устарела.
+func Sign(secret []byte, method, path, rawQuery, bodyDigest, userID, username, role, timestamp string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + "\n" + path + "\n" + rawQuery + "\n" + bodyDigest + "\n" +
+		userID + "\n" + username + "\n" + role + "\n" + timestamp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// BodyDigest — hex SHA-256 тела запроса; у запроса без тела это хеш пустой строки
+func BodyDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,68 @@
+package identity
+
+import (
+	"api-gateway/internal/lib/auth"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// signedVector — подпись, которую проверяет catalog/internal/middleware
+// (TestVerifyIdentityGatewayVector): при изменении формата меняются оба теста
+const signedVector = "9373238cbc51ab814b718f802ead88e70e58e5e49884f26133874543d50d96f4"
+
+var (
+	testNow  = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	testBody = []byte(`{"price":100}`)
+)
+
+func TestSignMatchesCatalog(t *testing.T) {
+	got := Sign([]byte("test-secret"), http.MethodPut, "/product/", "id=42", BodyDigest(testBody),
+		"11111111-1111-1111-1111-111111111111", "alice", "seller", strconv.FormatInt(testNow.Unix(), 10))
+	if got != signedVector {
+		t.Fatalf("Sign = %s, want %s", got, signedVector)
+	}
+}
+
+func TestBodyDigest(t *testing.T) {
+	const empty = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if got := BodyDigest(nil); got != empty {
+		t.Fatalf("BodyDigest(nil) = %s, want %s", got, empty)
+	}
+}
+
+func TestSetSignsRequest(t *testing.T) {
+	secret := []byte("test-secret")
+	info := &auth.TokenInfo{UserID: "11111111-1111-1111-1111-111111111111", Username: "alice", Role: "seller"}
+
+	h := http.Header{}
+	Set(h, secret, info, http.MethodPut, "/product/", "id=42", testBody, testNow)
+
+	if h.Get(HeaderSignature) != signedVector {
+		t.Fatalf("signature = %s, want %s", h.Get(HeaderSignature), signedVector)
+	}
+
+	// Те же заголовки не подходят к запросу с другим методом, путём,
+	// параметрами или телом
+	ts := h.Get(HeaderTimestamp)
+	digest := BodyDigest(testBody)
+	others := []struct{ method, path, query, digest string }{
+		{http.MethodDelete, "/product/", "id=42", digest},
+		{http.MethodPut, "/comment/", "id=42", digest},
+		{http.MethodPut, "/product/", "id=43", digest},
+		{http.MethodPut, "/product/", "id=42", BodyDigest([]byte(`{"price":1}`))},
+	}
+	for _, req := range others {
+		if Sign(secret, req.method, req.path, req.query, req.digest, info.UserID, info.Username, info.Role, ts) == signedVector {
+			t.Errorf("signature of %+v matches the signed request", req)
+		}
+	}
+
+	Strip(h)
+	for _, name := range headers {
+		if h.Get(name) != "" {
+			t.Errorf("%s is not stripped", name)
+		}
+	}
+}
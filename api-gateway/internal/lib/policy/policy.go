@@ -0,0 +1,105 @@
+package policy
+
+import (
+	"api-gateway/internal/lib/auth"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+type Access string
+
+const (
+	// Public — доступ без токена
+	Public Access = "public"
+	// Authenticated — нужен действительный токен
+	Authenticated Access = "authenticated"
+	// Roles — нужен токен с одной из ролей правила
+	Roles Access = "roles"
+)
+
+var (
+	ErrUnauthenticated = errors.New("требуется авторизация")
+	ErrForbidden       = errors.New("недостаточно прав")
+)
+
+// Rule — правило доступа к маршруту. Пустой Methods означает любой метод.
+type Rule struct {
+	Methods []string `yaml:"methods"`
+	Access  Access   `yaml:"access"`
+	Roles   []string `yaml:"roles"`
+}
+
+// Validate проверяет правило при загрузке конфигурации
+func (r Rule) Validate() error {
+	switch r.Access {
+	case Public, Authenticated:
+		return nil
+	case Roles:
+		if len(r.Roles) == 0 {
+			return errors.New("access 'roles' requires at least one role")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown access %q", r.Access)
+	}
+}
+
+// Select выбирает правило для метода: правило с явным методом важнее
+// правила для любого метода; если подходящего нет, действует fallback
+func Select(rules []Rule, method string, fallback Access) Rule {
+	var (
+		wildcard Rule
+		found    bool
+	)
+
+	for _, r := range rules {
+		if len(r.Methods) == 0 {
+			if !found {
+				wildcard, found = r, true
+			}
+			continue
+		}
+		if slices.Contains(r.Methods, method) {
+			return r
+		}
+	}
+
+	if found {
+		return wildcard
+	}
+	return Rule{Access: fallback}
+}
+
+// Authorize проверяет, допускает ли правило пользователя; info равен nil
+// для анонимного запроса
+func (r Rule) Authorize(info *auth.TokenInfo) error {
+	switch r.Access {
+	case Public:
+		return nil
+	case Authenticated:
+		if info == nil {
+			return ErrUnauthenticated
+		}
+		return nil
+	case Roles:
+		if info == nil {
+			return ErrUnauthenticated
+		}
+		if !slices.Contains(r.Roles, info.Role) {
+			return ErrForbidden
+		}
+		return nil
+	default:
+		return ErrForbidden
+	}
+}
+
+// Status возвращает HTTP-статус для ошибки Authorize
+func Status(err error) int {
+	if errors.Is(err, ErrUnauthenticated) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusForbidden
+}
@@ -0,0 +1,73 @@
+package policy
+
+import (
+	"api-gateway/internal/lib/auth"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	get := Rule{Methods: []string{http.MethodGet}, Access: Public}
+	write := Rule{Methods: []string{http.MethodPost, http.MethodPut}, Access: Roles, Roles: []string{"seller"}}
+	any1 := Rule{Access: Authenticated}
+	any2 := Rule{Access: Roles, Roles: []string{"admin"}}
+
+	tests := []struct {
+		name   string
+		rules  []Rule
+		method string
+		want   Rule
+	}{
+		{name: "explicit method", rules: []Rule{get, write}, method: http.MethodPut, want: write},
+		{name: "explicit method after wildcard", rules: []Rule{any1, get}, method: http.MethodGet, want: get},
+		{name: "wildcard when method not listed", rules: []Rule{get, any1}, method: http.MethodDelete, want: any1},
+		{name: "first wildcard wins", rules: []Rule{any1, any2}, method: http.MethodGet, want: any1},
+		{name: "fallback without match", rules: []Rule{get}, method: http.MethodPost, want: Rule{Access: Authenticated}},
+		{name: "fallback without rules", method: http.MethodGet, want: Rule{Access: Authenticated}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Select(tt.rules, tt.method, Authenticated)
+			if got.Access != tt.want.Access || !slices.Equal(got.Methods, tt.want.Methods) || !slices.Equal(got.Roles, tt.want.Roles) {
+				t.Fatalf("Select = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	user := &auth.TokenInfo{UserID: "u-1", Role: "user"}
+	admin := &auth.TokenInfo{UserID: "u-2", Role: "admin"}
+	admins := Rule{Access: Roles, Roles: []string{"admin"}}
+
+	tests := []struct {
+		name       string
+		rule       Rule
+		info       *auth.TokenInfo
+		wantErr    error
+		wantStatus int
+	}{
+		{name: "public anonymous", rule: Rule{Access: Public}},
+		{name: "authenticated", rule: Rule{Access: Authenticated}, info: user},
+		{name: "authenticated anonymous", rule: Rule{Access: Authenticated}, wantErr: ErrUnauthenticated, wantStatus: http.StatusUnauthorized},
+		{name: "role allowed", rule: admins, info: admin},
+		{name: "role denied", rule: admins, info: user, wantErr: ErrForbidden, wantStatus: http.StatusForbidden},
+		{name: "role anonymous", rule: admins, wantErr: ErrUnauthenticated, wantStatus: http.StatusUnauthorized},
+		{name: "unknown access", rule: Rule{Access: "everyone"}, info: admin, wantErr: ErrForbidden, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Authorize(tt.info)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && Status(err) != tt.wantStatus {
+				t.Errorf("status = %d, want %d", Status(err), tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory — корзины в памяти процесса
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*bucket)}
+}
+
+func (m *Memory) Take(_ context.Context, key string, limit Limit, now time.Time) (Decision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{}
+		m.buckets[key] = b
+	}
+
+	return b.take(limit, now), nil
+}
+
+// Run удаляет наполнившиеся корзины, пока не отменён ctx: новая корзина
+// ведёт себя так же, как полная
+func (m *Memory) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.evict(now)
+		}
+	}
+}
+
+func (m *Memory) evict(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, b := range m.buckets {
+		if now.After(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+}
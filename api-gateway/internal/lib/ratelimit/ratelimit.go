@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Limit — параметры корзины токенов: Rate токенов в секунду, не больше
+// Burst токенов в запасе
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Decision — итог попытки взять токен
+type Decision struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter — через сколько появится токен, если запрос отклонён
+	RetryAfter time.Duration
+	// Reset — через сколько корзина наполнится полностью
+	Reset time.Duration
+}
+
+// Backend хранит корзины. Memory годится для одного экземпляра шлюза;
+// для нескольких нужна реализация поверх общего хранилища (например,
+// Redis), выполняющая take атомарно.
+type Backend interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Decision, error)
+}
+
+// bucket — состояние корзины; хранилища держат его как есть
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full — когда корзина наполнится; после этого её можно забыть
+	full time.Time
+}
+
+// take пополняет корзину за прошедшее время и пытается взять токен
+func (b *bucket) take(limit Limit, now time.Time) Decision {
+	burst := float64(limit.Burst)
+
+	if b.updated.IsZero() {
+		b.tokens = burst
+	} else if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*limit.Rate)
+	}
+	b.updated = now
+
+	d := Decision{}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+
+	d.Remaining = int(b.tokens)
+	d.Reset = seconds((burst - b.tokens) / limit.Rate)
+	b.full = now.Add(d.Reset)
+
+	return d
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+// SetHeaders выставляет заголовки X-RateLimit-* и, если запрос отклонён,
+// Retry-After. Время — в целых секундах с округлением вверх.
+func SetHeaders(h http.Header, limit Limit, d Decision) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+
+	if !d.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(d.RetryAfter))))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
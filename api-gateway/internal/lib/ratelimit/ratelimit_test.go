@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestBucketTake(t *testing.T) {
+	// 2 токена в секунду, запас 3
+	limit := Limit{Rate: 2, Burst: 3}
+
+	tests := []struct {
+		name       string
+		at         time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		reset      time.Duration
+	}{
+		{name: "new bucket is full", at: 0, allowed: true, remaining: 2, reset: 500 * time.Millisecond},
+		{name: "second", at: 0, allowed: true, remaining: 1, reset: time.Second},
+		{name: "third", at: 0, allowed: true, remaining: 0, reset: 1500 * time.Millisecond},
+		{name: "burst spent", at: 0, allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond, reset: 1500 * time.Millisecond},
+		{name: "half a token later", at: 250 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 250 * time.Millisecond, reset: 1250 * time.Millisecond},
+		{name: "refilled one", at: 500 * time.Millisecond, allowed: true, remaining: 0, reset: 1500 * time.Millisecond},
+		{name: "capped at burst", at: time.Hour, allowed: true, remaining: 2, reset: 500 * time.Millisecond},
+	}
+
+	var b bucket
+	for _, tt := range tests {
+		d := b.take(limit, testNow.Add(tt.at))
+		if d.Allowed != tt.allowed || d.Remaining != tt.remaining || d.RetryAfter != tt.retryAfter || d.Reset != tt.reset {
+			t.Fatalf("%s: decision = %+v, want allowed=%v remaining=%d retry_after=%v reset=%v",
+				tt.name, d, tt.allowed, tt.remaining, tt.retryAfter, tt.reset)
+		}
+	}
+}
+
+func TestBucketClockSkew(t *testing.T) {
+	limit := Limit{Rate: 1, Burst: 1}
+
+	var b bucket
+	b.take(limit, testNow)
+	// Часы пошли назад: корзина не пополняется
+	if d := b.take(limit, testNow.Add(-time.Minute)); d.Allowed {
+		t.Fatalf("token taken in the past: %+v", d)
+	}
+}
+
+func TestMemoryEvictsFullBuckets(t *testing.T) {
+	m := NewMemory()
+	limit := Limit{Rate: 1, Burst: 2}
+
+	if _, err := m.Take(context.Background(), "a", limit, testNow); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Take(context.Background(), "b", limit, testNow.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	m.evict(testNow.Add(30 * time.Second))
+
+	if _, ok := m.buckets["a"]; ok {
+		t.Error("full bucket a is not evicted")
+	}
+	if _, ok := m.buckets["b"]; !ok {
+		t.Error("bucket b is evicted before it is full")
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	limit := Limit{Rate: 1, Burst: 5}
+
+	h := http.Header{}
+	SetHeaders(h, limit, Decision{Allowed: false, RetryAfter: 100 * time.Millisecond, Reset: 4200 * time.Millisecond})
+
+	want := map[string]string{
+		"X-RateLimit-Limit":     "5",
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     "5",
+		"Retry-After":           "1",
+	}
+	for name, value := range want {
+		if got := h.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+
+	h = http.Header{}
+	SetHeaders(h, limit, Decision{Allowed: true, Remaining: 3})
+	if h.Get("Retry-After") != "" {
+		t.Error("Retry-After is set for an allowed request")
+	}
+}
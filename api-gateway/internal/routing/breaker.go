@@ -0,0 +1,195 @@
+package routing
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker — настройки автомата защиты экземпляра. После
+// failure_threshold неудач подряд экземпляр не получает запросов
+// open_timeout, затем пропускает half_open_requests пробных запросов:
+// успех замыкает автомат, неудача снова размыкает.
+type CircuitBreaker struct {
+	FailureThreshold int           `yaml:"failure_threshold"`
+	OpenTimeout      time.Duration `yaml:"open_timeout"`
+	HalfOpenRequests int           `yaml:"half_open_requests"`
+}
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+	defaultHalfOpenRequests = 1
+)
+
+func (c *CircuitBreaker) setDefaults() error {
+	if c.FailureThreshold < 0 || c.OpenTimeout < 0 || c.HalfOpenRequests < 0 {
+		return errors.New("circuit_breaker values must not be negative")
+	}
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = defaultFailureThreshold
+	}
+	if c.OpenTimeout == 0 {
+		c.OpenTimeout = defaultOpenTimeout
+	}
+	if c.HalfOpenRequests == 0 {
+		c.HalfOpenRequests = defaultHalfOpenRequests
+	}
+	return nil
+}
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// breaker — автомат защиты одного экземпляра. Без настроек всегда замкнут.
+type breaker struct {
+	mu       sync.Mutex
+	cfg      *CircuitBreaker
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probes   int
+	now      func() time.Time
+}
+
+func newBreaker() *breaker {
+	return &breaker{state: CircuitClosed, now: time.Now}
+}
+
+func (b *breaker) configure(cfg *CircuitBreaker) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if cfg == nil {
+		b.cfg = nil
+		b.reset()
+		return
+	}
+
+	c := *cfg
+	b.cfg = &c
+}
+
+func (b *breaker) reset() {
+	b.state = CircuitClosed
+	b.failures = 0
+	b.probes = 0
+}
+
+// ready сообщает, пропустит ли автомат запрос, не занимая пробный слот
+func (b *breaker) ready() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	return b.state == CircuitClosed ||
+		(b.state == CircuitHalfOpen && b.probes < b.cfg.HalfOpenRequests)
+}
+
+// allow пропускает запрос; в полуоткрытом состоянии занимает пробный слот
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	switch b.state {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		if b.probes >= b.cfg.HalfOpenRequests {
+			return false
+		}
+		b.probes++
+		return true
+	default:
+		return false
+	}
+}
+
+// advance переводит разомкнутый автомат в полуоткрытый по истечении
+// open_timeout
+func (b *breaker) advance() {
+	if b.cfg == nil || b.state != CircuitOpen {
+		return
+	}
+	if b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		b.state = CircuitHalfOpen
+		b.probes = 0
+	}
+}
+
+// Result — итог попытки запроса к экземпляру
+type Result int
+
+const (
+	// Success — экземпляр ответил
+	Success Result = iota
+	// Failure — ошибка соединения, тайм-аут или 502/503/504
+	Failure
+	// Aborted — запрос отменил клиент; на автомат не влияет
+	Aborted
+)
+
+// record учитывает результат запроса и возвращает новое состояние, если
+// оно изменилось
+func (b *breaker) record(result Result) (CircuitState, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cfg == nil {
+		return b.state, false
+	}
+
+	if result == Aborted {
+		if b.state == CircuitHalfOpen && b.probes > 0 {
+			b.probes--
+		}
+		return b.state, false
+	}
+
+	// Запросы, начатые до размыкания, состояние разомкнутого автомата не
+	// меняют: его закрывает только пробный запрос
+	if result == Success {
+		switch b.state {
+		case CircuitClosed:
+			b.failures = 0
+		case CircuitHalfOpen:
+			b.reset()
+			return b.state, true
+		}
+		return b.state, false
+	}
+
+	switch b.state {
+	case CircuitHalfOpen:
+		b.open()
+		return b.state, true
+	case CircuitClosed:
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.open()
+			return b.state, true
+		}
+	}
+	return b.state, false
+}
+
+func (b *breaker) open() {
+	b.state = CircuitOpen
+	b.openedAt = b.now()
+	b.failures = 0
+	b.probes = 0
+}
+
+func (b *breaker) current() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	return b.state
+}
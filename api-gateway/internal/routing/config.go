@@ -0,0 +1,241 @@
+package routing
+
+import (
+	"api-gateway/internal/lib/policy"
+	"api-gateway/internal/lib/ratelimit"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig — формат файла маршрутов (YAML или JSON)
+type fileConfig struct {
+	DefaultAccess policy.Access `yaml:"default_access"`
+	Routes        []routeConfig `yaml:"routes"`
+}
+
+type routeConfig struct {
+	Prefix      string          `yaml:"prefix"`
+	Upstreams   []string        `yaml:"upstreams"`
+	Balancing   Balancing       `yaml:"balancing"`
+	HealthCheck *HealthCheck    `yaml:"health_check"`
+	Breaker     *CircuitBreaker `yaml:"circuit_breaker"`
+	Retry       *RetryPolicy    `yaml:"retry"`
+	RateLimit   *rateLimit      `yaml:"rate_limit"`
+	Cache       *CachePolicy    `yaml:"cache"`
+	StripPrefix bool            `yaml:"strip_prefix"`
+	Rewrite     string          `yaml:"rewrite"`
+	Timeout     time.Duration   `yaml:"timeout"`
+	Auth        []policy.Rule   `yaml:"auth"`
+}
+
+// rateLimit — не больше requests запросов за per от одного пользователя
+// (или IP для анонимных) с запасом burst на всплески
+type rateLimit struct {
+	Requests int           `yaml:"requests"`
+	Per      time.Duration `yaml:"per"`
+	Burst    int           `yaml:"burst"`
+}
+
+func (rl rateLimit) limit() (ratelimit.Limit, error) {
+	if rl.Requests <= 0 {
+		return ratelimit.Limit{}, errors.New("rate_limit requests must be positive")
+	}
+	if rl.Per < 0 || rl.Burst < 0 {
+		return ratelimit.Limit{}, errors.New("rate_limit values must not be negative")
+	}
+	if rl.Per == 0 {
+		rl.Per = time.Second
+	}
+	if rl.Burst == 0 {
+		rl.Burst = rl.Requests
+	}
+
+	return ratelimit.Limit{
+		Rate:  float64(rl.Requests) / rl.Per.Seconds(),
+		Burst: rl.Burst,
+	}, nil
+}
+
+// LoadFile читает и проверяет файл маршрутов. Переменные окружения вида
+// ${NAME} в файле подставляются до разбора.
+func LoadFile(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading routes file: %w", err)
+	}
+
+	return Parse([]byte(os.ExpandEnv(string(data))))
+}
+
+func Parse(data []byte) (*Table, error) {
+	var cfg fileConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing routes: %w", err)
+	}
+
+	if cfg.DefaultAccess == "" {
+		cfg.DefaultAccess = policy.Authenticated
+	}
+	if cfg.DefaultAccess != policy.Public && cfg.DefaultAccess != policy.Authenticated {
+		return nil, fmt.Errorf("default_access must be %q or %q, got %q", policy.Public, policy.Authenticated, cfg.DefaultAccess)
+	}
+
+	if len(cfg.Routes) == 0 {
+		return nil, errors.New("no routes defined")
+	}
+
+	seen := make(map[string]struct{}, len(cfg.Routes))
+	routes := make([]*Route, 0, len(cfg.Routes))
+
+	for i, rc := range cfg.Routes {
+		route, err := buildRoute(rc, cfg.DefaultAccess)
+		if err != nil {
+			return nil, fmt.Errorf("route #%d (%s): %w", i+1, rc.Prefix, err)
+		}
+
+		if _, ok := seen[route.Prefix]; ok {
+			return nil, fmt.Errorf("route #%d: duplicate prefix %q", i+1, route.Prefix)
+		}
+		seen[route.Prefix] = struct{}{}
+
+		routes = append(routes, route)
+	}
+
+	if err := checkSharedUpstreams(routes); err != nil {
+		return nil, err
+	}
+
+	return newTable(routes), nil
+}
+
+func buildRoute(rc routeConfig, defaultAccess policy.Access) (*Route, error) {
+	if !strings.HasPrefix(rc.Prefix, "/") {
+		return nil, errors.New("prefix must start with '/'")
+	}
+	if rc.Rewrite != "" && !strings.HasPrefix(rc.Rewrite, "/") {
+		return nil, errors.New("rewrite must start with '/'")
+	}
+	if rc.Timeout < 0 {
+		return nil, errors.New("timeout must not be negative")
+	}
+	if len(rc.Upstreams) == 0 {
+		return nil, errors.New("at least one upstream is required")
+	}
+
+	switch rc.Balancing {
+	case "":
+		rc.Balancing = RoundRobin
+	case RoundRobin, LeastConnections:
+	default:
+		return nil, fmt.Errorf("unknown balancing %q", rc.Balancing)
+	}
+
+	if rc.HealthCheck != nil {
+		if err := rc.HealthCheck.setDefaults(); err != nil {
+			return nil, err
+		}
+	}
+
+	if rc.Breaker != nil {
+		if err := rc.Breaker.setDefaults(); err != nil {
+			return nil, err
+		}
+	}
+
+	retry := RetryPolicy{Attempts: 1}
+	if rc.Retry != nil {
+		if err := rc.Retry.setDefaults(); err != nil {
+			return nil, err
+		}
+		retry = *rc.Retry
+	}
+
+	var limit *ratelimit.Limit
+	if rc.RateLimit != nil {
+		l, err := rc.RateLimit.limit()
+		if err != nil {
+			return nil, err
+		}
+		limit = &l
+	}
+
+	if rc.Cache != nil && rc.Cache.TTL <= 0 {
+		return nil, errors.New("cache ttl must be positive")
+	}
+
+	upstreams := make([]*Upstream, 0, len(rc.Upstreams))
+	for _, u := range rc.Upstreams {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return nil, fmt.Errorf("upstream %q: %w", u, err)
+		}
+		if parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("upstream %q must be an absolute URL", u)
+		}
+		upstreams = append(upstreams, newUpstream(parsed))
+	}
+
+	for _, rule := range rc.Auth {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	prefix := rc.Prefix
+	if prefix != "/" {
+		prefix = strings.TrimSuffix(prefix, "/")
+	}
+
+	return &Route{
+		Prefix:        prefix,
+		Upstreams:     upstreams,
+		Balancing:     rc.Balancing,
+		HealthCheck:   rc.HealthCheck,
+		Breaker:       rc.Breaker,
+		Retry:         retry,
+		RateLimit:     limit,
+		Cache:         rc.Cache,
+		StripPrefix:   rc.StripPrefix,
+		Rewrite:       rc.Rewrite,
+		Timeout:       rc.Timeout,
+		Policies:      rc.Auth,
+		defaultAccess: defaultAccess,
+	}, nil
+}
+
+// checkSharedUpstreams запрещает разные health_check и circuit_breaker для
+// одного экземпляра: его состояние общее для всех маршрутов, где он указан
+func checkSharedUpstreams(routes []*Route) error {
+	checks := make(map[string]*Route)
+	breakers := make(map[string]*Route)
+
+	for _, route := range routes {
+		for _, u := range route.Upstreams {
+			key := u.URL.String()
+
+			if route.HealthCheck != nil {
+				if other, ok := checks[key]; !ok {
+					checks[key] = route
+				} else if *other.HealthCheck != *route.HealthCheck {
+					return fmt.Errorf("upstream %q has different health_check in routes %s and %s", key, other.Prefix, route.Prefix)
+				}
+			}
+
+			if route.Breaker != nil {
+				if other, ok := breakers[key]; !ok {
+					breakers[key] = route
+				} else if *other.Breaker != *route.Breaker {
+					return fmt.Errorf("upstream %q has different circuit_breaker in routes %s and %s", key, other.Prefix, route.Prefix)
+				}
+			}
+		}
+	}
+
+	return nil
+}
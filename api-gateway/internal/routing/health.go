@@ -0,0 +1,129 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// healthTick — как часто проверяется, не пора ли опросить экземпляры;
+// интервал каждого экземпляра задаётся в health_check
+const healthTick = time.Second
+
+// CheckHealth опрашивает экземпляры маршрутов с health_check, пока не
+// отменён ctx. Экземпляр исключается из балансировки после
+// unhealthy_threshold неудач подряд и возвращается после healthy_threshold
+// успехов подряд.
+func (s *Store) CheckHealth(ctx context.Context, client *http.Client) {
+	ticker := time.NewTicker(healthTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, u := range s.upstreamList() {
+				if check, ok := u.startCheck(now); ok {
+					go s.probe(ctx, client, u, check)
+				}
+			}
+		}
+	}
+}
+
+// startCheck помечает экземпляр как проверяемый, если проверка настроена,
+// подошло её время и предыдущая уже закончилась
+func (u *Upstream) startCheck(now time.Time) (HealthCheck, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.check == nil || u.checking || now.Before(u.nextCheck) {
+		return HealthCheck{}, false
+	}
+	u.checking = true
+	return *u.check, true
+}
+
+func (s *Store) probe(ctx context.Context, client *http.Client, u *Upstream, check HealthCheck) {
+	err := probeUpstream(ctx, client, u, check)
+	if ctx.Err() != nil {
+		return
+	}
+
+	changed, healthy := u.record(time.Now(), check, err)
+	if !changed {
+		return
+	}
+
+	if healthy {
+		s.log.Info("Upstream is healthy again", slog.String("upstream", u.URL.String()))
+	} else {
+		s.log.Warn("Upstream ejected after failed health checks",
+			slog.String("upstream", u.URL.String()),
+			slog.Any("error", err))
+	}
+}
+
+func probeUpstream(ctx context.Context, client *http.Client, u *Upstream, check HealthCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	target := strings.TrimSuffix(u.URL.String(), "/") + check.Path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// record учитывает результат проверки и сообщает, изменилось ли состояние
+func (u *Upstream) record(now time.Time, check HealthCheck, err error) (changed, healthy bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.checking = false
+	// Проверку могли отключить перезагрузкой, пока шёл запрос
+	if u.check == nil {
+		return false, u.Healthy()
+	}
+
+	u.lastCheck = now
+	u.nextCheck = now.Add(check.Interval)
+
+	wasHealthy := u.Healthy()
+	if err != nil {
+		u.lastError = err.Error()
+		u.successes = 0
+		u.failures++
+		if wasHealthy && u.failures >= check.UnhealthyThreshold {
+			u.healthy.Store(false)
+			return true, false
+		}
+		return false, wasHealthy
+	}
+
+	u.lastError = ""
+	u.failures = 0
+	u.successes++
+	if !wasHealthy && u.successes >= check.HealthyThreshold {
+		u.healthy.Store(true)
+		return true, true
+	}
+	return false, wasHealthy
+}
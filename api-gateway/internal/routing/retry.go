@@ -0,0 +1,87 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// RetryPolicy — повторы запроса к другому (или тому же) экземпляру после
+// ошибки соединения, тайм-аута или ответа 502/503/504. Повторяются только
+// идемпотентные методы.
+type RetryPolicy struct {
+	// Attempts — общее число попыток, включая первую
+	Attempts   int           `yaml:"attempts"`
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	// PerTryTimeout ограничивает одну попытку; timeout маршрута — весь
+	// запрос вместе с повторами
+	PerTryTimeout time.Duration `yaml:"per_try_timeout"`
+	Methods       []string      `yaml:"methods"`
+}
+
+var idempotentMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodOptions,
+	http.MethodTrace, http.MethodPut, http.MethodDelete,
+}
+
+const (
+	defaultRetryBackoff    = 50 * time.Millisecond
+	defaultRetryMaxBackoff = time.Second
+	// maxRetryAttempts — верхняя граница, чтобы повторы не умножали
+	// нагрузку на упавший сервис
+	maxRetryAttempts = 5
+)
+
+func (p *RetryPolicy) setDefaults() error {
+	if p.Attempts < 0 || p.Backoff < 0 || p.MaxBackoff < 0 || p.PerTryTimeout < 0 {
+		return errors.New("retry values must not be negative")
+	}
+	if p.Attempts == 0 {
+		p.Attempts = 1
+	}
+	if p.Attempts > maxRetryAttempts {
+		return fmt.Errorf("retry attempts must not exceed %d", maxRetryAttempts)
+	}
+	if p.Backoff == 0 {
+		p.Backoff = defaultRetryBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = defaultRetryMaxBackoff
+	}
+	if len(p.Methods) == 0 {
+		p.Methods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+	}
+	for _, m := range p.Methods {
+		if !slices.Contains(idempotentMethods, m) {
+			return fmt.Errorf("retry method %q is not idempotent", m)
+		}
+	}
+	return nil
+}
+
+// AttemptsFor возвращает число попыток для метода
+func (p RetryPolicy) AttemptsFor(method string) int {
+	if p.Attempts <= 1 || !slices.Contains(p.Methods, method) {
+		return 1
+	}
+	return p.Attempts
+}
+
+// Delay — пауза перед повтором retry (с 1): экспоненциальная с полным
+// джиттером, чтобы повторы от разных запросов не приходили разом
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	ceiling := p.MaxBackoff
+	if shift := retry - 1; shift < 32 {
+		if d := p.Backoff << shift; d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
@@ -0,0 +1,156 @@
+package routing
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Store хранит текущую таблицу маршрутов и перезагружает её из файла
+type Store struct {
+	log   *slog.Logger
+	path  string
+	table atomic.Pointer[Table]
+
+	modTime time.Time
+
+	mu        sync.Mutex
+	upstreams map[string]*Upstream
+}
+
+// NewStore загружает таблицу из файла; ошибка означает, что шлюз не может
+// стартовать без маршрутов
+func NewStore(log *slog.Logger, path string) (*Store, error) {
+	s := &Store{log: log, path: path, upstreams: make(map[string]*Upstream)}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Table возвращает текущий снимок таблицы
+func (s *Store) Table() *Table {
+	return s.table.Load()
+}
+
+// Reload перечитывает файл. При ошибке остаётся прежняя таблица.
+func (s *Store) Reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	// Неверный файл не перечитывается на каждой проверке, только после
+	// следующего изменения
+	s.modTime = info.ModTime()
+
+	table, err := LoadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.adopt(table)
+	s.table.Store(table)
+
+	s.log.Info("Routes loaded",
+		slog.String("file", s.path),
+		slog.Int("routes", len(table.routes)))
+
+	return nil
+}
+
+// Watch перезагружает маршруты при изменении файла (проверка раз в
+// interval) и по сигналу SIGHUP, пока не отменён ctx
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			s.log.Info("SIGHUP received, reloading routes")
+			s.reload()
+		case <-ticker.C:
+			info, err := os.Stat(s.path)
+			if err != nil {
+				s.log.Warn("Failed to stat routes file", slog.Any("error", err))
+				continue
+			}
+			if info.ModTime().Equal(s.modTime) {
+				continue
+			}
+			s.log.Info("Routes file changed, reloading")
+			s.reload()
+		}
+	}
+}
+
+func (s *Store) reload() {
+	if err := s.Reload(); err != nil {
+		s.log.Error("Failed to reload routes, keeping previous table", slog.Any("error", err))
+	}
+}
+
+// adopt заменяет экземпляры новой таблицы уже известными с тем же URL,
+// чтобы перезагрузка не сбрасывала результаты проверок и счётчики запросов.
+// Экземпляры, которых нет в новой таблице, перестают проверяться.
+func (s *Store) adopt(table *Table) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upstreams := make(map[string]*Upstream, len(s.upstreams))
+	checks := make(map[string]*HealthCheck)
+	breakers := make(map[string]*CircuitBreaker)
+
+	for _, route := range table.routes {
+		for i, u := range route.Upstreams {
+			key := u.URL.String()
+
+			if known, ok := upstreams[key]; ok {
+				route.Upstreams[i] = known
+			} else if known, ok := s.upstreams[key]; ok {
+				route.Upstreams[i] = known
+				upstreams[key] = known
+			} else {
+				upstreams[key] = u
+			}
+
+			if route.HealthCheck != nil {
+				checks[key] = route.HealthCheck
+			}
+			if route.Breaker != nil {
+				breakers[key] = route.Breaker
+			}
+		}
+	}
+
+	for key, u := range upstreams {
+		u.setCheck(checks[key])
+		u.breaker.configure(breakers[key])
+	}
+
+	s.upstreams = upstreams
+}
+
+func (s *Store) upstreamList() []*Upstream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Upstream, 0, len(s.upstreams))
+	for _, u := range s.upstreams {
+		list = append(list, u)
+	}
+	return list
+}
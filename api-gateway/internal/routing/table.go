@@ -0,0 +1,119 @@
+package routing
+
+import (
+	"api-gateway/internal/lib/policy"
+	"api-gateway/internal/lib/ratelimit"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Route — маршрут, готовый к проксированию
+type Route struct {
+	Prefix      string
+	Upstreams   []*Upstream
+	Balancing   Balancing
+	HealthCheck *HealthCheck
+	Breaker     *CircuitBreaker
+	Retry       RetryPolicy
+	// RateLimit равен nil, если маршрут не ограничен
+	RateLimit *ratelimit.Limit
+	// Cache равен nil, если ответы маршрута не кэшируются
+	Cache       *CachePolicy
+	StripPrefix bool
+	Rewrite     string
+	Timeout     time.Duration
+	Policies    []policy.Rule
+
+	defaultAccess policy.Access
+	next          atomic.Uint64
+}
+
+// CachePolicy — кэширование GET-ответов маршрута: ttl, заголовки запроса,
+// от которых зависит ответ, и топики событий, сбрасывающие записи
+type CachePolicy struct {
+	TTL          time.Duration `yaml:"ttl"`
+	Vary         []string      `yaml:"vary"`
+	InvalidateOn []string      `yaml:"invalidate_on"`
+}
+
+// Table — неизменяемый снимок таблицы маршрутов. При перезагрузке
+// создаётся новая таблица, запросы в обработке дорабатывают со старой.
+type Table struct {
+	routes []*Route
+}
+
+func newTable(routes []*Route) *Table {
+	sorted := make([]*Route, len(routes))
+	copy(sorted, routes)
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+	})
+
+	return &Table{routes: sorted}
+}
+
+// Match возвращает маршрут с самым длинным префиксом, совпадающим
+// с путём по границе сегмента: /products не совпадает с /productsX
+func (t *Table) Match(path string) (*Route, bool) {
+	for _, r := range t.routes {
+		if hasPathPrefix(path, r.Prefix) {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+func (t *Table) Routes() []*Route {
+	return t.routes
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	if prefix == "/" {
+		return strings.HasPrefix(path, "/")
+	}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
+
+// TargetPath возвращает путь запроса к сервису с учётом strip_prefix и rewrite
+func (r *Route) TargetPath(path string) string {
+	if r.Rewrite == "" && !r.StripPrefix {
+		return path
+	}
+
+	rest := strings.TrimPrefix(path, strings.TrimSuffix(r.Prefix, "/"))
+
+	base := "/"
+	if r.Rewrite != "" {
+		base = r.Rewrite
+	}
+
+	joined := strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(rest, "/")
+	if rest == "" && !strings.HasSuffix(base, "/") {
+		joined = strings.TrimSuffix(joined, "/")
+	}
+	if joined == "" {
+		joined = "/"
+	}
+
+	return joined
+}
+
+// Upstream выбирает экземпляр сервиса согласно балансировке маршрута,
+// для повтора — по возможности не из tried. false означает, что все
+// экземпляры исключены проверками или автоматами защиты.
+func (r *Route) Upstream(tried ...*Upstream) (*Upstream, bool) {
+	return pick(r.Upstreams, r.Balancing, &r.next, tried)
+}
+
+// Rule возвращает правило доступа для метода
+func (r *Route) Rule(method string) policy.Rule {
+	return policy.Select(r.Policies, method, r.defaultAccess)
+}
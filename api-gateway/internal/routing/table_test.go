@@ -0,0 +1,72 @@
+package routing
+
+import "testing"
+
+func TestTableMatch(t *testing.T) {
+	table := newTable([]*Route{
+		{Prefix: "/"},
+		{Prefix: "/products"},
+		{Prefix: "/products/search/"},
+		{Prefix: "/product"},
+	})
+
+	tests := []struct {
+		path   string
+		prefix string
+	}{
+		{path: "/products", prefix: "/products"},
+		{path: "/products/", prefix: "/products"},
+		{path: "/products/42", prefix: "/products"},
+		{path: "/products/search", prefix: "/products/search/"},
+		{path: "/products/searchX", prefix: "/products"},
+		{path: "/productsX", prefix: "/"},
+		{path: "/product/", prefix: "/product"},
+		{path: "/productX", prefix: "/"},
+		{path: "/", prefix: "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			route, ok := table.Match(tt.path)
+			if !ok {
+				t.Fatalf("no route for %s", tt.path)
+			}
+			if route.Prefix != tt.prefix {
+				t.Fatalf("matched %s, want %s", route.Prefix, tt.prefix)
+			}
+		})
+	}
+
+	if _, ok := newTable([]*Route{{Prefix: "/products"}}).Match("/productsX"); ok {
+		t.Fatal("/products matched /productsX")
+	}
+	if _, ok := newTable([]*Route{{Prefix: "/products"}}).Match("products"); ok {
+		t.Fatal("matched a path without leading slash")
+	}
+}
+
+func TestRouteTargetPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		route *Route
+		path  string
+		want  string
+	}{
+		{name: "as is", route: &Route{Prefix: "/products"}, path: "/products/42", want: "/products/42"},
+		{name: "strip prefix", route: &Route{Prefix: "/api", StripPrefix: true}, path: "/api/products/", want: "/products/"},
+		{name: "strip prefix with slash", route: &Route{Prefix: "/api/", StripPrefix: true}, path: "/api/products", want: "/products"},
+		{name: "strip whole path", route: &Route{Prefix: "/api", StripPrefix: true}, path: "/api", want: "/"},
+		{name: "rewrite", route: &Route{Prefix: "/shop", Rewrite: "/products"}, path: "/shop/42", want: "/products/42"},
+		{name: "rewrite whole path", route: &Route{Prefix: "/shop", Rewrite: "/products"}, path: "/shop", want: "/products"},
+		{name: "rewrite keeps trailing slash", route: &Route{Prefix: "/shop", Rewrite: "/products/"}, path: "/shop", want: "/products/"},
+		{name: "rewrite to root", route: &Route{Prefix: "/shop", Rewrite: "/"}, path: "/shop/42", want: "/42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.route.TargetPath(tt.path); got != tt.want {
+				t.Fatalf("TargetPath(%s) = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package routing
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balancing — способ выбора экземпляра сервиса
+type Balancing string
+
+const (
+	RoundRobin       Balancing = "round_robin"
+	LeastConnections Balancing = "least_conn"
+)
+
+// HealthCheck — настройки активной проверки экземпляров маршрута
+type HealthCheck struct {
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+	// UnhealthyThreshold — сколько неудачных проверок подряд исключают
+	// экземпляр, HealthyThreshold — сколько удачных возвращают его
+	UnhealthyThreshold int `yaml:"unhealthy_threshold"`
+	HealthyThreshold   int `yaml:"healthy_threshold"`
+}
+
+const (
+	defaultCheckInterval      = 10 * time.Second
+	defaultCheckTimeout       = 2 * time.Second
+	defaultUnhealthyThreshold = 3
+	defaultHealthyThreshold   = 2
+)
+
+func (h *HealthCheck) setDefaults() error {
+	if h.Path == "" {
+		h.Path = "/"
+	}
+	if h.Path[0] != '/' {
+		return errors.New("health_check path must start with '/'")
+	}
+	if h.Interval == 0 {
+		h.Interval = defaultCheckInterval
+	}
+	if h.Timeout == 0 {
+		h.Timeout = defaultCheckTimeout
+	}
+	if h.Interval < 0 || h.Timeout < 0 {
+		return errors.New("health_check interval and timeout must not be negative")
+	}
+	if h.UnhealthyThreshold <= 0 {
+		h.UnhealthyThreshold = defaultUnhealthyThreshold
+	}
+	if h.HealthyThreshold <= 0 {
+		h.HealthyThreshold = defaultHealthyThreshold
+	}
+	return nil
+}
+
+// Upstream — экземпляр сервиса. Состояние экземпляра переживает
+// перезагрузку маршрутов: Store переиспользует его по URL.
+type Upstream struct {
+	URL *url.URL
+
+	healthy atomic.Bool
+	active  atomic.Int64
+	breaker *breaker
+
+	mu        sync.Mutex
+	check     *HealthCheck
+	checking  bool
+	nextCheck time.Time
+	successes int
+	failures  int
+	lastCheck time.Time
+	lastError string
+}
+
+func newUpstream(u *url.URL) *Upstream {
+	up := &Upstream{URL: u, breaker: newBreaker()}
+	// До первой проверки экземпляр считается доступным, иначе шлюз
+	// отвечал бы 503 сразу после старта
+	up.healthy.Store(true)
+	return up
+}
+
+// setCheck меняет настройки проверки. Экземпляр без проверки всегда
+// считается доступным.
+func (u *Upstream) setCheck(check *HealthCheck) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if check == nil {
+		u.check = nil
+		u.successes, u.failures = 0, 0
+		u.lastError = ""
+		u.healthy.Store(true)
+		return
+	}
+
+	if u.check == nil || *u.check != *check {
+		u.nextCheck = time.Time{}
+	}
+	c := *check
+	u.check = &c
+}
+
+// Healthy сообщает, принимает ли экземпляр запросы
+func (u *Upstream) Healthy() bool {
+	return u.healthy.Load()
+}
+
+// Active — число запросов к экземпляру, которые сейчас в обработке
+func (u *Upstream) Active() int64 {
+	return u.active.Load()
+}
+
+// Circuit — состояние автомата защиты экземпляра
+func (u *Upstream) Circuit() CircuitState {
+	return u.breaker.current()
+}
+
+// available сообщает, можно ли выбрать экземпляр для запроса
+func (u *Upstream) available() bool {
+	return u.Healthy() && u.breaker.ready()
+}
+
+// Begin занимает экземпляр под попытку запроса. false означает, что
+// автомат защиты не пропускает запрос; попытку нужно завершить Finish.
+func (u *Upstream) Begin() (*Attempt, bool) {
+	if !u.breaker.allow() {
+		return nil, false
+	}
+	u.active.Add(1)
+	return &Attempt{upstream: u}, true
+}
+
+// Attempt — попытка запроса к экземпляру
+type Attempt struct {
+	upstream *Upstream
+	finished bool
+}
+
+// Finish учитывает итог попытки; changed сообщает, что автомат защиты
+// сменил состояние на state
+func (a *Attempt) Finish(result Result) (state CircuitState, changed bool) {
+	if a.finished {
+		return a.upstream.Circuit(), false
+	}
+	a.finished = true
+	a.upstream.active.Add(-1)
+
+	return a.upstream.breaker.record(result)
+}
+
+// UpstreamStatus — состояние экземпляра для страницы статуса
+type UpstreamStatus struct {
+	URL       string     `json:"url"`
+	Healthy   bool       `json:"healthy"`
+	Active    int64      `json:"active_requests"`
+	Checked   bool       `json:"health_checked"`
+	Circuit   string     `json:"circuit"`
+	LastCheck *time.Time `json:"last_check,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+func (u *Upstream) Status() UpstreamStatus {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	status := UpstreamStatus{
+		URL:       u.URL.String(),
+		Healthy:   u.Healthy(),
+		Active:    u.Active(),
+		Checked:   u.check != nil,
+		Circuit:   string(u.Circuit()),
+		LastError: u.lastError,
+	}
+	if !u.lastCheck.IsZero() {
+		lastCheck := u.lastCheck
+		status.LastCheck = &lastCheck
+	}
+
+	return status
+}
+
+// pick выбирает доступный экземпляр, по возможности не из tried;
+// false означает, что доступных нет. Обход начинается со сдвига счётчика,
+// поэтому и при least_conn равно загруженные экземпляры получают запросы
+// по очереди.
+func pick(upstreams []*Upstream, balancing Balancing, counter *atomic.Uint64, tried []*Upstream) (*Upstream, bool) {
+	n := uint64(len(upstreams))
+	start := counter.Add(1) - 1
+
+	var best, fallback *Upstream
+	for i := uint64(0); i < n; i++ {
+		u := upstreams[(start+i)%n]
+		if !u.available() {
+			continue
+		}
+		if slices.Contains(tried, u) {
+			if fallback == nil {
+				fallback = u
+			}
+			continue
+		}
+		if balancing != LeastConnections {
+			return u, true
+		}
+		if best == nil || u.Active() < best.Active() {
+			best = u
+		}
+	}
+
+	if best == nil {
+		best = fallback
+	}
+	return best, best != nil
+}
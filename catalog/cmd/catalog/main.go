@@ -3,7 +3,9 @@ package main
 import (
 	"catalog/internal/app"
 	"catalog/internal/config"
+	"catalog/internal/reindex"
 	"context"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
@@ -20,6 +22,14 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		if err := runReindex(ctx, log, cfg, os.Args[2:]); err != nil {
+			log.Error(fmt.Errorf("reindex failed: %w", err).Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	application := app.NewApp(log, cfg)
 	go func() {
 		if err := application.Run(); err != nil {
@@ -41,6 +51,21 @@ func main() {
 
 }
 
+// runReindex выполняет подкоманду `catalog reindex [-batch-size N] [-delete-old]`
+func runReindex(ctx context.Context, log *slog.Logger, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	batchSize := fs.Int("batch-size", 1000, "documents per bulk request")
+	deleteOld := fs.Bool("delete-old", false, "delete indices previously behind the alias")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return app.Reindex(ctx, log, cfg, reindex.Options{
+		BatchSize: *batchSize,
+		DeleteOld: *deleteOld,
+	})
+}
+
 // setupLogger инициализирует логгер
 func setupLogger() *slog.Logger {
 
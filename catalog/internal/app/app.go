@@ -1,13 +1,18 @@
 package app
 
 import (
+	"catalog/internal/category"
 	"catalog/internal/comment"
 	"catalog/internal/config"
 	"catalog/internal/database/postgresql"
 	"catalog/internal/kafka"
+	"catalog/internal/lib/tracing"
 	m "catalog/internal/middleware"
+	"catalog/internal/outbox"
 	"catalog/internal/product"
+	"catalog/internal/search"
 	"context"
+	"events"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -18,6 +23,7 @@ import (
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // type test struct {
@@ -32,9 +38,18 @@ type App struct {
 	log        *slog.Logger
 	storage    *postgresql.Storage
 	broker     *kafka.KafkaProducer
+	relay      *outbox.Relay
+
+	shutdownTracing func(context.Context) error
 }
 
 func NewApp(log *slog.Logger, cfg *config.Config) *App {
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Error("Failed to set up tracing", slog.Any("error", err))
+		os.Exit(1)
+	}
+
 	storage, err := postgresql.ConnectAndNew(log, &cfg.Database)
 	if err != nil {
 		log.Error("Failed to create DB:")
@@ -42,39 +57,45 @@ func NewApp(log *slog.Logger, cfg *config.Config) *App {
 	}
 	brokers := []string{"kafka:9092"}
 
-	producer := kafka.NewKafkaProducer(brokers)
-	// producer := &test{}
-
-	esHost := cfg.Elasticsearch.Host
-	if esHost == "" {
-		esHost = "elasticsearch:9200"
-	}
-	log.Info("Configuring Elasticsearch client", slog.String("host", esHost))
-
-	esConfig := elasticsearch.Config{
-		Addresses: []string{fmt.Sprintf("http://%s", esHost)},
-	}
-
-	esClient, err := elasticsearch.NewClient(esConfig)
+	eventFormat, err := events.FormatByName(cfg.Kafka.EventFormat)
 	if err != nil {
-		log.Error("Failed to create Elasticsearch client", "error", err)
+		log.Error("Failed to select event format", slog.Any("error", err))
+		os.Exit(1)
 	}
+	producer := kafka.NewKafkaProducer(brokers, eventFormat)
+	// producer := &test{}
 
-	productRepo := product.NewRepository(storage.DB, esClient)
+	searchClient := newSearchClient(log, cfg)
+
+	productRepo := product.NewRepository(storage.DB, searchClient, cfg.Elasticsearch.ProductsIndex)
 
 	productViewUsecase := product.NewUseacase(log, productRepo, producer)
 	productAddUsecase := product.NewAddUseacase(log, productRepo, producer)
-	commentRepo := comment.NewRepository(storage.DB)
+	productEditUsecase := product.NewEditUseacase(log, productRepo, producer)
+	commentRepo := comment.NewRepository(storage.DB, searchClient, cfg.Elasticsearch.CommentsIndex)
 	commentCreateUsecase := comment.NewCreateUseacase(log, commentRepo, producer)
 	commentViewUsecase := comment.NewViewUseacase(log, commentRepo, producer)
+	commentEditUsecase := comment.NewEditUseacase(log, commentRepo, producer)
 
 	productSearch := product.NewSearchUseacase(log, productRepo, producer)
 
+	categoryRepo := category.NewRepository(storage.DB)
+	categoryUsecase := category.NewUseacase(log, categoryRepo)
+
+	relay := outbox.NewRelay(log, outbox.NewRepository(storage.DB), producer, outbox.RelayConfig{
+		PollInterval: cfg.Outbox.PollInterval,
+		BatchSize:    cfg.Outbox.BatchSize,
+		MaxAttempts:  cfg.Outbox.MaxAttempts,
+		MaxBackoff:   cfg.Outbox.MaxBackoff,
+	})
+
 	router := chi.NewRouter()
+	// X-Request-Id приходит от шлюза и попадает в логи запроса
+	router.Use(middleware.RequestID)
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 	router.Use(m.MetricsMiddleware)
-	router.Use(m.HeaderAuth(log))
+	router.Use(m.HeaderAuth(log, cfg.Secret))
 
 	router.Use(m.LogEventMiddleware(log, producer))
 	router.Handle("/metrics", promhttp.Handler())
@@ -82,13 +103,25 @@ func NewApp(log *slog.Logger, cfg *config.Config) *App {
 	router.Get("/products/", product.ViewListProducts(log, productViewUsecase))
 	router.Get("/product/", product.ViewProduct(log, productViewUsecase))
 	router.Post("/product/", product.AddProduct(log, productAddUsecase))
+	router.Put("/product/", product.UpdateProduct(log, productEditUsecase))
+	router.Patch("/product/", product.UpdateProduct(log, productEditUsecase))
+	router.Delete("/product/", product.DeleteProduct(log, productEditUsecase))
 	router.Post("/comment/", comment.CreateComment(log, commentCreateUsecase))
+	router.Put("/comment/", comment.UpdateComment(log, commentEditUsecase))
+	router.Delete("/comment/", comment.DeleteComment(log, commentEditUsecase))
 	router.Get("/comments/", comment.ViewCommentInProduct(log, commentViewUsecase))
+	router.Get("/comments/search/", comment.SearchComments(log, commentViewUsecase))
 	router.Get("/search/", product.SearchProduct(log, productSearch))
+	router.Get("/search/suggest/", product.SuggestProduct(log, productSearch))
+	router.Get("/categories/", category.ViewCategoryTree(log, categoryUsecase))
+	router.Get("/category/", category.ViewCategory(log, categoryUsecase))
+	router.Post("/category/", category.CreateCategory(log, categoryUsecase))
+	router.Put("/category/", category.UpdateCategory(log, categoryUsecase))
+	router.Delete("/category/", category.DeleteCategory(log, categoryUsecase))
 
 	srv := &http.Server{
 		Addr:         fmt.Sprintf("0.0.0.0:%s", cfg.ServerPort),
-		Handler:      router,
+		Handler:      otelhttp.NewHandler(router, "catalog", otelhttp.WithSpanNameFormatter(spanName)),
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  15 * time.Second,
@@ -99,12 +132,46 @@ func NewApp(log *slog.Logger, cfg *config.Config) *App {
 		log:        log,
 		storage:    storage,
 		broker:     producer,
+		relay:      relay,
+
+		shutdownTracing: shutdownTracing,
+	}
+}
+
+func spanName(_ string, r *http.Request) string {
+	return r.Method + " " + r.URL.Path
+}
+
+func newSearchClient(log *slog.Logger, cfg *config.Config) *search.Client {
+	esHost := cfg.Elasticsearch.Host
+	if esHost == "" {
+		esHost = "elasticsearch:9200"
+	}
+	log.Info("Configuring Elasticsearch client", slog.String("host", esHost))
+
+	esConfig := elasticsearch.Config{
+		Addresses: []string{fmt.Sprintf("http://%s", esHost)},
+		// Запросы к Elasticsearch — дочерние спаны запроса к каталогу
+		Transport: otelhttp.NewTransport(http.DefaultTransport,
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return "elasticsearch " + r.Method + " " + r.URL.Path
+			})),
+	}
+
+	esClient, err := elasticsearch.NewClient(esConfig)
+	if err != nil {
+		log.Error("Failed to create Elasticsearch client", "error", err)
 	}
+
+	return search.NewClient(esClient)
 }
 
 func (a *App) Run() error {
 	a.log.Info("Starting server ", slog.String("port", a.httpServer.Addr))
 
+	a.relay.Start()
+	a.log.Info("Outbox relay started")
+
 	return a.httpServer.ListenAndServe()
 }
 
@@ -115,11 +182,18 @@ func (a *App) Shutdown(ctx context.Context) error {
 		return err
 	}
 
+	a.relay.Stop()
+	a.log.Info("Outbox relay stopped.")
+
 	a.storage.Stop()
 	a.log.Info("Database connection closed.")
 
 	a.broker.Close()
 	a.log.Info("Broker connection closed.")
 
+	if err := a.shutdownTracing(ctx); err != nil {
+		a.log.Error("Failed to flush traces", slog.Any("error", err))
+	}
+
 	return nil
 }
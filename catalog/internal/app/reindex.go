@@ -0,0 +1,25 @@
+package app
+
+import (
+	"catalog/internal/config"
+	"catalog/internal/database/postgresql"
+	"catalog/internal/product"
+	"catalog/internal/reindex"
+	"context"
+	"log/slog"
+)
+
+// Reindex перестраивает индекс товаров из Postgres и переключает на него
+// алиас ELASTICSEARCH_PRODUCTS_INDEX
+func Reindex(ctx context.Context, log *slog.Logger, cfg *config.Config, opts reindex.Options) error {
+	storage, err := postgresql.ConnectAndNew(log, &cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer storage.Stop()
+
+	searchClient := newSearchClient(log, cfg)
+	productRepo := product.NewRepository(storage.DB, searchClient, cfg.Elasticsearch.ProductsIndex)
+
+	return reindex.NewReindexer(log, productRepo, searchClient, cfg.Elasticsearch.ProductsIndex).Run(ctx, opts)
+}
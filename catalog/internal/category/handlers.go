@@ -0,0 +1,277 @@
+package category
+
+import (
+	"catalog/internal/lib/handlers/response"
+	"catalog/internal/middleware"
+	"catalog/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	errCreateCategory     = "failed to create category"
+	successCreateCategory = "create category successfully"
+	errUpdateCategory     = "failed to update category"
+	successUpdateCategory = "update category successfully"
+	errDeleteCategory     = "failed to delete category"
+	successDeleteCategory = "delete category successfully"
+
+	messageUnauthorized = "Unauthorized"
+	messageForbidden    = "only admins can manage categories"
+)
+
+type CategoryTreeViewer interface {
+	ViewCategoryTree(context.Context) ([]*models.Category, error)
+}
+
+func ViewCategoryTree(log *slog.Logger, categoryTreeViewer CategoryTreeViewer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "category.handlers.ViewCategoryTree"
+
+		categories, err := categoryTreeViewer.ViewCategoryTree(r.Context())
+		if err != nil {
+			log.Error(op+": failed to get categories", slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusInternalServerError, "failed to get categories")
+			return
+		}
+
+		if categories == nil {
+			categories = []*models.Category{}
+		}
+
+		response.RespondWithJSON(w, log, http.StatusOK, categories)
+	}
+}
+
+type CategoryViewer interface {
+	ViewCategory(context.Context, string) (*models.Category, error)
+}
+
+func ViewCategory(log *slog.Logger, categoryViewer CategoryViewer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "category.handlers.ViewCategory"
+
+		idStr := r.URL.Query().Get("id")
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			log.Warn(op+": invalid UUID", slog.String("id", idStr), slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'id' parameter")
+			return
+		}
+
+		category, err := categoryViewer.ViewCategory(r.Context(), id.String())
+		if err != nil {
+			log.Error(op+": failed to get category", slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusInternalServerError, "failed to get category")
+			return
+		}
+
+		if category == nil {
+			response.RespondWithError(w, log, http.StatusNotFound, "category not found")
+			return
+		}
+
+		response.RespondWithJSON(w, log, http.StatusOK, category)
+	}
+}
+
+type RequestCategory struct {
+	ID       string  `json:"id,omitempty"`
+	Name     string  `json:"name"`
+	ParentID *string `json:"parent_id"`
+}
+
+type ResponseCreateCategory struct {
+	ID string `json:"id"`
+}
+
+type CategoryCreator interface {
+	CreateCategory(context.Context, string, *string) (uuid.UUID, error)
+}
+
+func CreateCategory(log *slog.Logger, categoryCreator CategoryCreator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "category.handlers.CreateCategory"
+
+		log := log.With(
+			slog.String("op", op),
+		)
+
+		if _, ok := requireAdmin(w, r, log); !ok {
+			return
+		}
+
+		var req RequestCategory
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error(fmt.Errorf("failed to decode request body: Error: %w", err).Error())
+
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid request")
+			return
+		}
+
+		if msg, ok := validateRequest(&req); !ok {
+			response.RespondWithError(w, log, http.StatusBadRequest, msg)
+			return
+		}
+
+		id, err := categoryCreator.CreateCategory(r.Context(), req.Name, req.ParentID)
+		if err != nil {
+			log.Error(fmt.Errorf("%s Error: %w", errCreateCategory, err).Error())
+			respondWithCategoryError(w, log, err, errCreateCategory)
+			return
+		}
+
+		log.Info(successCreateCategory, slog.String("id", id.String()))
+
+		response.RespondWithJSON(w, log, http.StatusCreated, ResponseCreateCategory{ID: id.String()})
+	}
+}
+
+type CategoryUpdater interface {
+	UpdateCategory(context.Context, models.Actor, string, string, *string) error
+}
+
+func UpdateCategory(log *slog.Logger, categoryUpdater CategoryUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "category.handlers.UpdateCategory"
+
+		log := log.With(
+			slog.String("op", op),
+		)
+
+		actor, ok := requireAdmin(w, r, log)
+		if !ok {
+			return
+		}
+
+		var req RequestCategory
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error(fmt.Errorf("failed to decode request body: Error: %w", err).Error())
+
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid request")
+			return
+		}
+
+		id, err := uuid.Parse(req.ID)
+		if err != nil {
+			log.Warn(op+": invalid UUID", slog.String("id", req.ID), slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'id' parameter")
+			return
+		}
+
+		if msg, ok := validateRequest(&req); !ok {
+			response.RespondWithError(w, log, http.StatusBadRequest, msg)
+			return
+		}
+
+		if err := categoryUpdater.UpdateCategory(r.Context(), actor, id.String(), req.Name, req.ParentID); err != nil {
+			log.Error(fmt.Errorf("%s Error: %w", errUpdateCategory, err).Error())
+			respondWithCategoryError(w, log, err, errUpdateCategory)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		log.Info(successUpdateCategory, slog.String("id", id.String()))
+	}
+}
+
+type CategoryDeleter interface {
+	DeleteCategory(context.Context, models.Actor, string) error
+}
+
+func DeleteCategory(log *slog.Logger, categoryDeleter CategoryDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "category.handlers.DeleteCategory"
+
+		log := log.With(
+			slog.String("op", op),
+		)
+
+		actor, ok := requireAdmin(w, r, log)
+		if !ok {
+			return
+		}
+
+		idStr := r.URL.Query().Get("id")
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			log.Warn(op+": invalid UUID", slog.String("id", idStr), slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'id' parameter")
+			return
+		}
+
+		if err := categoryDeleter.DeleteCategory(r.Context(), actor, id.String()); err != nil {
+			log.Error(fmt.Errorf("%s Error: %w", errDeleteCategory, err).Error())
+			respondWithCategoryError(w, log, err, errDeleteCategory)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+		log.Info(successDeleteCategory, slog.String("id", id.String()))
+	}
+}
+
+// requireAdmin пропускает только администраторов: без пользователя отвечает
+// 401, с другой ролью — 403
+func requireAdmin(w http.ResponseWriter, r *http.Request, log *slog.Logger) (models.Actor, bool) {
+	actor, ok := middleware.GetActor(r)
+	if !ok {
+		log.Warn("failed to get user from context")
+
+		response.RespondWithError(w, log, http.StatusUnauthorized, messageUnauthorized)
+		return models.Actor{}, false
+	}
+
+	if actor.Role != models.RoleAdmin {
+		log.Warn("category change by non-admin", slog.String("user_id", actor.UserID), slog.String("role", actor.Role))
+
+		response.RespondWithError(w, log, http.StatusForbidden, messageForbidden)
+		return models.Actor{}, false
+	}
+
+	return actor, true
+}
+
+func validateRequest(req *RequestCategory) (string, bool) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "invalid 'name' parameter", false
+	}
+
+	if req.ParentID != nil {
+		parentID, err := uuid.Parse(*req.ParentID)
+		if err != nil {
+			return "invalid 'parent_id' parameter", false
+		}
+		normalized := parentID.String()
+		req.ParentID = &normalized
+	}
+
+	return "", true
+}
+
+func respondWithCategoryError(w http.ResponseWriter, log *slog.Logger, err error, message string) {
+	switch {
+	case errors.Is(err, ErrCategoryNotFound):
+		response.RespondWithError(w, log, http.StatusNotFound, "category not found")
+	case errors.Is(err, ErrParentNotFound):
+		response.RespondWithError(w, log, http.StatusBadRequest, "parent category not found")
+	case errors.Is(err, ErrCategoryExists):
+		response.RespondWithError(w, log, http.StatusConflict, "category with this name already exists")
+	case errors.Is(err, ErrCategoryCycle):
+		response.RespondWithError(w, log, http.StatusBadRequest, "category cannot be moved under itself or its descendant")
+	case errors.Is(err, ErrHasChildren):
+		response.RespondWithError(w, log, http.StatusConflict, "category has subcategories")
+	default:
+		response.RespondWithError(w, log, http.StatusInternalServerError, message)
+	}
+}
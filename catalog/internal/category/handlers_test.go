@@ -0,0 +1,58 @@
+package category
+
+import (
+	"catalog/internal/middleware"
+	"catalog/internal/models"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeDeleter struct {
+	called bool
+}
+
+func (d *fakeDeleter) DeleteCategory(context.Context, models.Actor, string) error {
+	d.called = true
+	return nil
+}
+
+func TestDeleteCategoryRequiresAdmin(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name   string
+		role   string
+		anon   bool
+		status int
+	}{
+		{name: "anonymous", anon: true, status: http.StatusUnauthorized},
+		{name: "seller", role: "seller", status: http.StatusForbidden},
+		{name: "admin", role: models.RoleAdmin, status: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodDelete, "/category/?id=11111111-1111-1111-1111-111111111111", nil)
+			if !tt.anon {
+				ctx := context.WithValue(r.Context(), middleware.UserIDContextKey, "22222222-2222-2222-2222-222222222222")
+				ctx = context.WithValue(ctx, middleware.UserRoleContextKey, tt.role)
+				r = r.WithContext(ctx)
+			}
+			w := httptest.NewRecorder()
+			deleter := &fakeDeleter{}
+
+			DeleteCategory(log, deleter)(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if deleter.called != (tt.status == http.StatusNoContent) {
+				t.Errorf("deleter called = %v", deleter.called)
+			}
+		})
+	}
+}
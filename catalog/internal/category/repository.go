@@ -0,0 +1,308 @@
+package category
+
+import (
+	"catalog/internal/models"
+	"catalog/internal/outbox"
+	"context"
+	"database/sql"
+	"errors"
+	"events"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrParentNotFound   = errors.New("parent category not found")
+	ErrCategoryExists   = errors.New("category with this name already exists")
+	ErrCategoryCycle    = errors.New("category cannot be moved under itself or its descendant")
+	ErrHasChildren      = errors.New("category has subcategories")
+)
+
+const (
+	uniqueViolationCode = "23505"
+	productEventsTopic  = "product-events"
+)
+
+type CategoryRepository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+func (rep *CategoryRepository) ListCategories(ctx context.Context) ([]*models.Category, error) {
+	const query = `
+		SELECT id, name, parent_id
+		FROM categories
+		ORDER BY name, id
+	`
+
+	rows, err := rep.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("querying categories: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []*models.Category
+
+	for rows.Next() {
+		var (
+			c        models.Category
+			parentID sql.NullString
+		)
+		if err := rows.Scan(&c.ID, &c.Name, &parentID); err != nil {
+			return nil, fmt.Errorf("scanning category: %w", err)
+		}
+		if parentID.Valid {
+			c.ParentID = &parentID.String
+		}
+		categories = append(categories, &c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading rows: %w", err)
+	}
+
+	return categories, nil
+}
+
+func (rep *CategoryRepository) ViewCategory(ctx context.Context, id string) (*models.Category, error) {
+	const query = `SELECT id, name, parent_id FROM categories WHERE id = $1`
+
+	var (
+		c        models.Category
+		parentID sql.NullString
+	)
+
+	err := rep.db.QueryRowContext(ctx, query, id).Scan(&c.ID, &c.Name, &parentID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		c.ParentID = &parentID.String
+	}
+
+	return &c, nil
+}
+
+func (rep *CategoryRepository) CreateCategory(ctx context.Context, name string, parentID *string) (uuid.UUID, error) {
+	const op = "category.repository.CreateCategory"
+
+	if parentID != nil {
+		if err := rep.checkExists(ctx, rep.db, *parentID); err != nil {
+			if errors.Is(err, ErrCategoryNotFound) {
+				return uuid.Nil, fmt.Errorf("%s: %w", op, ErrParentNotFound)
+			}
+			return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	categoryID := uuid.New()
+
+	const queryInsert = `
+		INSERT INTO categories (id, name, parent_id)
+		VALUES ($1, $2, $3)
+	`
+
+	if _, err := rep.db.ExecContext(ctx, queryInsert, categoryID, name, parentID); err != nil {
+		if isUniqueViolation(err) {
+			return uuid.Nil, fmt.Errorf("%s: %w", op, ErrCategoryExists)
+		}
+		return uuid.Nil, fmt.Errorf("%s: failed to insert category: %w", op, err)
+	}
+
+	return categoryID, nil
+}
+
+// UpdateCategory изменяет категорию; при переименовании товары категории
+// получают новую версию и product.updated в той же транзакции
+func (rep *CategoryRepository) UpdateCategory(ctx context.Context, actor models.Actor, id, name string, parentID *string) error {
+	const op = "category.repository.UpdateCategory"
+
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var oldName string
+	err = tx.QueryRowContext(ctx, `SELECT name FROM categories WHERE id = $1 FOR UPDATE`, id).Scan(&oldName)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%s: %w", op, ErrCategoryNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: failed to query category: %w", op, err)
+	}
+
+	if parentID != nil {
+		if err := rep.checkExists(ctx, tx, *parentID); err != nil {
+			if errors.Is(err, ErrCategoryNotFound) {
+				return fmt.Errorf("%s: %w", op, ErrParentNotFound)
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		// Новый родитель не должен находиться в поддереве перемещаемой категории
+		const queryCycle = `
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM categories WHERE id = $1
+				UNION ALL
+				SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+			)
+			SELECT EXISTS(SELECT 1 FROM subtree WHERE id = $2)
+		`
+
+		var cycle bool
+		if err := tx.QueryRowContext(ctx, queryCycle, id, *parentID).Scan(&cycle); err != nil {
+			return fmt.Errorf("%s: failed to check hierarchy: %w", op, err)
+		}
+		if cycle {
+			return fmt.Errorf("%s: %w", op, ErrCategoryCycle)
+		}
+	}
+
+	const queryUpdate = `UPDATE categories SET name = $2, parent_id = $3 WHERE id = $1`
+
+	if _, err := tx.ExecContext(ctx, queryUpdate, id, name, parentID); err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, ErrCategoryExists)
+		}
+		return fmt.Errorf("%s: failed to update category: %w", op, err)
+	}
+
+	if name != oldName {
+		const queryProducts = `
+			UPDATE products SET version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE category_id = $1
+			RETURNING id, name, description, price, seller_name, version
+		`
+		if err := enqueueProductUpdates(ctx, tx, actor, name, queryProducts, id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: failed to commit: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteCategory удаляет категорию без подкатегорий; её товары остаются
+// без категории, получают новую версию и product.updated
+func (rep *CategoryRepository) DeleteCategory(ctx context.Context, actor models.Actor, id string) error {
+	const op = "category.repository.DeleteCategory"
+
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var hasChildren bool
+	const queryChildren = `SELECT EXISTS(SELECT 1 FROM categories WHERE parent_id = $1)`
+	if err := tx.QueryRowContext(ctx, queryChildren, id).Scan(&hasChildren); err != nil {
+		return fmt.Errorf("%s: failed to check subcategories: %w", op, err)
+	}
+	if hasChildren {
+		return fmt.Errorf("%s: %w", op, ErrHasChildren)
+	}
+
+	// Обнуляем category_id сами, а не через ON DELETE SET NULL: изменённые
+	// товары нужны для событий
+	const queryProducts = `
+		UPDATE products SET category_id = NULL, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE category_id = $1
+		RETURNING id, name, description, price, seller_name, version
+	`
+	if err := enqueueProductUpdates(ctx, tx, actor, "", queryProducts, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("%s: failed to delete category: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, ErrCategoryNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: failed to commit: %w", op, err)
+	}
+
+	return nil
+}
+
+// enqueueProductUpdates выполняет query, изменяющий товары категории, и
+// кладёт product.updated по каждому из них в outbox
+func enqueueProductUpdates(ctx context.Context, tx *sql.Tx, actor models.Actor, categoryName, query string, args ...any) error {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update products: %w", err)
+	}
+
+	var products []events.Product
+	for rows.Next() {
+		var (
+			p           = events.Product{CategoryName: categoryName}
+			description sql.NullString
+		)
+		if err := rows.Scan(&p.ProductID, &p.Title, &description, &p.Price, &p.SellerName, &p.Version); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan product: %w", err)
+		}
+		p.Description = description.String
+		products = append(products, p)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read products: %w", err)
+	}
+
+	for _, p := range products {
+		event, err := models.NewEvent(actor, events.ProductUpdated{Product: p})
+		if err != nil {
+			return err
+		}
+		if err := outbox.Enqueue(ctx, tx, productEventsTopic, p.ProductID, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (rep *CategoryRepository) checkExists(ctx context.Context, db queryRower, id string) error {
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check category: %w", err)
+	}
+	if !exists {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode
+}
@@ -0,0 +1,116 @@
+package category
+
+import (
+	"catalog/internal/models"
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+)
+
+type RepoCategory interface {
+	ListCategories(context.Context) ([]*models.Category, error)
+	ViewCategory(context.Context, string) (*models.Category, error)
+	CreateCategory(context.Context, string, *string) (uuid.UUID, error)
+	UpdateCategory(context.Context, models.Actor, string, string, *string) error
+	DeleteCategory(context.Context, models.Actor, string) error
+}
+
+type Useacase struct {
+	repoCategory RepoCategory
+	log          *slog.Logger
+}
+
+func NewUseacase(log *slog.Logger, repoCategory RepoCategory) *Useacase {
+	return &Useacase{
+		repoCategory: repoCategory,
+		log:          log,
+	}
+}
+
+// ViewCategoryTree возвращает корневые категории с вложенными подкатегориями
+func (u *Useacase) ViewCategoryTree(ctx context.Context) ([]*models.Category, error) {
+	const op = "category.usecase.ViewCategoryTree"
+
+	categories, err := u.repoCategory.ListCategories(ctx)
+	if err != nil {
+		u.log.Error(op+": failed to get categories", slog.Any("err", err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return buildTree(categories), nil
+}
+
+func (u *Useacase) ViewCategory(ctx context.Context, id string) (*models.Category, error) {
+	const op = "category.usecase.ViewCategory"
+
+	category, err := u.repoCategory.ViewCategory(ctx, id)
+	if err != nil {
+		u.log.Error(op+": failed to get category", slog.String("id", id), slog.Any("err", err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return category, nil
+}
+
+func (u *Useacase) CreateCategory(ctx context.Context, name string, parentID *string) (uuid.UUID, error) {
+	const op = "category.usecase.CreateCategory"
+
+	id, err := u.repoCategory.CreateCategory(ctx, name, parentID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.log.Info(op+": category created", slog.String("id", id.String()), slog.String("name", name))
+
+	return id, nil
+}
+
+func (u *Useacase) UpdateCategory(ctx context.Context, actor models.Actor, id, name string, parentID *string) error {
+	const op = "category.usecase.UpdateCategory"
+
+	if err := u.repoCategory.UpdateCategory(ctx, actor, id, name, parentID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.log.Info(op+": category updated", slog.String("id", id))
+
+	return nil
+}
+
+func (u *Useacase) DeleteCategory(ctx context.Context, actor models.Actor, id string) error {
+	const op = "category.usecase.DeleteCategory"
+
+	if err := u.repoCategory.DeleteCategory(ctx, actor, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.log.Info(op+": category deleted", slog.String("id", id))
+
+	return nil
+}
+
+func buildTree(categories []*models.Category) []*models.Category {
+	byID := make(map[string]*models.Category, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+
+	var roots []*models.Category
+	for _, c := range categories {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+			continue
+		}
+
+		parent, ok := byID[*c.ParentID]
+		if !ok {
+			roots = append(roots, c)
+			continue
+		}
+		parent.Children = append(parent.Children, c)
+	}
+
+	return roots
+}
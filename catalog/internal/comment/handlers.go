@@ -2,25 +2,34 @@ package comment
 
 import (
 	"catalog/internal/lib/handlers/response"
+	"catalog/internal/lib/pagination"
+	"catalog/internal/middleware"
 	"catalog/internal/models"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 )
 
 const (
+	warnGetUserID        = "failed to get user ID from context"
 	messageUnauthorized  = "Unauthorized"
 	errCreateComment     = "failed to create comment"
 	successCreateComment = "create comment successfully"
+	errUpdateComment     = "failed to update comment"
+	successUpdateComment = "update comment successfully"
+	errDeleteComment     = "failed to delete comment"
+	successDeleteComment = "delete comment successfully"
+	defaultSearchLimit   = 20
 )
 
 type RequestCreateComment struct {
-	UserID    string `json:"user_id"`
 	ProductID string `json:"product_id"`
 	Comment   string `json:"comment"`
 }
@@ -37,6 +46,14 @@ func CreateComment(log *slog.Logger, CommentCreater CommentCreater) http.Handler
 			slog.String("op", op),
 		)
 
+		userID, ok := middleware.GetUserID(r)
+		if !ok || userID == "" {
+			log.Warn(warnGetUserID)
+
+			response.RespondWithError(w, log, http.StatusUnauthorized, messageUnauthorized)
+			return
+		}
+
 		var req RequestCreateComment
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			log.Error(fmt.Errorf("failed to decode request body: Error: %w", err).Error())
@@ -46,10 +63,10 @@ func CreateComment(log *slog.Logger, CommentCreater CommentCreater) http.Handler
 		}
 
 		log = log.With(
-			slog.String("user_id", req.UserID),
+			slog.String("user_id", userID),
 		)
 
-		if err := CommentCreater.CreateComment(r.Context(), req.UserID, req.ProductID, req.Comment); err != nil {
+		if err := CommentCreater.CreateComment(r.Context(), userID, req.ProductID, req.Comment); err != nil {
 			log.Error(fmt.Errorf("%s Error: %w", errCreateComment, err).Error())
 
 			response.RespondWithError(w, log, http.StatusInternalServerError, errCreateComment)
@@ -63,8 +80,123 @@ func CreateComment(log *slog.Logger, CommentCreater CommentCreater) http.Handler
 	}
 }
 
+type RequestUpdateComment struct {
+	ID      string `json:"id"`
+	Comment string `json:"comment"`
+}
+
+type CommentUpdater interface {
+	UpdateComment(context.Context, models.Actor, string, string) error
+}
+
+// UpdateComment меняет текст комментария; изменить можно только свой комментарий
+func UpdateComment(log *slog.Logger, commentUpdater CommentUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "comment.handlers.UpdateComment"
+
+		log := log.With(
+			slog.String("op", op),
+		)
+
+		actor, ok := middleware.GetActor(r)
+		if !ok {
+			log.Warn(warnGetUserID)
+
+			response.RespondWithError(w, log, http.StatusUnauthorized, messageUnauthorized)
+			return
+		}
+
+		var req RequestUpdateComment
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error(fmt.Errorf("failed to decode request body: Error: %w", err).Error())
+
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid request")
+			return
+		}
+
+		id, err := uuid.Parse(req.ID)
+		if err != nil {
+			log.Warn(op+": invalid UUID", slog.String("id", req.ID), slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'id' parameter")
+			return
+		}
+
+		if strings.TrimSpace(req.Comment) == "" {
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'comment' parameter")
+			return
+		}
+
+		if err := commentUpdater.UpdateComment(r.Context(), actor, id.String(), req.Comment); err != nil {
+			log.Error(fmt.Errorf("%s Error: %w", errUpdateComment, err).Error())
+
+			switch {
+			case errors.Is(err, ErrCommentNotFound):
+				response.RespondWithError(w, log, http.StatusNotFound, "comment not found")
+			case errors.Is(err, ErrForbidden):
+				response.RespondWithError(w, log, http.StatusForbidden, "comment belongs to another user")
+			default:
+				response.RespondWithError(w, log, http.StatusInternalServerError, errUpdateComment)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		log.Info(successUpdateComment, slog.String("id", id.String()))
+	}
+}
+
+type CommentDeleter interface {
+	DeleteComment(context.Context, models.Actor, string) error
+}
+
+// DeleteComment удаляет комментарий; удалить можно только свой комментарий
+func DeleteComment(log *slog.Logger, commentDeleter CommentDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "comment.handlers.DeleteComment"
+
+		log := log.With(
+			slog.String("op", op),
+		)
+
+		actor, ok := middleware.GetActor(r)
+		if !ok {
+			log.Warn(warnGetUserID)
+
+			response.RespondWithError(w, log, http.StatusUnauthorized, messageUnauthorized)
+			return
+		}
+
+		idStr := r.URL.Query().Get("id")
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			log.Warn(op+": invalid UUID", slog.String("id", idStr), slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'id' parameter")
+			return
+		}
+
+		if err := commentDeleter.DeleteComment(r.Context(), actor, id.String()); err != nil {
+			log.Error(fmt.Errorf("%s Error: %w", errDeleteComment, err).Error())
+
+			switch {
+			case errors.Is(err, ErrCommentNotFound):
+				response.RespondWithError(w, log, http.StatusNotFound, "comment not found")
+			case errors.Is(err, ErrForbidden):
+				response.RespondWithError(w, log, http.StatusForbidden, "comment belongs to another user")
+			default:
+				response.RespondWithError(w, log, http.StatusInternalServerError, errDeleteComment)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+		log.Info(successDeleteComment, slog.String("id", id.String()))
+	}
+}
+
 type CommentListViewer interface {
-	ViewCommentInProduct(context.Context, string, int, int) ([]*models.CommentListView, error)
+	ViewCommentInProduct(context.Context, string, pagination.Request) (*models.Page[*models.CommentListView], error)
 }
 
 func ViewCommentInProduct(log *slog.Logger, CommentListViewer CommentListViewer) http.HandlerFunc {
@@ -78,27 +210,19 @@ func ViewCommentInProduct(log *slog.Logger, CommentListViewer CommentListViewer)
 			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'id' parameter")
 			return
 		}
-		fmt.Println(productID)
-		q := r.URL.Query()
-
-		offsetStr := q.Get("offset")
-		limitStr := q.Get("limit")
-
-		offset, err := strconv.Atoi(offsetStr)
-		if err != nil || offset < 0 {
-			log.Warn(op+": invalid offset", slog.String("offset", offsetStr), slog.Any("err", err))
-			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'offset' parameter")
+		page, param, err := pagination.ParseRequest(r.URL.Query())
+		if err != nil {
+			log.Warn(op+": invalid pagination", slog.String("param", param), slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusBadRequest, fmt.Sprintf("invalid '%s' parameter", param))
 			return
 		}
 
-		limit, err := strconv.Atoi(limitStr)
-		if err != nil || limit <= 0 {
-			log.Warn(op+": invalid limit", slog.String("limit", limitStr), slog.Any("err", err))
-			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'limit' parameter")
+		comments, err := CommentListViewer.ViewCommentInProduct(r.Context(), productID.String(), page)
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			log.Warn(op+": invalid cursor", slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'cursor' parameter")
 			return
 		}
-
-		comments, err := CommentListViewer.ViewCommentInProduct(r.Context(), productID.String(), offset, limit)
 		if err != nil {
 			log.Error(op+": failed to get comments", slog.Any("err", err))
 			response.RespondWithError(w, log, http.StatusInternalServerError, "failed to get comments")
@@ -108,3 +232,63 @@ func ViewCommentInProduct(log *slog.Logger, CommentListViewer CommentListViewer)
 		response.RespondWithJSON(w, log, http.StatusOK, comments)
 	}
 }
+
+type CommentSearcher interface {
+	SearchComments(context.Context, string, string, pagination.Request) (*models.CommentSearchResult, error)
+}
+
+func SearchComments(log *slog.Logger, commentSearcher CommentSearcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "comment.handlers.SearchComments"
+
+		q := r.URL.Query()
+
+		query := strings.TrimSpace(q.Get("req"))
+		if query == "" {
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'req' parameter")
+			return
+		}
+
+		var productID string
+		if idStr := q.Get("product_id"); idStr != "" {
+			id, err := uuid.Parse(idStr)
+			if err != nil {
+				log.Warn(op+": invalid UUID", slog.String("id", idStr), slog.Any("err", err))
+				response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'product_id' parameter")
+				return
+			}
+			productID = id.String()
+		}
+
+		page := pagination.Request{Limit: defaultSearchLimit}
+
+		if limitStr := q.Get("limit"); limitStr != "" {
+			limit, err := strconv.Atoi(limitStr)
+			if err != nil || limit <= 0 || limit > pagination.MaxLimit {
+				log.Warn(op+": invalid limit", slog.String("limit", limitStr), slog.Any("err", err))
+				response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'limit' parameter")
+				return
+			}
+			page.Limit = limit
+		}
+
+		if offsetStr := q.Get("offset"); offsetStr != "" {
+			offset, err := strconv.Atoi(offsetStr)
+			if err != nil || offset < 0 || offset+page.Limit > maxResultWindow {
+				log.Warn(op+": invalid offset", slog.String("offset", offsetStr), slog.Any("err", err))
+				response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'offset' parameter")
+				return
+			}
+			page.Offset = offset
+		}
+
+		result, err := commentSearcher.SearchComments(r.Context(), query, productID, page)
+		if err != nil {
+			log.Error(op+": failed to search comments", slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusInternalServerError, "failed to search comments")
+			return
+		}
+
+		response.RespondWithJSON(w, log, http.StatusOK, result)
+	}
+}
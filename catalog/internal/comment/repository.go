@@ -1,25 +1,46 @@
 package comment
 
 import (
+	"catalog/internal/lib/pagination"
 	"catalog/internal/models"
+	"catalog/internal/outbox"
+	"catalog/internal/search"
 	"context"
 	"database/sql"
+	"errors"
+	"events"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const commentEventsTopic = "comment-events"
+
+var (
+	ErrCommentNotFound = errors.New("comment not found")
+	ErrForbidden       = errors.New("comment belongs to another user")
+)
+
 type CommentRepository struct {
-	db *sql.DB
+	db            *sql.DB
+	search        *search.Client
+	commentsIndex string
 }
 
-func NewRepository(db *sql.DB) *CommentRepository {
-	return &CommentRepository{db: db}
+func NewRepository(db *sql.DB, search *search.Client, commentsIndex string) *CommentRepository {
+	return &CommentRepository{db: db, search: search, commentsIndex: commentsIndex}
 }
 
 func (rep *CommentRepository) CreateComment(ctx context.Context, UserID, ProductID, Comment string) (uuid.UUID, error) {
 	const op = "comment.repository.CreateComment"
 
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
 	commentID := uuid.New()
 
 	const queryInsert = `
@@ -27,44 +48,191 @@ func (rep *CommentRepository) CreateComment(ctx context.Context, UserID, Product
 		VALUES ($1, $2, $3, $4)
 	`
 
-	_, err := rep.db.ExecContext(ctx, queryInsert,
+	_, err = tx.ExecContext(ctx, queryInsert,
 		commentID, UserID, ProductID, Comment,
 	)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("%s: failed to insert comment: %w", op, err)
 	}
 
+	event, err := models.NewEvent(models.Actor{UserID: UserID}, events.CommentCreated{
+		CommentID: commentID.String(),
+		ProductID: ProductID,
+		UserID:    UserID,
+		Comment:   Comment,
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := outbox.Enqueue(ctx, tx, commentEventsTopic, commentID.String(), event); err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("%s: failed to commit: %w", op, err)
+	}
+
 	return commentID, nil
 }
 
+// UpdateComment заменяет текст комментария; изменить можно только свой
+// комментарий
+func (rep *CommentRepository) UpdateComment(ctx context.Context, actor models.Actor, id, comment string) error {
+	const op = "comment.repository.UpdateComment"
+
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var (
+		userID    string
+		productID string
+	)
+	err = tx.QueryRowContext(ctx, `SELECT user_id, product_id FROM comments WHERE id = $1 FOR UPDATE`, id).Scan(&userID, &productID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%s: %w", op, ErrCommentNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: failed to query comment: %w", op, err)
+	}
+
+	if !actor.CanManage(userID) {
+		return fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE comments SET comment = $2 WHERE id = $1`, id, comment); err != nil {
+		return fmt.Errorf("%s: failed to update comment: %w", op, err)
+	}
+
+	event, err := models.NewEvent(actor, events.CommentUpdated{
+		CommentID: id,
+		ProductID: productID,
+		UserID:    userID,
+		Comment:   comment,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := outbox.Enqueue(ctx, tx, commentEventsTopic, id, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: failed to commit: %w", op, err)
+	}
+
+	return nil
+}
+
+func (rep *CommentRepository) DeleteComment(ctx context.Context, actor models.Actor, id string) error {
+	const op = "comment.repository.DeleteComment"
+
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var (
+		userID    string
+		productID string
+	)
+	err = tx.QueryRowContext(ctx, `SELECT user_id, product_id FROM comments WHERE id = $1 FOR UPDATE`, id).Scan(&userID, &productID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%s: %w", op, ErrCommentNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: failed to query comment: %w", op, err)
+	}
+
+	if !actor.CanManage(userID) {
+		return fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("%s: failed to delete comment: %w", op, err)
+	}
+
+	event, err := models.NewEvent(actor, events.CommentDeleted{
+		CommentID: id,
+		ProductID: productID,
+		UserID:    userID,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := outbox.Enqueue(ctx, tx, commentEventsTopic, id, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: failed to commit: %w", op, err)
+	}
+
+	return nil
+}
+
+// ViewCommentInProduct возвращает комментарии товара от новых к старым;
+// порядок (created_at, id) позволяет листать как по offset, так и по курсору
 func (rep *CommentRepository) ViewCommentInProduct(
 	ctx context.Context,
 	productID string,
-	offset int,
-	limit int,
-) ([]*models.CommentListView, []string, error) {
+	page pagination.Request,
+) (*models.Page[*models.CommentListView], []string, error) {
 	const query = `
-        SELECT id, user_id, comment
+        SELECT id, user_id, comment, created_at
         FROM comments
         WHERE product_id = $1
+        ORDER BY created_at DESC, id DESC
         OFFSET $2
         LIMIT $3;
     `
 
-	rows, err := rep.db.QueryContext(ctx, query, productID, offset, limit)
+	const queryAfter = `
+        SELECT id, user_id, comment, created_at
+        FROM comments
+        WHERE product_id = $1 AND (created_at, id) < ($2, $3)
+        ORDER BY created_at DESC, id DESC
+        LIMIT $4;
+    `
+
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if page.Cursor != nil {
+		createdAt, parseErr := time.Parse(time.RFC3339Nano, page.Cursor.Key)
+		if parseErr != nil {
+			return nil, nil, pagination.ErrInvalidCursor
+		}
+		rows, err = rep.db.QueryContext(ctx, queryAfter, productID, createdAt, page.Cursor.ID, page.Limit+1)
+	} else {
+		rows, err = rep.db.QueryContext(ctx, query, productID, page.Offset, page.Limit+1)
+	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("querying comments: %w", err)
 	}
 	defer rows.Close()
 
 	var (
-		comments []*models.CommentListView
+		comments = make([]*models.CommentListView, 0, page.Limit)
 		ids      []string
+		hasMore  bool
 	)
 
 	for rows.Next() {
+		if len(comments) == page.Limit {
+			hasMore = true
+			break
+		}
+
 		var comment models.CommentListView
-		if err := rows.Scan(&comment.ID, &comment.UserID, &comment.Comment); err != nil {
+		if err := rows.Scan(&comment.ID, &comment.UserID, &comment.Comment, &comment.CreatedAt); err != nil {
 			return nil, nil, fmt.Errorf("scanning comment: %w", err)
 		}
 		comments = append(comments, &comment)
@@ -75,5 +243,120 @@ func (rep *CommentRepository) ViewCommentInProduct(
 		return nil, nil, fmt.Errorf("reading rows: %w", err)
 	}
 
-	return comments, ids, nil
+	result := &models.Page[*models.CommentListView]{
+		Items: comments,
+		Pagination: models.Pagination{
+			Limit:   page.Limit,
+			HasMore: hasMore,
+		},
+	}
+
+	if page.Cursor == nil {
+		result.Pagination.Offset = &page.Offset
+	}
+
+	if hasMore {
+		last := comments[len(comments)-1]
+		result.Pagination.NextCursor = pagination.Encode(pagination.Cursor{
+			Key: last.CreatedAt.Format(time.RFC3339Nano),
+			ID:  last.ID,
+		})
+	}
+
+	return result, ids, nil
+}
+
+func (rep *CommentRepository) SearchComments(ctx context.Context, query, productID string, page pagination.Request) (*models.CommentSearchResult, []string, error) {
+	const op = "comment.repository.SearchComments"
+
+	res, err := search.Search[commentDocument](ctx, rep.search, rep.commentsIndex, buildSearchRequest(query, productID, page))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ids := make([]string, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		id := hit.Source.CommentID
+		if id == "" {
+			id = hit.ID
+		}
+		ids = append(ids, id)
+	}
+
+	comments, err := rep.viewCommentsByIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: failed to hydrate comments: %w", op, err)
+	}
+
+	// Порядок релевантности из ES сохраняется; комментарии, которых уже нет
+	// в Postgres, пропускаются
+	items := make([]*models.CommentSearchHit, 0, len(ids))
+	found := make([]string, 0, len(ids))
+	for i, hit := range res.Hits.Hits {
+		comment, ok := comments[ids[i]]
+		if !ok {
+			continue
+		}
+		items = append(items, &models.CommentSearchHit{
+			CommentListView: comment.CommentListView,
+			ProductID:       comment.ProductID,
+			Score:           hit.Score,
+			Highlights:      hit.Highlight,
+		})
+		found = append(found, ids[i])
+	}
+
+	offset := page.Offset
+	result := &models.CommentSearchResult{
+		Items: items,
+		Total: res.Hits.Total.Value,
+		Pagination: models.Pagination{
+			Limit:   page.Limit,
+			Offset:  &offset,
+			HasMore: page.Offset+len(res.Hits.Hits) < res.Hits.Total.Value,
+		},
+	}
+
+	return result, found, nil
+}
+
+type commentWithProduct struct {
+	*models.CommentListView
+	ProductID string
+}
+
+func (rep *CommentRepository) viewCommentsByIDs(ctx context.Context, ids []string) (map[string]commentWithProduct, error) {
+	comments := make(map[string]commentWithProduct, len(ids))
+	if len(ids) == 0 {
+		return comments, nil
+	}
+
+	const query = `
+		SELECT id, user_id, product_id, comment, created_at
+		FROM comments
+		WHERE id = ANY($1)
+	`
+
+	rows, err := rep.db.QueryContext(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("querying comments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			comment   models.CommentListView
+			productID string
+		)
+		if err := rows.Scan(&comment.ID, &comment.UserID, &productID, &comment.Comment, &comment.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning comment: %w", err)
+		}
+		comments[comment.ID] = commentWithProduct{CommentListView: &comment, ProductID: productID}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading rows: %w", err)
+	}
+
+	return comments, nil
 }
@@ -0,0 +1,47 @@
+package comment
+
+import (
+	"catalog/internal/lib/pagination"
+	"catalog/internal/search"
+)
+
+// commentDocument — документ индекса comments
+type commentDocument struct {
+	CommentID string `json:"comment_id"`
+	ProductID string `json:"product_id,omitempty"`
+	Comment   string `json:"comment"`
+}
+
+// maxResultWindow — ограничение index.max_result_window по умолчанию
+const maxResultWindow = 10000
+
+func buildSearchRequest(query, productID string, page pagination.Request) search.Request {
+	boolQuery := search.BoolQuery{
+		Must: []search.Query{{
+			Match: map[string]search.MatchQuery{
+				"comment": {Query: query, Fuzziness: "AUTO"},
+			},
+		}},
+	}
+
+	if productID != "" {
+		boolQuery.Filter = append(boolQuery.Filter, search.Query{
+			Term: map[string]any{"product_id": productID},
+		})
+	}
+
+	return search.Request{
+		From:  page.Offset,
+		Size:  page.Limit,
+		Query: &search.Query{Bool: &boolQuery},
+		Highlight: &search.Highlight{
+			PreTags:  []string{"<em>"},
+			PostTags: []string{"</em>"},
+			Encoder:  search.EncoderHTML,
+			Fields: map[string]search.HighlightField{
+				"comment": {FragmentSize: 150, NumberOfFragments: 3},
+			},
+		},
+		Source: []string{"comment_id"},
+	}
+}
@@ -1,28 +1,35 @@
 package comment
 
 import (
+	"catalog/internal/lib/pagination"
 	"catalog/internal/models"
 	"context"
+	"events"
 	"fmt"
 	"log/slog"
-	"time"
 
 	"github.com/google/uuid"
 )
 
-type Event struct {
-	Action      string    `json:"action"`
-	CommentID   string    `json:"comment_id,omitempty"`
-	Comment     string    `json:"comment,omitempty"`
-	URL         string    `json:"url,omitempty"`
-	Ids         []string  `json:"ids,omitempty"`
-	Title       string    `json:"title,omitempty"`
-	Description string    `json:"description,omitempty"`
-	Timestamp   time.Time `json:"timestamp,omitempty"`
+type EventProducer interface {
+	Send(ctx context.Context, event events.Envelope, topic string) error
 }
 
-type EventProducer interface {
-	Send(ctx context.Context, event any, topic string) error
+// sendUserAction отправляет действие пользователя в user-events, не
+// задерживая ответ
+func sendUserAction(log *slog.Logger, producer EventProducer, actor models.Actor, action events.UserAction) {
+	event, err := models.NewEvent(actor, action)
+	if err != nil {
+		log.Error("failed to build user event", slog.String("err", err.Error()))
+		return
+	}
+
+	go func() {
+		ctx := context.Background()
+		if err := producer.Send(ctx, event, "user-events"); err != nil {
+			log.Error("failed to send event to Kafka", slog.String("err", err.Error()))
+		}
+	}()
 }
 
 type CreateUseacase struct {
@@ -51,31 +58,59 @@ func (u *CreateUseacase) CreateComment(ctx context.Context, UserID, ProductID, C
 
 	}
 
-	eventUser := Event{
-		URL:       "comment",
-		Action:    "create",
-		Timestamp: time.Now(),
+	sendUserAction(u.log, u.eventProducer, models.Actor{UserID: UserID}, events.UserAction{URL: "comment", Action: "create"})
+
+	// comment_created записывается в outbox репозиторием в той же транзакции
+	u.log.Info(op+": comment created", slog.String("comment_id", commentID.String()))
+
+	return nil
+}
+
+type RepoEditComment interface {
+	UpdateComment(context.Context, models.Actor, string, string) error
+	DeleteComment(context.Context, models.Actor, string) error
+}
+
+type EditUseacase struct {
+	repoEditComment RepoEditComment
+	eventProducer   EventProducer
+	log             *slog.Logger
+}
+
+func NewEditUseacase(log *slog.Logger, repoEditComment RepoEditComment, eventProducer EventProducer) *EditUseacase {
+	return &EditUseacase{
+		repoEditComment: repoEditComment,
+		eventProducer:   eventProducer,
+		log:             log,
 	}
+}
 
-	go func() {
-		ctx := context.Background()
-		if err := u.eventProducer.Send(ctx, eventUser, "user-events"); err != nil {
-			u.log.Error("failed to send event to Kafka", slog.String("err", err.Error()))
-		}
-	}()
+func (u *EditUseacase) UpdateComment(ctx context.Context, actor models.Actor, id, comment string) error {
+	const op = "comment.usecase.UpdateComment"
 
-	eventProduct := Event{
-		Action:    "comment_created",
-		CommentID: commentID.String(),
-		Comment:   Comment,
+	// comment_updated записывается в outbox репозиторием в той же транзакции
+	if err := u.repoEditComment.UpdateComment(ctx, actor, id, comment); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	go func() {
-		ctx := context.Background()
-		if err := u.eventProducer.Send(ctx, eventProduct, "comment-events"); err != nil {
-			u.log.Error("failed to send event to Kafka", slog.String("err", err.Error()))
-		}
-	}()
+	sendUserAction(u.log, u.eventProducer, actor, events.UserAction{URL: "comment", Action: "update"})
+
+	u.log.Info(op+": comment updated", slog.String("comment_id", id))
+
+	return nil
+}
+
+func (u *EditUseacase) DeleteComment(ctx context.Context, actor models.Actor, id string) error {
+	const op = "comment.usecase.DeleteComment"
+
+	// comment_deleted записывается в outbox репозиторием в той же транзакции
+	if err := u.repoEditComment.DeleteComment(ctx, actor, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	sendUserAction(u.log, u.eventProducer, actor, events.UserAction{URL: "comment", Action: "delete"})
+
+	u.log.Info(op+": comment deleted", slog.String("comment_id", id))
 
 	return nil
 }
@@ -95,37 +130,52 @@ func NewViewUseacase(log *slog.Logger, RepoViewComment RepoViewComment, eventPro
 }
 
 type RepoViewComment interface {
-	ViewCommentInProduct(context.Context, string, int, int) ([]*models.CommentListView, []string, error)
+	ViewCommentInProduct(context.Context, string, pagination.Request) (*models.Page[*models.CommentListView], []string, error)
+	SearchComments(context.Context, string, string, pagination.Request) (*models.CommentSearchResult, []string, error)
 }
 
-func (u *ViewUseacase) ViewCommentInProduct(ctx context.Context, productID string, offset int, limit int) ([]*models.CommentListView, error) {
+func (u *ViewUseacase) ViewCommentInProduct(ctx context.Context, productID string, page pagination.Request) (*models.Page[*models.CommentListView], error) {
 	const op = "product.usecase.ViewCommentInProduct"
 
-	comments, ids, err := u.repoViewComment.ViewCommentInProduct(ctx, productID, offset, limit)
+	comments, ids, err := u.repoViewComment.ViewCommentInProduct(ctx, productID, page)
 	if err != nil {
 		u.log.Error(op+": failed to get comments", slog.Any("err", err))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	event := Event{
-		URL:       "comments",
-		Ids:       ids,
-		Action:    "visibility",
-		Timestamp: time.Now(),
-	}
-
-	go func() {
-		ctx := context.Background()
-		if err := u.eventProducer.Send(ctx, event, "user-events"); err != nil {
-			u.log.Error("failed to send event to Kafka", slog.String("err", err.Error()))
-		}
-	}()
+	u.sendVisibility(ids)
 
 	u.log.Info(op+": successfully retrieved comments",
-		slog.Int("count", len(comments)),
-		slog.Int("offset", offset),
-		slog.Int("limit", limit),
+		slog.Int("count", len(comments.Items)),
+		slog.Int("offset", page.Offset),
+		slog.Bool("cursor", page.Cursor != nil),
+		slog.Int("limit", page.Limit),
 	)
 
 	return comments, nil
 }
+
+func (u *ViewUseacase) SearchComments(ctx context.Context, query, productID string, page pagination.Request) (*models.CommentSearchResult, error) {
+	const op = "comment.usecase.SearchComments"
+
+	result, ids, err := u.repoViewComment.SearchComments(ctx, query, productID, page)
+	if err != nil {
+		u.log.Error(op+": failed to search comments", slog.Any("err", err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.sendVisibility(ids)
+
+	u.log.Info(op+": successfully searched comments",
+		slog.Int("count", len(result.Items)),
+		slog.Int("total", result.Total),
+		slog.String("search_request", query),
+		slog.String("product_id", productID),
+	)
+
+	return result, nil
+}
+
+func (u *ViewUseacase) sendVisibility(ids []string) {
+	sendUserAction(u.log, u.eventProducer, models.Actor{}, events.UserAction{URL: "comments", Action: "visibility", IDs: ids})
+}
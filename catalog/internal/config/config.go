@@ -1,7 +1,9 @@
 package config
 
 import (
+	"catalog/internal/lib/tracing"
 	"log"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 	"github.com/joho/godotenv"
@@ -12,6 +14,9 @@ type Config struct {
 	Secret        string `env:"SECRET" default:""`
 	Database      DatabaseConfig
 	Elasticsearch ElasticsearchConfig
+	Outbox        OutboxConfig
+	Kafka         KafkaConfig
+	Tracing       tracing.Config
 }
 
 type DatabaseConfig struct {
@@ -23,7 +28,22 @@ type DatabaseConfig struct {
 }
 
 type ElasticsearchConfig struct {
-	Host string `env:"ELASTICSEARCH_HOST" default:"elasticsearch:9200"`
+	Host          string `env:"ELASTICSEARCH_HOST" default:"elasticsearch:9200"`
+	ProductsIndex string `env:"ELASTICSEARCH_PRODUCTS_INDEX" envDefault:"products"`
+	CommentsIndex string `env:"ELASTICSEARCH_COMMENTS_INDEX" envDefault:"comments"`
+}
+
+type OutboxConfig struct {
+	PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" envDefault:"1s"`
+	BatchSize    int           `env:"OUTBOX_BATCH_SIZE" envDefault:"100"`
+	MaxAttempts  int           `env:"OUTBOX_MAX_ATTEMPTS" envDefault:"20"`
+	MaxBackoff   time.Duration `env:"OUTBOX_MAX_BACKOFF" envDefault:"5m"`
+}
+
+type KafkaConfig struct {
+	// EventFormat — формат всех событий каталога, включая outbox: json или
+	// avro. Avro читают indexer и consumer, comment-etl — только JSON.
+	EventFormat string `env:"KAFKA_EVENT_FORMAT" envDefault:"json"`
 }
 
 func Load() *Config {
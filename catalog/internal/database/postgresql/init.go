@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 const UnableToConnectDatabase = "Unable to connect to database: "
@@ -25,7 +27,8 @@ func ConnectAndNew(log *slog.Logger, cfg *config.DatabaseConfig) (*Storage, erro
 
 	dsn := getDSN(cfg)
 
-	db, err := sql.Open("pgx", dsn)
+	// Каждый запрос с контекстом становится спаном трейса
+	db, err := otelsql.Open("pgx", dsn, otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
 	if err != nil {
 		log.Error(UnableToConnectDatabase, "error", err)
 		return nil, err
@@ -1,43 +1,154 @@
 package kafka
 
 import (
+	"catalog/internal/outbox"
 	"context"
-	"encoding/json"
+	"errors"
+	"events"
 	"fmt"
 	"time"
 
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("catalog/internal/kafka")
+
 type KafkaProducer struct {
 	writer *kafka.Writer
 	topic  string
+	format events.Format
 }
 
-func NewKafkaProducer(brokers []string) *KafkaProducer {
+func NewKafkaProducer(brokers []string, format events.Format) *KafkaProducer {
 	return &KafkaProducer{
+		format: format,
 		writer: &kafka.Writer{
-			Addr:         kafka.TCP(brokers...),
-			Balancer:     &kafka.LeastBytes{},
+			Addr: kafka.TCP(brokers...),
+			// Hash сохраняет порядок событий одной сущности (по ключу),
+			// сообщения без ключа распределяются round-robin
+			Balancer:     &kafka.Hash{},
 			RequiredAcks: kafka.RequireOne,
+			// Send пишет по одному сообщению, а relay — пачкой: ждать
+			// добора пачки секунду по умолчанию незачем
+			BatchTimeout: 10 * time.Millisecond,
 		},
 	}
 }
 
-func (kp *KafkaProducer) Send(ctx context.Context, event any, topic string) error {
-	msgBytes, err := json.Marshal(event)
+// Send отправляет событие без ключа партиционирования
+func (kp *KafkaProducer) Send(ctx context.Context, event events.Envelope, topic string) error {
+	msgBytes, err := kp.format.Marshal(event)
 	if err != nil {
 		return err
 	}
-	fmt.Println(topic)
 	message := kafka.Message{
-		Topic: topic,
-		Key:   nil,
-		Value: msgBytes,
-		Time:  time.Now(),
+		Topic:   topic,
+		Key:     nil,
+		Value:   msgBytes,
+		Headers: []kafka.Header{{Key: events.ContentTypeHeader, Value: []byte(kp.format.ContentType())}},
+		Time:    time.Now(),
+	}
+
+	return kp.write(ctx, message)
+}
+
+// PublishBatch отправляет пачку outbox одним запросом в формате продюсера,
+// как и Send. Outbox хранит события в JSON; trace context каждой записи уже
+// лежит в её заголовках.
+func (kp *KafkaProducer) PublishBatch(ctx context.Context, records []outbox.Record) []error {
+	errs := make([]error, len(records))
+	// sent — индексы записей, попавших в пачку: запись, которую не удалось
+	// перекодировать, не отправляется
+	sent := make([]int, 0, len(records))
+	messages := make([]kafka.Message, 0, len(records))
+	now := time.Now()
+	for i, r := range records {
+		value, err := kp.encode(r.Value)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		headers := []kafka.Header{{Key: events.ContentTypeHeader, Value: []byte(kp.format.ContentType())}}
+		for k, v := range r.Headers {
+			headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
+		}
+		messages = append(messages, kafka.Message{Topic: r.Topic, Key: r.Key, Value: value, Headers: headers, Time: now})
+		sent = append(sent, i)
+	}
+	if len(messages) == 0 {
+		return errs
+	}
+
+	ctx, span := tracer.Start(ctx, "send batch",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingBatchMessageCount(len(messages)),
+		),
+	)
+	defer span.End()
+
+	err := kp.writer.WriteMessages(ctx, messages...)
+	if err == nil {
+		return errs
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) && len(writeErrs) == len(messages) {
+		for j, i := range sent {
+			errs[i] = writeErrs[j]
+		}
+		return errs
+	}
+	for _, i := range sent {
+		errs[i] = err
+	}
+	return errs
+}
+
+// encode перекодирует событие из JSON outbox в формат продюсера
+func (kp *KafkaProducer) encode(payload []byte) ([]byte, error) {
+	if kp.format.ContentType() == events.ContentTypeJSON {
+		return payload, nil
+	}
+
+	env, err := events.JSON.Unmarshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("decoding outbox event: %w", err)
+	}
+	return kp.format.Marshal(env)
+}
+
+// write отправляет сообщение в спане producer и передаёт trace context
+// потребителям в заголовках сообщения (traceparent)
+func (kp *KafkaProducer) write(ctx context.Context, message kafka.Message) error {
+	ctx, span := tracer.Start(ctx, "send "+message.Topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(message.Topic),
+			attribute.Int("messaging.message.body.size", len(message.Value)),
+		),
+	)
+	defer span.End()
+
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier{Headers: &message.Headers})
+
+	if err := kp.writer.WriteMessages(ctx, message); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
 	}
 
-	return kp.writer.WriteMessages(ctx, message)
+	return nil
 }
 
 func (kp *KafkaProducer) Close() error {
@@ -0,0 +1,42 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"events"
+	"testing"
+)
+
+func TestEncodeOutboxPayload(t *testing.T) {
+	env, err := events.New("catalog", nil, events.ProductCreated{Product: events.Product{ProductID: "p1", Title: "t", SellerName: "s", Version: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// JSON уходит из outbox как есть
+	got, err := (&KafkaProducer{format: events.JSON}).encode(payload)
+	if err != nil || !bytes.Equal(got, payload) {
+		t.Fatalf("json encode = %s, %v", got, err)
+	}
+
+	avro := &KafkaProducer{format: events.Avro}
+	got, err = avro.encode(payload)
+	if err != nil {
+		t.Fatalf("avro encode: %v", err)
+	}
+	decoded, err := events.Avro.Unmarshal(got)
+	if err != nil {
+		t.Fatalf("avro decode: %v", err)
+	}
+	if decoded.ID != env.ID || decoded.Type != env.Type {
+		t.Fatalf("decoded = %s %s, want %s %s", decoded.ID, decoded.Type, env.ID, env.Type)
+	}
+
+	if _, err := avro.encode([]byte("not json")); err == nil {
+		t.Fatal("broken outbox payload was encoded")
+	}
+}
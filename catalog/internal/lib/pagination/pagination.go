@@ -0,0 +1,95 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+const MaxLimit = 100
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor — позиция последнего элемента страницы: значение ключа
+// сортировки и id как тайбрейкер. Клиенту отдаётся в непрозрачном виде.
+type Cursor struct {
+	Sort string `json:"s,omitempty"`
+	Key  string `json:"k"`
+	ID   string `json:"id"`
+}
+
+func Encode(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func Decode(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// id попадает в запрос как UUID: чужое значение — ошибка клиента, а не 500
+	id, err := uuid.Parse(c.ID)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c.ID = id.String()
+
+	return &c, nil
+}
+
+// Request — параметры страницы. Если задан Cursor, используется keyset-режим,
+// иначе OFFSET/LIMIT.
+type Request struct {
+	Limit  int
+	Offset int
+	Cursor *Cursor
+}
+
+// ParseRequest разбирает limit, offset и cursor из query-параметров.
+// При ошибке возвращает имя некорректного параметра.
+func ParseRequest(q url.Values) (Request, string, error) {
+	var req Request
+
+	limitStr := q.Get("limit")
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 || limit > MaxLimit {
+		return req, "limit", fmt.Errorf("limit must be in [1, %d]: %q", MaxLimit, limitStr)
+	}
+	req.Limit = limit
+
+	if cursorStr := q.Get("cursor"); cursorStr != "" {
+		if q.Has("offset") {
+			return req, "offset", errors.New("offset cannot be combined with cursor")
+		}
+
+		cursor, err := Decode(cursorStr)
+		if err != nil {
+			return req, "cursor", err
+		}
+		req.Cursor = cursor
+
+		return req, "", nil
+	}
+
+	if offsetStr := q.Get("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return req, "offset", fmt.Errorf("offset must be non-negative: %q", offsetStr)
+		}
+		req.Offset = offset
+	}
+
+	return req, "", nil
+}
@@ -1,9 +1,17 @@
 package middleware
 
 import (
+	"bytes"
+	"catalog/internal/models"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type contextKey string
@@ -11,21 +19,41 @@ type contextKey string
 const (
 	UserIDContextKey   contextKey = "UserID"
 	UsernameContextKey contextKey = "Username"
+	UserRoleContextKey contextKey = "UserRole"
 )
 
-func HeaderAuth(log *slog.Logger) func(http.Handler) http.Handler {
+// maxIdentityAge — сколько действительна подпись заголовков шлюза
+const maxIdentityAge = time.Minute
+
+// HeaderAuth доверяет заголовкам пользователя, только если их подписал
+// api-gateway общим секретом (см. api-gateway/internal/lib/identity).
+// Без подписи или с пустым секретом запрос считается анонимным.
+func HeaderAuth(log *slog.Logger, secret string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			userID := r.Header.Get("X-User-ID")
 			username := r.Header.Get("X-Username")
+			role := r.Header.Get("X-User-Role")
 
 			if userID != "" {
+				digest, err := bodyDigest(r)
+				if err != nil || !verifyIdentity(secret, r.Method, r.URL.Path, r.URL.RawQuery, digest, userID, username, role,
+					r.Header.Get("X-Identity-Timestamp"), r.Header.Get("X-Identity-Signature"), time.Now()) {
+					log.Warn("Ignoring unsigned user headers",
+						slog.String("user_id", userID),
+						slog.String("remote_addr", r.RemoteAddr))
+
+					next.ServeHTTP(w, r)
+					return
+				}
+
 				log.Info("User authenticated",
 					slog.String("user_id", userID),
 					slog.String("username", username))
 
 				ctx := context.WithValue(r.Context(), UserIDContextKey, userID)
 				ctx = context.WithValue(ctx, UsernameContextKey, username)
+				ctx = context.WithValue(ctx, UserRoleContextKey, role)
 
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
@@ -36,6 +64,51 @@ func HeaderAuth(log *slog.Logger) func(http.Handler) http.Handler {
 	}
 }
 
+// verifyIdentity проверяет подпись шлюза: она покрывает метод, путь, строку
+// запроса и хеш тела, поэтому заголовки не переносятся на другой запрос
+func verifyIdentity(secret, method, path, rawQuery, bodyDigest, userID, username, role, timestamp, signature string, now time.Time) bool {
+	if secret == "" || timestamp == "" || signature == "" {
+		return false
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > maxIdentityAge || age < -maxIdentityAge {
+		return false
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + path + "\n" + rawQuery + "\n" + bodyDigest + "\n" +
+		userID + "\n" + username + "\n" + role + "\n" + timestamp))
+
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// bodyDigest читает тело запроса, возвращает на место копию и считает её
+// hex SHA-256 — так же, как api-gateway/internal/lib/identity.BodyDigest
+func bodyDigest(r *http.Request) (string, error) {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 func GetUserID(r *http.Request) (string, bool) {
 	userID, ok := r.Context().Value(UserIDContextKey).(string)
 	return userID, ok
@@ -45,3 +118,21 @@ func GetUsername(r *http.Request) (string, bool) {
 	username, ok := r.Context().Value(UsernameContextKey).(string)
 	return username, ok
 }
+
+func GetUserRole(r *http.Request) (string, bool) {
+	role, ok := r.Context().Value(UserRoleContextKey).(string)
+	return role, ok
+}
+
+// GetActor собирает пользователя из контекста запроса, заполненного HeaderAuth
+func GetActor(r *http.Request) (models.Actor, bool) {
+	userID, ok := GetUserID(r)
+	if !ok || userID == "" {
+		return models.Actor{}, false
+	}
+
+	username, _ := GetUsername(r)
+	role, _ := GetUserRole(r)
+
+	return models.Actor{UserID: userID, Username: username, Role: role}, true
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gatewaySignature — подпись api-gateway/internal/lib/identity.Sign для
+// запроса ниже (TestSignMatchesCatalog в шлюзе): при изменении формата
+// меняются оба теста
+const gatewaySignature = "9373238cbc51ab814b718f802ead88e70e58e5e49884f26133874543d50d96f4"
+
+// gatewayBodyDigest — хеш тела {"price":100} подписанного запроса
+const gatewayBodyDigest = "fbcfc7a6ca136b21ff0a7101016fc91230798ffc2e6f5fc856df2fc29adca67a"
+
+type signedRequest struct {
+	secret, method, path, query, digest, userID, signature string
+	now                                                    time.Time
+}
+
+func TestVerifyIdentityGatewayVector(t *testing.T) {
+	const (
+		username = "alice"
+		role     = "seller"
+	)
+	signedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	ts := strconv.FormatInt(signedAt.Unix(), 10)
+
+	tests := []struct {
+		name   string
+		modify func(*signedRequest)
+		want   bool
+	}{
+		{name: "signed by gateway", modify: func(*signedRequest) {}, want: true},
+		{name: "within max age", modify: func(r *signedRequest) { r.now = signedAt.Add(maxIdentityAge) }, want: true},
+		{name: "other method", modify: func(r *signedRequest) { r.method = http.MethodDelete }},
+		{name: "other path", modify: func(r *signedRequest) { r.path = "/comment/" }},
+		{name: "other query", modify: func(r *signedRequest) { r.query = "id=43" }},
+		{name: "other body", modify: func(r *signedRequest) { r.digest = strings.Repeat("0", 64) }},
+		{name: "other user", modify: func(r *signedRequest) { r.userID = "22222222-2222-2222-2222-222222222222" }},
+		{name: "other secret", modify: func(r *signedRequest) { r.secret = "attacker" }},
+		{name: "empty secret", modify: func(r *signedRequest) { r.secret = "" }},
+		{name: "expired", modify: func(r *signedRequest) { r.now = signedAt.Add(maxIdentityAge + time.Second) }},
+		{name: "not hex", modify: func(r *signedRequest) { r.signature = "zz" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := signedRequest{
+				secret:    "test-secret",
+				method:    http.MethodPut,
+				path:      "/product/",
+				query:     "id=42",
+				digest:    gatewayBodyDigest,
+				userID:    "11111111-1111-1111-1111-111111111111",
+				signature: gatewaySignature,
+				now:       signedAt,
+			}
+			tt.modify(&req)
+
+			got := verifyIdentity(req.secret, req.method, req.path, req.query, req.digest, req.userID, username, role, ts, req.signature, req.now)
+			if got != tt.want {
+				t.Fatalf("verifyIdentity = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBodyDigestRestoresBody(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPut, "/product/?id=42", strings.NewReader(`{"price":100}`))
+
+	digest, err := bodyDigest(r)
+	if err != nil {
+		t.Fatalf("bodyDigest: %v", err)
+	}
+	if digest != gatewayBodyDigest {
+		t.Fatalf("digest = %s, want %s", digest, gatewayBodyDigest)
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	if string(body) != `{"price":100}` {
+		t.Fatalf("body after digest = %q", body)
+	}
+}
@@ -1,21 +1,16 @@
 package middleware
 
 import (
+	"catalog/internal/models"
 	"context"
+	"events"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"time"
 )
 
-type Event struct {
-	URL       string    `json:"url"`
-	Action    string    `json:"action"`
-	Timestamp time.Time `json:"timestamp"`
-}
-
 type EventProducer interface {
-	Send(ctx context.Context, event any, topic string) error
+	Send(ctx context.Context, event events.Envelope, topic string) error
 }
 
 func LogEventMiddleware(log *slog.Logger, producer EventProducer) func(http.Handler) http.Handler {
@@ -23,15 +18,19 @@ func LogEventMiddleware(log *slog.Logger, producer EventProducer) func(http.Hand
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			urlPath := r.URL.Path
 			stringPath := urlPath[5 : len(urlPath)-1]
-			event := Event{
-				URL:       stringPath,
-				Action:    "open",
-				Timestamp: time.Now(),
-			}
 			log.Info(fmt.Sprintf("LogEventMiddleware: urlpath: %s", urlPath))
 
+			actor, _ := GetActor(r)
+			event, err := models.NewEvent(actor, events.UserAction{URL: stringPath, Action: "open"})
+			if err != nil {
+				log.Error("failed to build user event", slog.String("err", err.Error()))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Отправка переживает запрос, но остаётся в его трейсе
+			ctx := context.WithoutCancel(r.Context())
 			go func() {
-				ctx := context.Background()
 				if err := producer.Send(ctx, event, "user-events"); err != nil {
 					log.Error("failed to send event to Kafka", slog.String("err", err.Error()))
 				}
@@ -0,0 +1,15 @@
+package models
+
+const RoleAdmin = "admin"
+
+// Actor — пользователь, от имени которого выполняется запрос
+type Actor struct {
+	UserID   string
+	Username string
+	Role     string
+}
+
+// CanManage разрешает изменять запись её владельцу и администратору
+func (a Actor) CanManage(ownerID string) bool {
+	return a.Role == RoleAdmin || (ownerID != "" && ownerID == a.UserID)
+}
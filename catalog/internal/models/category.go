@@ -0,0 +1,8 @@
+package models
+
+type Category struct {
+	ID       string      `json:"id"`
+	Name     string      `json:"name"`
+	ParentID *string     `json:"parent_id,omitempty"`
+	Children []*Category `json:"children,omitempty"`
+}
@@ -1,7 +1,10 @@
 package models
 
+import "time"
+
 type CommentListView struct {
-	ID      string `json:"id"`
-	UserID  string `json:"user_id"`
-	Comment string `json:"comment"`
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -0,0 +1,14 @@
+package models
+
+type CommentSearchHit struct {
+	*CommentListView
+	ProductID  string              `json:"product_id"`
+	Score      float64             `json:"score"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+type CommentSearchResult struct {
+	Items      []*CommentSearchHit `json:"items"`
+	Total      int                 `json:"total"`
+	Pagination Pagination          `json:"pagination"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "events"
+
+// eventProducer — имя каталога в конверте событий
+const eventProducer = "catalog"
+
+// NewEvent оборачивает payload в конверт события от имени каталога.
+// Нулевой actor — анонимный пользователь.
+func NewEvent(actor Actor, payload events.Payload) (events.Envelope, error) {
+	var eventActor *events.Actor
+	if actor.UserID != "" {
+		eventActor = &events.Actor{UserID: actor.UserID}
+	}
+
+	return events.New(eventProducer, eventActor, payload)
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+const (
+	SortNewest    = "newest"
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
+	SortName      = "name"
+)
+
+// ProductFilter — условия выборки списка товаров
+type ProductFilter struct {
+	// CategoryID включает товары категории и всех её потомков
+	CategoryID   string
+	SellerName   string
+	MinPrice     *int
+	MaxPrice     *int
+	CreatedAfter *time.Time
+	// Sort — одно из значений Sort*, пустая строка означает SortNewest
+	Sort string
+}
@@ -0,0 +1,28 @@
+package models
+
+// ProductSearchFilter — полнотекстовый запрос и фильтры поиска товаров
+type ProductSearchFilter struct {
+	Query        string
+	CategoryName string
+	SellerName   string
+	MinPrice     *int
+	MaxPrice     *int
+}
+
+type ProductSearchHit struct {
+	*ProductView
+	Score      float64             `json:"score"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type ProductSearchResult struct {
+	Items      []*ProductSearchHit      `json:"items"`
+	Total      int                      `json:"total"`
+	Facets     map[string][]FacetBucket `json:"facets"`
+	Pagination Pagination               `json:"pagination"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// ProductUpdate — изменяемые поля товара, nil означает «не менять»
+type ProductUpdate struct {
+	Name         *string
+	Description  *string
+	Price        *int
+	CategoryName *string
+}
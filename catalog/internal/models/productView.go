@@ -9,5 +9,7 @@ type ProductView struct {
 	Price        int       `json:"price"`
 	SellerName   string    `json:"seller_name"`
 	CategoryName string    `json:"category_name,omitempty"`
+	Version      int       `json:"version"`
 	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
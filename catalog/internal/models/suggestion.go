@@ -0,0 +1,11 @@
+package models
+
+type Suggestion struct {
+	Text      string `json:"text"`
+	ProductID string `json:"product_id,omitempty"`
+}
+
+type Suggestions struct {
+	Products   []Suggestion `json:"products"`
+	Categories []Suggestion `json:"categories"`
+}
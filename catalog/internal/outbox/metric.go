@@ -0,0 +1,15 @@
+package outbox
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var failedMessages = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "outbox_failed_messages_total",
+		Help: "Outbox messages parked after exhausting delivery attempts",
+	},
+	[]string{"topic"},
+)
+
+func init() {
+	prometheus.MustRegister(failedMessages)
+}
@@ -0,0 +1,225 @@
+package outbox
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"events"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Message — строка таблицы outbox, ожидающая отправки в Kafka
+type Message struct {
+	ID       int64
+	Topic    string
+	Key      string
+	Payload  []byte
+	Attempts int
+	// TraceContext — trace context запроса, породившего событие; relay
+	// продолжает в нём трейс при отправке
+	TraceContext map[string]string
+}
+
+// Execer позволяет писать в outbox как через *sql.DB, так и внутри *sql.Tx
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Enqueue сохраняет событие в outbox. Вызывается в той же транзакции,
+// что и изменение данных, чтобы событие не потерялось при падении Kafka.
+func Enqueue(ctx context.Context, exec Execer, topic, key string, event events.Envelope) error {
+	const op = "outbox.Enqueue"
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%s: failed to marshal event: %w", op, err)
+	}
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	var traceContext []byte
+	if len(carrier) > 0 {
+		traceContext, err = json.Marshal(carrier)
+		if err != nil {
+			return fmt.Errorf("%s: failed to marshal trace context: %w", op, err)
+		}
+	}
+
+	const query = `
+		INSERT INTO outbox (topic, message_key, payload, trace_context)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	if _, err := exec.ExecContext(ctx, query, topic, key, payload, traceContext); err != nil {
+		return fmt.Errorf("%s: failed to insert event: %w", op, err)
+	}
+
+	return nil
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// claimLock — ключ advisory lock, под которым relay'и разбирают outbox:
+// так несколько экземпляров каталога не отправят события одной сущности
+// параллельно
+const claimLock = 7_102_001
+
+// Claim забирает до limit готовых к отправке сообщений и откладывает их
+// повторную выдачу на lease: за это время их нужно отправить и отметить.
+// Транзакция короткая — отправка в Kafka идёт уже без блокировок строк.
+//
+// Сообщение не выдаётся, пока более раннее сообщение с тем же ключом ждёт
+// повтора или отправляется: Hash-балансировщик сохраняет порядок событий
+// сущности, только если они уходят по порядку. Отложенные через Park
+// сообщения не выдаются и очередь ключа не держат.
+func (rep *Repository) Claim(ctx context.Context, limit int, lease time.Duration) ([]Message, error) {
+	const op = "outbox.repository.Claim"
+
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, claimLock); err != nil {
+		return nil, fmt.Errorf("%s: failed to lock outbox: %w", op, err)
+	}
+
+	const query = `
+		UPDATE outbox
+		SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT o.id
+			FROM outbox o
+			WHERE o.delivered_at IS NULL
+				AND o.failed_at IS NULL
+				AND o.next_attempt_at <= CURRENT_TIMESTAMP
+				AND NOT EXISTS (
+					SELECT 1
+					FROM outbox prev
+					WHERE prev.message_key = o.message_key
+						AND prev.id < o.id
+						AND prev.delivered_at IS NULL
+						AND prev.failed_at IS NULL
+						AND prev.next_attempt_at > CURRENT_TIMESTAMP
+				)
+			ORDER BY o.id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, topic, COALESCE(message_key, ''), payload, attempts, trace_context
+	`
+
+	rows, err := tx.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to claim messages: %w", op, err)
+	}
+
+	var messages []Message
+	for rows.Next() {
+		var (
+			m            Message
+			traceContext []byte
+		)
+		if err := rows.Scan(&m.ID, &m.Topic, &m.Key, &m.Payload, &m.Attempts, &traceContext); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("%s: failed to scan message: %w", op, err)
+		}
+		// Битый trace context не мешает доставке: событие уйдёт без него
+		if len(traceContext) > 0 {
+			_ = json.Unmarshal(traceContext, &m.TraceContext)
+		}
+		messages = append(messages, m)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: failed to read messages: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: failed to commit: %w", op, err)
+	}
+
+	// RETURNING не сохраняет порядок подзапроса
+	slices.SortFunc(messages, func(a, b Message) int { return cmp.Compare(a.ID, b.ID) })
+
+	return messages, nil
+}
+
+// MarkDelivered отмечает сообщения отправленными
+func (rep *Repository) MarkDelivered(ctx context.Context, ids []int64) error {
+	const op = "outbox.repository.MarkDelivered"
+
+	const query = `
+		UPDATE outbox SET delivered_at = CURRENT_TIMESTAMP, attempts = attempts + 1, last_error = NULL
+		WHERE id = ANY($1)
+	`
+
+	if _, err := rep.db.ExecContext(ctx, query, ids); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// MarkFailed засчитывает неудачную попытку и откладывает следующую на delay
+func (rep *Repository) MarkFailed(ctx context.Context, id int64, reason string, delay time.Duration) error {
+	const op = "outbox.repository.MarkFailed"
+
+	const query = `
+		UPDATE outbox
+		SET attempts = attempts + 1,
+			last_error = $2,
+			next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $3)
+		WHERE id = $1
+	`
+
+	if _, err := rep.db.ExecContext(ctx, query, id, reason, delay.Seconds()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Park засчитывает последнюю попытку и откладывает сообщение насовсем:
+// оно остаётся в outbox с failed_at и last_error. Вернуть его в очередь —
+// UPDATE outbox SET failed_at = NULL, attempts = 0 WHERE id = ...
+func (rep *Repository) Park(ctx context.Context, id int64, reason string) error {
+	const op = "outbox.repository.Park"
+
+	const query = `
+		UPDATE outbox
+		SET attempts = attempts + 1,
+			last_error = $2,
+			failed_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+
+	if _, err := rep.db.ExecContext(ctx, query, id, reason); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Release возвращает сообщения в очередь без попытки: они ждут более
+// раннее сообщение своего ключа, которое не удалось отправить
+func (rep *Repository) Release(ctx context.Context, ids []int64) error {
+	const op = "outbox.repository.Release"
+
+	if _, err := rep.db.ExecContext(ctx, `UPDATE outbox SET next_attempt_at = CURRENT_TIMESTAMP WHERE id = ANY($1)`, ids); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
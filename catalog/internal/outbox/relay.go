@@ -0,0 +1,227 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("catalog/internal/outbox")
+
+// Record — сообщение outbox, подготовленное к отправке
+type Record struct {
+	Topic string
+	Key   []byte
+	Value []byte
+	// Headers — trace context, в котором relay отправляет сообщение
+	Headers map[string]string
+}
+
+type Publisher interface {
+	// PublishBatch отправляет пачку одним запросом; i-я ошибка относится к
+	// i-й записи, nil — запись доставлена
+	PublishBatch(ctx context.Context, records []Record) []error
+}
+
+type RelayConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	MaxBackoff   time.Duration
+}
+
+// claimLease — на сколько relay забирает сообщения: если он упадёт, не
+// отметив их, через это время их отправит следующий
+const claimLease = time.Minute
+
+// Relay периодически вычитывает outbox и публикует события в Kafka
+type Relay struct {
+	repo      *Repository
+	publisher Publisher
+	cfg       RelayConfig
+	log       *slog.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRelay(log *slog.Logger, repo *Repository, publisher Publisher, cfg RelayConfig) *Relay {
+	return &Relay{
+		repo:      repo,
+		publisher: publisher,
+		cfg:       cfg,
+		log:       log.With(slog.String("component", "outbox.relay")),
+	}
+}
+
+func (r *Relay) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.run(ctx)
+	}()
+}
+
+// Stop останавливает воркер и дожидается завершения текущей итерации
+func (r *Relay) Stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *Relay) run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Пока outbox отдаёт полные пачки, продолжаем без ожидания
+		for r.relayBatch(ctx) == r.cfg.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayBatch забирает пачку из outbox, отправляет её в Kafka одним запросом
+// и отмечает результат. Возвращает число забранных сообщений.
+func (r *Relay) relayBatch(ctx context.Context) int {
+	const op = "outbox.relay.relayBatch"
+
+	messages, err := r.repo.Claim(ctx, r.cfg.BatchSize, claimLease)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.log.Error(op+": failed to claim outbox messages", slog.Any("err", err))
+		}
+		return 0
+	}
+	if len(messages) == 0 {
+		return 0
+	}
+
+	records := make([]Record, len(messages))
+	spans := make([]trace.Span, len(messages))
+	for i, m := range messages {
+		// Отправка продолжает трейс запроса, записавшего событие
+		msgCtx := otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(m.TraceContext))
+		msgCtx, spans[i] = tracer.Start(msgCtx, "outbox relay "+m.Topic)
+
+		headers := propagation.MapCarrier{}
+		otel.GetTextMapPropagator().Inject(msgCtx, headers)
+
+		records[i] = Record{Topic: m.Topic, Value: m.Payload, Headers: headers}
+		if m.Key != "" {
+			records[i].Key = []byte(m.Key)
+		}
+	}
+
+	errs := r.publisher.PublishBatch(ctx, records)
+
+	var (
+		delivered []int64
+		released  []int64
+		failedKey = make(map[string]bool)
+	)
+	for i, m := range messages {
+		err := errs[i]
+		if err == nil && m.Key != "" && failedKey[m.Key] {
+			err = errBlocked
+		}
+
+		switch {
+		case err == nil:
+			delivered = append(delivered, m.ID)
+		case errors.Is(err, errBlocked):
+			// Не засчитываем попытку: сообщение дождётся более раннего
+			released = append(released, m.ID)
+		case m.Attempts+1 >= r.cfg.MaxAttempts:
+			spans[i].RecordError(err)
+			spans[i].SetStatus(codes.Error, err.Error())
+			r.park(ctx, m, err)
+		default:
+			spans[i].RecordError(err)
+			spans[i].SetStatus(codes.Error, err.Error())
+			r.log.Warn(op+": failed to publish event",
+				slog.Int64("id", m.ID),
+				slog.String("topic", m.Topic),
+				slog.Int("attempt", m.Attempts+1),
+				slog.Any("err", err),
+			)
+			if err := r.repo.MarkFailed(ctx, m.ID, err.Error(), r.backoff(m.Attempts+1)); err != nil {
+				r.log.Error(op+": failed to record attempt", slog.Int64("id", m.ID), slog.Any("err", err))
+			}
+		}
+		if err != nil && m.Key != "" {
+			failedKey[m.Key] = true
+		}
+		spans[i].End()
+	}
+
+	if len(released) > 0 {
+		if err := r.repo.Release(ctx, released); err != nil {
+			r.log.Error(op+": failed to release messages", slog.Any("err", err))
+		}
+	}
+	if len(delivered) > 0 {
+		if err := r.repo.MarkDelivered(ctx, delivered); err != nil {
+			// Сообщения уйдут повторно после claimLease; получатели
+			// идемпотентны по id события
+			r.log.Error(op+": failed to mark messages delivered", slog.Any("err", err))
+		} else {
+			r.log.Debug(op+": events published", slog.Int("count", len(delivered)))
+		}
+	}
+
+	return len(messages)
+}
+
+// park откладывает сообщение, исчерпавшее попытки: оно остаётся в outbox
+// со статусом failed и учитывается в outbox_failed_messages_total
+func (r *Relay) park(ctx context.Context, m Message, err error) {
+	const op = "outbox.relay.park"
+
+	r.log.Error(op+": event failed after all attempts, parked in outbox",
+		slog.Int64("id", m.ID),
+		slog.String("topic", m.Topic),
+		slog.Int("attempts", m.Attempts+1),
+		slog.Any("err", err),
+	)
+	failedMessages.WithLabelValues(m.Topic).Inc()
+
+	if err := r.repo.Park(ctx, m.ID, err.Error()); err != nil {
+		r.log.Error(op+": failed to park message", slog.Int64("id", m.ID), slog.Any("err", err))
+	}
+}
+
+// errBlocked — сообщение идёт после неотправленного сообщения того же ключа
+var errBlocked = errors.New("earlier message with the same key was not delivered")
+
+// backoff — экспоненциальная задержка между попытками, ограниченная MaxBackoff
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempts && delay < r.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.cfg.MaxBackoff {
+		delay = r.cfg.MaxBackoff
+	}
+	return delay
+}
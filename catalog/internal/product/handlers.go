@@ -2,19 +2,25 @@ package product
 
 import (
 	"catalog/internal/lib/handlers/response"
+	"catalog/internal/lib/pagination"
+	"catalog/internal/middleware"
 	"catalog/internal/models"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type ProductListViewer interface {
-	ViewListProducts(context.Context, int, int) ([]*models.ProductListView, error)
+	ViewListProducts(context.Context, models.ProductFilter, pagination.Request) (*models.Page[*models.ProductListView], error)
 }
 
 func ViewListProducts(log *slog.Logger, productListViewer ProductListViewer) http.HandlerFunc {
@@ -23,24 +29,26 @@ func ViewListProducts(log *slog.Logger, productListViewer ProductListViewer) htt
 
 		q := r.URL.Query()
 
-		offsetStr := q.Get("offset")
-		limitStr := q.Get("limit")
-
-		offset, err := strconv.Atoi(offsetStr)
-		if err != nil || offset < 0 {
-			log.Warn(op+": invalid offset", slog.String("offset", offsetStr), slog.Any("err", err))
-			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'offset' parameter")
+		page, param, err := pagination.ParseRequest(q)
+		if err != nil {
+			log.Warn(op+": invalid pagination", slog.String("param", param), slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusBadRequest, fmt.Sprintf("invalid '%s' parameter", param))
 			return
 		}
 
-		limit, err := strconv.Atoi(limitStr)
-		if err != nil || limit <= 0 {
-			log.Warn(op+": invalid limit", slog.String("limit", limitStr), slog.Any("err", err))
-			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'limit' parameter")
+		filter, param, err := parseProductFilter(q)
+		if err != nil {
+			log.Warn(op+": invalid filter", slog.String("param", param), slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusBadRequest, fmt.Sprintf("invalid '%s' parameter", param))
 			return
 		}
 
-		products, err := productListViewer.ViewListProducts(r.Context(), offset, limit)
+		products, err := productListViewer.ViewListProducts(r.Context(), filter, page)
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			log.Warn(op+": cursor does not match request", slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'cursor' parameter")
+			return
+		}
 		if err != nil {
 			log.Error(op+": failed to get products", slog.Any("err", err))
 			response.RespondWithError(w, log, http.StatusInternalServerError, "failed to get products")
@@ -51,6 +59,67 @@ func ViewListProducts(log *slog.Logger, productListViewer ProductListViewer) htt
 	}
 }
 
+// parseProductFilter разбирает параметры фильтрации и сортировки списка.
+// При ошибке возвращает имя некорректного параметра.
+func parseProductFilter(q url.Values) (models.ProductFilter, string, error) {
+	var filter models.ProductFilter
+
+	if categoryStr := q.Get("category_id"); categoryStr != "" {
+		categoryID, err := uuid.Parse(categoryStr)
+		if err != nil {
+			return filter, "category_id", err
+		}
+		filter.CategoryID = categoryID.String()
+	}
+
+	filter.SellerName = q.Get("seller")
+
+	if param, err := parsePriceRange(q, &filter.MinPrice, &filter.MaxPrice); err != nil {
+		return filter, param, err
+	}
+
+	if createdStr := q.Get("created_after"); createdStr != "" {
+		createdAfter, err := time.Parse(time.RFC3339, createdStr)
+		if err != nil {
+			return filter, "created_after", err
+		}
+		filter.CreatedAfter = &createdAfter
+	}
+
+	filter.Sort = q.Get("sort")
+	if !isValidSort(filter.Sort) {
+		return filter, "sort", fmt.Errorf("unsupported sort %q", filter.Sort)
+	}
+
+	return filter, "", nil
+}
+
+func parsePriceRange(q url.Values, minPrice, maxPrice **int) (string, error) {
+	for _, p := range []struct {
+		name string
+		dst  **int
+	}{
+		{"min_price", minPrice},
+		{"max_price", maxPrice},
+	} {
+		str := q.Get(p.name)
+		if str == "" {
+			continue
+		}
+		v, err := strconv.Atoi(str)
+		if err != nil || v < 0 {
+			return p.name, fmt.Errorf("price must be a non-negative integer: %q", str)
+		}
+		*p.dst = &v
+	}
+
+	if *minPrice != nil && *maxPrice != nil && **minPrice > **maxPrice {
+		return "min_price", fmt.Errorf("min_price is greater than max_price")
+	}
+
+	return "", nil
+}
+
 type ProductViewer interface {
 	ViewProduct(context.Context, string) (*models.ProductView, error)
 }
@@ -89,6 +158,10 @@ const (
 	errAddProduct        = "failed to add product"
 	ErrInsufficientFunds = "insufficient balance to send coin"
 	successAddproduct    = "add product successfully"
+	errUpdateProduct     = "failed to update product"
+	successUpdateProduct = "update product successfully"
+	errDeleteProduct     = "failed to delete product"
+	successDeleteProduct = "delete product successfully"
 )
 
 type RequestAddProduct struct {
@@ -96,28 +169,28 @@ type RequestAddProduct struct {
 	Description  string `json:"description"`
 	Price        int    `json:"price"`
 	CategoryName string `json:"categoryName"`
-	SellerName   string `json:"sellerName"`
 }
 
 type ProductAdder interface {
-	AddProduct(context.Context, string, string, string, string, int) error
+	AddProduct(context.Context, models.Actor, string, string, string, int) error
 }
 
 func AddProduct(log *slog.Logger, productAdder ProductAdder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "product.handlers.AddProduct"
 
-		// userID, ok := middleware.GetUserID(r)
-		// if !ok {
-		// 	log.Warn(warnGetUserID)
-
-		// 	response.RespondWithError(w, log, http.StatusUnauthorized, messageUnauthorized)
-		// }
-
 		log := log.With(
 			slog.String("op", op),
 		)
 
+		actor, ok := middleware.GetActor(r)
+		if !ok {
+			log.Warn(warnGetUserID)
+
+			response.RespondWithError(w, log, http.StatusUnauthorized, messageUnauthorized)
+			return
+		}
+
 		var req RequestAddProduct
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			log.Error(fmt.Errorf("failed to decode request body: Error: %w", err).Error())
@@ -126,7 +199,7 @@ func AddProduct(log *slog.Logger, productAdder ProductAdder) http.HandlerFunc {
 			return
 		}
 
-		if err := productAdder.AddProduct(r.Context(), req.SellerName, req.Name, req.Description, req.CategoryName, req.Price); err != nil {
+		if err := productAdder.AddProduct(r.Context(), actor, req.Name, req.Description, req.CategoryName, req.Price); err != nil {
 			log.Error(fmt.Errorf("%s Error: %w", errAddProduct, err).Error())
 
 			// if errors.Is(err, wallet.ErrInsufficientFunds) {
@@ -145,24 +218,283 @@ func AddProduct(log *slog.Logger, productAdder ProductAdder) http.HandlerFunc {
 	}
 }
 
+type RequestUpdateProduct struct {
+	ID           string  `json:"id"`
+	Version      int     `json:"version"`
+	Name         *string `json:"name"`
+	Description  *string `json:"description"`
+	Price        *int    `json:"price"`
+	CategoryName *string `json:"categoryName"`
+}
+
+type ProductUpdater interface {
+	UpdateProduct(context.Context, models.Actor, string, models.ProductUpdate, int) (*models.ProductView, error)
+}
+
+// UpdateProduct обрабатывает PUT (все поля обязательны) и PATCH (только переданные поля)
+func UpdateProduct(log *slog.Logger, productUpdater ProductUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "product.handlers.UpdateProduct"
+
+		log := log.With(
+			slog.String("op", op),
+		)
+
+		actor, ok := middleware.GetActor(r)
+		if !ok {
+			log.Warn(warnGetUserID)
+
+			response.RespondWithError(w, log, http.StatusUnauthorized, messageUnauthorized)
+			return
+		}
+
+		var req RequestUpdateProduct
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error(fmt.Errorf("failed to decode request body: Error: %w", err).Error())
+
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid request")
+			return
+		}
+
+		id, err := uuid.Parse(req.ID)
+		if err != nil {
+			log.Warn(op+": invalid UUID", slog.String("id", req.ID), slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'id' parameter")
+			return
+		}
+
+		if req.Version <= 0 {
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'version' parameter")
+			return
+		}
+
+		if r.Method == http.MethodPut &&
+			(req.Name == nil || req.Description == nil || req.Price == nil || req.CategoryName == nil) {
+			response.RespondWithError(w, log, http.StatusBadRequest, "PUT requires name, description, price and categoryName")
+			return
+		}
+
+		if req.Name != nil && *req.Name == "" {
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'name' parameter")
+			return
+		}
+
+		if req.Price != nil && *req.Price < 0 {
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'price' parameter")
+			return
+		}
+
+		update := models.ProductUpdate{
+			Name:         req.Name,
+			Description:  req.Description,
+			Price:        req.Price,
+			CategoryName: req.CategoryName,
+		}
+
+		product, err := productUpdater.UpdateProduct(r.Context(), actor, id.String(), update, req.Version)
+		if err != nil {
+			log.Error(fmt.Errorf("%s Error: %w", errUpdateProduct, err).Error())
+			respondWithEditError(w, log, err, errUpdateProduct)
+			return
+		}
+
+		log.Info(successUpdateProduct, slog.String("id", product.ID))
+
+		response.RespondWithJSON(w, log, http.StatusOK, product)
+	}
+}
+
+type ProductDeleter interface {
+	DeleteProduct(context.Context, models.Actor, string, int) error
+}
+
+func DeleteProduct(log *slog.Logger, productDeleter ProductDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "product.handlers.DeleteProduct"
+
+		log := log.With(
+			slog.String("op", op),
+		)
+
+		actor, ok := middleware.GetActor(r)
+		if !ok {
+			log.Warn(warnGetUserID)
+
+			response.RespondWithError(w, log, http.StatusUnauthorized, messageUnauthorized)
+			return
+		}
+
+		q := r.URL.Query()
+
+		idStr := q.Get("id")
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			log.Warn(op+": invalid UUID", slog.String("id", idStr), slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'id' parameter")
+			return
+		}
+
+		versionStr := q.Get("version")
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version <= 0 {
+			log.Warn(op+": invalid version", slog.String("version", versionStr), slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'version' parameter")
+			return
+		}
+
+		if err := productDeleter.DeleteProduct(r.Context(), actor, id.String(), version); err != nil {
+			log.Error(fmt.Errorf("%s Error: %w", errDeleteProduct, err).Error())
+			respondWithEditError(w, log, err, errDeleteProduct)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+		log.Info(successDeleteProduct, slog.String("id", id.String()))
+	}
+}
+
+func respondWithEditError(w http.ResponseWriter, log *slog.Logger, err error, message string) {
+	switch {
+	case errors.Is(err, ErrProductNotFound):
+		response.RespondWithError(w, log, http.StatusNotFound, "product not found")
+	case errors.Is(err, ErrForbidden):
+		response.RespondWithError(w, log, http.StatusForbidden, "product belongs to another seller")
+	case errors.Is(err, ErrCategoryNotFound):
+		response.RespondWithError(w, log, http.StatusBadRequest, "category not found")
+	case errors.Is(err, ErrVersionConflict):
+		response.RespondWithError(w, log, http.StatusConflict, "product was modified, reload it and retry")
+	default:
+		response.RespondWithError(w, log, http.StatusInternalServerError, message)
+	}
+}
+
+const (
+	defaultSearchLimit  = 20
+	defaultSuggestLimit = 5
+	maxSuggestLimit     = 10
+	maxSuggestPrefixLen = 100
+	suggestTimeout      = 300 * time.Millisecond
+)
+
 type ProductSearcher interface {
-	SearchProduct(context.Context, string) ([]*models.ProductView, error)
+	SearchProduct(context.Context, models.ProductSearchFilter, pagination.Request) (*models.ProductSearchResult, error)
 }
 
 func SearchProduct(log *slog.Logger, ProductSearcher ProductSearcher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		const op = "product.handlers.ViewProduct"
+		const op = "product.handlers.SearchProduct"
+
+		q := r.URL.Query()
+
+		filter, page, param, err := parseSearchRequest(q)
+		if err != nil {
+			log.Warn(op+": invalid search request", slog.String("param", param), slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusBadRequest, fmt.Sprintf("invalid '%s' parameter", param))
+			return
+		}
 
-		request := r.URL.Query().Get("req")
-		fmt.Println(request)
+		if filter.Query == "" && filter.CategoryName == "" && filter.SellerName == "" &&
+			filter.MinPrice == nil && filter.MaxPrice == nil {
+			response.RespondWithJSON(w, log, http.StatusOK, &models.ProductSearchResult{
+				Items:      []*models.ProductSearchHit{},
+				Facets:     map[string][]models.FacetBucket{},
+				Pagination: models.Pagination{Limit: page.Limit, Offset: &page.Offset},
+			})
+			return
+		}
 
-		products, err := ProductSearcher.SearchProduct(r.Context(), request)
+		result, err := ProductSearcher.SearchProduct(r.Context(), filter, page)
 		if err != nil {
 			log.Error(op+": failed to get products", slog.Any("err", err))
 			response.RespondWithError(w, log, http.StatusInternalServerError, "failed to get products")
 			return
 		}
 
-		response.RespondWithJSON(w, log, http.StatusOK, products)
+		response.RespondWithJSON(w, log, http.StatusOK, result)
+	}
+}
+
+// parseSearchRequest разбирает запрос поиска: req, category, seller,
+// min_price, max_price, offset и limit (по умолчанию 20)
+func parseSearchRequest(q url.Values) (models.ProductSearchFilter, pagination.Request, string, error) {
+	filter := models.ProductSearchFilter{
+		Query:        strings.TrimSpace(q.Get("req")),
+		CategoryName: q.Get("category"),
+		SellerName:   q.Get("seller"),
+	}
+	page := pagination.Request{Limit: defaultSearchLimit}
+
+	if param, err := parsePriceRange(q, &filter.MinPrice, &filter.MaxPrice); err != nil {
+		return filter, page, param, err
+	}
+
+	if limitStr := q.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > pagination.MaxLimit {
+			return filter, page, "limit", fmt.Errorf("limit must be in [1, %d]: %q", pagination.MaxLimit, limitStr)
+		}
+		page.Limit = limit
+	}
+
+	if offsetStr := q.Get("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 || offset+page.Limit > maxResultWindow {
+			return filter, page, "offset", fmt.Errorf("offset must be in [0, %d]: %q", maxResultWindow-page.Limit, offsetStr)
+		}
+		page.Offset = offset
+	}
+
+	return filter, page, "", nil
+}
+
+type ProductSuggester interface {
+	SuggestProduct(context.Context, string, int) (*models.Suggestions, error)
+}
+
+func SuggestProduct(log *slog.Logger, productSuggester ProductSuggester) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "product.handlers.SuggestProduct"
+
+		q := r.URL.Query()
+
+		prefix := strings.TrimSpace(q.Get("req"))
+		if len([]rune(prefix)) > maxSuggestPrefixLen {
+			response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'req' parameter")
+			return
+		}
+
+		size := defaultSuggestLimit
+		if sizeStr := q.Get("limit"); sizeStr != "" {
+			v, err := strconv.Atoi(sizeStr)
+			if err != nil || v <= 0 || v > maxSuggestLimit {
+				log.Warn(op+": invalid limit", slog.String("limit", sizeStr), slog.Any("err", err))
+				response.RespondWithError(w, log, http.StatusBadRequest, "invalid 'limit' parameter")
+				return
+			}
+			size = v
+		}
+
+		if prefix == "" {
+			response.RespondWithJSON(w, log, http.StatusOK, &models.Suggestions{
+				Products:   []models.Suggestion{},
+				Categories: []models.Suggestion{},
+			})
+			return
+		}
+
+		// Подсказки полезны только пока пользователь печатает: медленный
+		// ответ лучше оборвать, чем задержать следующий запрос
+		ctx, cancel := context.WithTimeout(r.Context(), suggestTimeout)
+		defer cancel()
+
+		suggestions, err := productSuggester.SuggestProduct(ctx, prefix, size)
+		if err != nil {
+			log.Error(op+": failed to get suggestions", slog.Any("err", err))
+			response.RespondWithError(w, log, http.StatusInternalServerError, "failed to get suggestions")
+			return
+		}
+
+		response.RespondWithJSON(w, log, http.StatusOK, suggestions)
 	}
 }
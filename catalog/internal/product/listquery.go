@@ -0,0 +1,161 @@
+package product
+
+import (
+	"catalog/internal/lib/pagination"
+	"catalog/internal/models"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sortSpec описывает разрешённую сортировку: колонку ключа, направление,
+// а также как извлечь ключ из строки и восстановить его из курсора
+type sortSpec struct {
+	column string
+	desc   bool
+	key    func(*models.ProductListView) string
+	parse  func(string) (any, error)
+}
+
+// sortSpecs — белый список сортировок. В SQL подставляются только эти
+// колонки, значение из запроса используется лишь как ключ. id в конце
+// делает порядок детерминированным при совпадении основного ключа.
+var sortSpecs = map[string]sortSpec{
+	models.SortNewest: {
+		column: "p.created_at",
+		desc:   true,
+		key:    func(p *models.ProductListView) string { return p.CreatedAt.Format(time.RFC3339Nano) },
+		parse:  func(s string) (any, error) { return time.Parse(time.RFC3339Nano, s) },
+	},
+	models.SortPriceAsc: {
+		column: "p.price",
+		key:    func(p *models.ProductListView) string { return strconv.Itoa(p.Price) },
+		parse:  func(s string) (any, error) { return strconv.Atoi(s) },
+	},
+	models.SortPriceDesc: {
+		column: "p.price",
+		desc:   true,
+		key:    func(p *models.ProductListView) string { return strconv.Itoa(p.Price) },
+		parse:  func(s string) (any, error) { return strconv.Atoi(s) },
+	},
+	models.SortName: {
+		column: "p.name",
+		key:    func(p *models.ProductListView) string { return p.Name },
+		parse:  func(s string) (any, error) { return s, nil },
+	},
+}
+
+func isValidSort(sort string) bool {
+	_, ok := sortSpecs[sort]
+	return sort == "" || ok
+}
+
+func effectiveSort(sort string) string {
+	if sort == "" {
+		return models.SortNewest
+	}
+	return sort
+}
+
+// listQuery собирает запрос списка товаров; значения фильтров передаются
+// только через плейсхолдеры
+type listQuery struct {
+	with  string
+	where []string
+	args  []any
+}
+
+func (q *listQuery) arg(v any) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// buildListQuery выбирает на одну строку больше page.Limit, чтобы
+// определить, есть ли следующая страница
+func buildListQuery(filter models.ProductFilter, page pagination.Request) (string, []any, error) {
+	sort := effectiveSort(filter.Sort)
+
+	spec, ok := sortSpecs[sort]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported sort %q", filter.Sort)
+	}
+
+	var q listQuery
+
+	if filter.CategoryID != "" {
+		// Категория раскрывается в поддерево через рекурсивный CTE
+		q.with = fmt.Sprintf(`
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM categories WHERE id = %s
+			UNION ALL
+			SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+		)`, q.arg(filter.CategoryID))
+		q.where = append(q.where, "p.category_id IN (SELECT id FROM subtree)")
+	}
+
+	if filter.SellerName != "" {
+		q.where = append(q.where, "p.seller_name = "+q.arg(filter.SellerName))
+	}
+
+	if filter.MinPrice != nil {
+		q.where = append(q.where, "p.price >= "+q.arg(*filter.MinPrice))
+	}
+
+	if filter.MaxPrice != nil {
+		q.where = append(q.where, "p.price <= "+q.arg(*filter.MaxPrice))
+	}
+
+	if filter.CreatedAfter != nil {
+		q.where = append(q.where, "p.created_at > "+q.arg(*filter.CreatedAfter))
+	}
+
+	direction, cmp := "ASC", ">"
+	if spec.desc {
+		direction, cmp = "DESC", "<"
+	}
+
+	if page.Cursor != nil {
+		if page.Cursor.Sort != sort {
+			return "", nil, pagination.ErrInvalidCursor
+		}
+
+		key, err := spec.parse(page.Cursor.Key)
+		if err != nil {
+			return "", nil, pagination.ErrInvalidCursor
+		}
+
+		q.where = append(q.where, fmt.Sprintf("(%s, p.id) %s (%s, %s)",
+			spec.column, cmp, q.arg(key), q.arg(page.Cursor.ID)))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(q.with)
+	sb.WriteString(`
+		SELECT p.id, p.name, p.price, p.created_at
+		FROM products p`)
+
+	if len(q.where) > 0 {
+		sb.WriteString("\n\t\tWHERE ")
+		sb.WriteString(strings.Join(q.where, " AND "))
+	}
+
+	fmt.Fprintf(&sb, "\n\t\tORDER BY %s %s, p.id %s", spec.column, direction, direction)
+
+	if page.Cursor == nil {
+		sb.WriteString("\n\t\tOFFSET " + q.arg(page.Offset))
+	}
+	sb.WriteString(" LIMIT " + q.arg(page.Limit+1))
+
+	return sb.String(), q.args, nil
+}
+
+// nextCursor строит курсор по последнему элементу страницы
+func nextCursor(sort string, last *models.ProductListView) string {
+	sort = effectiveSort(sort)
+	return pagination.Encode(pagination.Cursor{
+		Sort: sort,
+		Key:  sortSpecs[sort].key(last),
+		ID:   last.ID,
+	})
+}
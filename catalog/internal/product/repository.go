@@ -1,47 +1,65 @@
 package product
 
 import (
-	"bytes"
+	"catalog/internal/lib/pagination"
 	"catalog/internal/models"
+	"catalog/internal/outbox"
+	"catalog/internal/search"
 	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
+	"events"
 	"fmt"
+	"time"
 
-	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/google/uuid"
 )
 
+const productEventsTopic = "product-events"
+
+var (
+	ErrProductNotFound  = errors.New("product not found")
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrVersionConflict  = errors.New("product was modified concurrently")
+	ErrForbidden        = errors.New("product belongs to another seller")
+)
+
 type ProductRepository struct {
-	db *sql.DB
-	es *elasticsearch.Client
+	db            *sql.DB
+	search        *search.Client
+	productsIndex string
 }
 
-func NewRepository(db *sql.DB, es *elasticsearch.Client) *ProductRepository {
-	return &ProductRepository{db: db, es: es}
+func NewRepository(db *sql.DB, search *search.Client, productsIndex string) *ProductRepository {
+	return &ProductRepository{db: db, search: search, productsIndex: productsIndex}
 }
 
-func (rep *ProductRepository) ViewListProducts(ctx context.Context, offset int, limit int) ([]*models.ProductListView, []string, error) {
-	const query = `
-		SELECT id, name, price
-		FROM products
-		OFFSET $1 LIMIT $2
-	`
+func (rep *ProductRepository) ViewListProducts(ctx context.Context, filter models.ProductFilter, page pagination.Request) (*models.Page[*models.ProductListView], []string, error) {
+	query, args, err := buildListQuery(filter, page)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	rows, err := rep.db.QueryContext(ctx, query, offset, limit)
+	rows, err := rep.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, nil, err
 	}
 	defer rows.Close()
 
 	var (
-		products []*models.ProductListView
+		products = make([]*models.ProductListView, 0, page.Limit)
 		ids      []string
+		hasMore  bool
 	)
 
 	for rows.Next() {
+		if len(products) == page.Limit {
+			hasMore = true
+			break
+		}
+
 		var p models.ProductListView
-		err := rows.Scan(&p.ID, &p.Name, &p.Price)
+		err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.CreatedAt)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -53,106 +71,184 @@ func (rep *ProductRepository) ViewListProducts(ctx context.Context, offset int,
 		return nil, nil, err
 	}
 
-	return products, ids, nil
-}
-
-func (rep *ProductRepository) SearchProduct(ctx context.Context, req string) ([]*models.ProductView, []string, error) {
-	if req == "" {
-		return nil, nil, nil
+	result := &models.Page[*models.ProductListView]{
+		Items: products,
+		Pagination: models.Pagination{
+			Limit:   page.Limit,
+			HasMore: hasMore,
+		},
 	}
 
-	if rep.es == nil {
-		return nil, nil, fmt.Errorf("elasticsearch client is not initialized")
+	if page.Cursor == nil {
+		result.Pagination.Offset = &page.Offset
 	}
 
-	esQuery := map[string]interface{}{
-		"query": map[string]interface{}{
-			"multi_match": map[string]interface{}{
-				"query":     req,
-				"fields":    []string{"title^2", "description"},
-				"type":      "best_fields",
-				"fuzziness": "AUTO",
-			},
-		},
-		"size": 50,
+	if hasMore {
+		result.Pagination.NextCursor = nextCursor(filter.Sort, products[len(products)-1])
 	}
 
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(esQuery); err != nil {
-		return nil, nil, fmt.Errorf("error encoding query: %w", err)
+	return result, ids, nil
+}
+
+func (rep *ProductRepository) SearchProduct(ctx context.Context, filter models.ProductSearchFilter, page pagination.Request) (*models.ProductSearchResult, []string, error) {
+	const op = "product.repository.SearchProduct"
+
+	res, err := search.Search[productDocument](ctx, rep.search, rep.productsIndex, buildSearchRequest(filter, page))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	fmt.Printf("Elasticsearch query: %s\n", buf.String())
+	ids := make([]string, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		id := hit.Source.ProductID
+		if id == "" {
+			id = hit.ID
+		}
+		ids = append(ids, id)
+	}
 
-	res, err := rep.es.Search(
-		rep.es.Search.WithContext(ctx),
-		rep.es.Search.WithIndex("products"),
-		rep.es.Search.WithBody(&buf),
-	)
+	products, err := rep.ViewProductsByIDs(ctx, ids)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error searching in elasticsearch: %w", err)
+		return nil, nil, fmt.Errorf("%s: failed to hydrate products: %w", op, err)
 	}
-	defer res.Body.Close()
 
-	if res.IsError() {
-		var e map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
-			return nil, nil, fmt.Errorf("error parsing the elasticsearch response body: %w", err)
+	// Порядок релевантности из ES сохраняется; документы, которых уже нет
+	// в Postgres, пропускаются
+	items := make([]*models.ProductSearchHit, 0, len(ids))
+	found := make([]string, 0, len(ids))
+	for i, hit := range res.Hits.Hits {
+		product, ok := products[ids[i]]
+		if !ok {
+			continue
 		}
-		return nil, nil, fmt.Errorf("elasticsearch error: %v", e)
+		items = append(items, &models.ProductSearchHit{
+			ProductView: product,
+			Score:       hit.Score,
+			Highlights:  hit.Highlight,
+		})
+		found = append(found, ids[i])
 	}
 
-	var r map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		return nil, nil, fmt.Errorf("error parsing the elasticsearch response body: %w", err)
+	offset := page.Offset
+	result := &models.ProductSearchResult{
+		Items:  items,
+		Total:  res.Hits.Total.Value,
+		Facets: facetsFromAggregations(res.Aggregations),
+		Pagination: models.Pagination{
+			Limit:   page.Limit,
+			Offset:  &offset,
+			HasMore: page.Offset+len(res.Hits.Hits) < res.Hits.Total.Value,
+		},
 	}
 
-	totalHits, _ := r["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64)
-	fmt.Printf("Elasticsearch search results: total hits = %.0f\n", totalHits)
+	return result, found, nil
+}
 
-	var products []*models.ProductView
-	var ids []string
+func (rep *ProductRepository) SuggestProduct(ctx context.Context, prefix string, size int) (*models.Suggestions, error) {
+	const op = "product.repository.SuggestProduct"
 
-	hits, _ := r["hits"].(map[string]interface{})
-	if hits == nil {
-		return nil, nil, nil
+	res, err := search.Search[productDocument](ctx, rep.search, rep.productsIndex, buildSuggestRequest(prefix, size))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	hitsList, _ := hits["hits"].([]interface{})
-	if hitsList == nil {
-		return nil, nil, nil
+	suggestions := &models.Suggestions{
+		Products:   []models.Suggestion{},
+		Categories: []models.Suggestion{},
 	}
 
-	for _, hit := range hitsList {
-		h, _ := hit.(map[string]interface{})
-		if h == nil {
-			continue
+	for _, entry := range res.Suggest[suggestProducts] {
+		for _, opt := range entry.Options {
+			productID := opt.Source.ProductID
+			if productID == "" {
+				productID = opt.ID
+			}
+			suggestions.Products = append(suggestions.Products, models.Suggestion{
+				Text:      opt.Text,
+				ProductID: productID,
+			})
 		}
+	}
 
-		source, _ := h["_source"].(map[string]interface{})
-		if source == nil {
-			continue
+	for _, entry := range res.Suggest[suggestCategories] {
+		for _, opt := range entry.Options {
+			suggestions.Categories = append(suggestions.Categories, models.Suggestion{Text: opt.Text})
 		}
+	}
 
-		productID, _ := source["product_id"].(string)
+	return suggestions, nil
+}
+
+// ViewProductsByIDs загружает товары одним запросом
+func (rep *ProductRepository) ViewProductsByIDs(ctx context.Context, ids []string) (map[string]*models.ProductView, error) {
+	products := make(map[string]*models.ProductView, len(ids))
+	if len(ids) == 0 {
+		return products, nil
+	}
+
+	const query = `
+		SELECT 
+			p.id,
+			p.name,
+			p.description,
+			p.price,
+			p.seller_name,
+			c.name,
+			p.version,
+			p.created_at,
+			p.updated_at
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE p.id = ANY($1)
+	`
 
-		fmt.Printf("Found product in Elasticsearch: ID=%s, source=%v\n", productID, source)
+	rows, err := rep.db.QueryContext(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-		product, err := rep.ViewProduct(ctx, productID)
+	for rows.Next() {
+		var (
+			product      models.ProductView
+			description  sql.NullString
+			categoryName sql.NullString
+		)
+		err := rows.Scan(
+			&product.ID,
+			&product.Name,
+			&description,
+			&product.Price,
+			&product.SellerName,
+			&categoryName,
+			&product.Version,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+		)
 		if err != nil {
-			fmt.Printf("Error getting details for product %s: %v\n", productID, err)
-			continue
+			return nil, err
 		}
+		product.Description = description.String
+		product.CategoryName = categoryName.String
+		products[product.ID] = &product
+	}
 
-		products = append(products, product)
-		ids = append(ids, productID)
-
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return products, ids, nil
+	return products, nil
 }
 
 func (rep *ProductRepository) ViewProduct(ctx context.Context, id string) (*models.ProductView, error) {
+	return viewProduct(ctx, rep.db, id)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func viewProduct(ctx context.Context, db queryRower, id string) (*models.ProductView, error) {
 	const query = `
 		SELECT 
 			p.id,
@@ -161,22 +257,30 @@ func (rep *ProductRepository) ViewProduct(ctx context.Context, id string) (*mode
 			p.price,
 			p.seller_name,
 			c.name,
-			p.created_at
+			p.version,
+			p.created_at,
+			p.updated_at
 		FROM products p
 		LEFT JOIN categories c ON p.category_id = c.id
 		WHERE p.id = $1
 	`
 
-	var product models.ProductView
+	var (
+		product      models.ProductView
+		description  sql.NullString
+		categoryName sql.NullString
+	)
 
-	err := rep.db.QueryRowContext(ctx, query, id).Scan(
+	err := db.QueryRowContext(ctx, query, id).Scan(
 		&product.ID,
 		&product.Name,
-		&product.Description,
+		&description,
 		&product.Price,
 		&product.SellerName,
-		&product.CategoryName,
+		&categoryName,
+		&product.Version,
 		&product.CreatedAt,
+		&product.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -186,19 +290,91 @@ func (rep *ProductRepository) ViewProduct(ctx context.Context, id string) (*mode
 		return nil, err
 	}
 
+	product.Description = description.String
+	product.CategoryName = categoryName.String
+
 	return &product, nil
 }
 
-func (rep *ProductRepository) AddProduct(ctx context.Context, sellerName, name, description, categoryName string, price int) (uuid.UUID, error) {
+// StreamProducts построчно читает товары, изменённые начиная с since
+// (нулевое время — все товары), и передаёт их в fn
+func (rep *ProductRepository) StreamProducts(ctx context.Context, since time.Time, fn func(*models.ProductView) error) error {
+	const op = "product.repository.StreamProducts"
+
+	const query = `
+		SELECT 
+			p.id,
+			p.name,
+			p.description,
+			p.price,
+			p.seller_name,
+			c.name,
+			p.version,
+			p.created_at,
+			p.updated_at
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE p.updated_at >= $1
+		ORDER BY p.id
+	`
+
+	rows, err := rep.db.QueryContext(ctx, query, since)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			product      models.ProductView
+			description  sql.NullString
+			categoryName sql.NullString
+		)
+		err := rows.Scan(
+			&product.ID,
+			&product.Name,
+			&description,
+			&product.Price,
+			&product.SellerName,
+			&categoryName,
+			&product.Version,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		product.Description = description.String
+		product.CategoryName = categoryName.String
+
+		if err := fn(&product); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (rep *ProductRepository) AddProduct(ctx context.Context, sellerID, sellerName, name, description, categoryName string, price int) (uuid.UUID, error) {
 	const op = "product.repository.AddProduct"
 
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
 	var categoryID uuid.UUID
 	queryCategory := `SELECT id FROM categories WHERE name = $1`
 
-	err := rep.db.QueryRowContext(ctx, queryCategory, categoryName).Scan(&categoryID)
+	err = tx.QueryRowContext(ctx, queryCategory, categoryName).Scan(&categoryID)
 
 	if err == sql.ErrNoRows {
-		return uuid.Nil, fmt.Errorf("%s: category not found", op)
+		return uuid.Nil, fmt.Errorf("%s: %w", op, ErrCategoryNotFound)
 	}
 
 	if err != nil {
@@ -208,16 +384,195 @@ func (rep *ProductRepository) AddProduct(ctx context.Context, sellerName, name,
 	productID := uuid.New()
 
 	const queryInsert = `
-		INSERT INTO products (id, name, description, price, seller_name, category_id)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO products (id, name, description, price, seller_name, seller_id, category_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
 
-	_, err = rep.db.ExecContext(ctx, queryInsert,
-		productID, name, description, price, sellerName, categoryID,
+	_, err = tx.ExecContext(ctx, queryInsert,
+		productID, name, description, price, sellerName, sellerID, categoryID,
 	)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("%s: failed to insert product: %w", op, err)
 	}
 
+	event, err := models.NewEvent(models.Actor{UserID: sellerID}, events.ProductCreated{Product: events.Product{
+		ProductID:    productID.String(),
+		Title:        name,
+		Description:  description,
+		Price:        price,
+		SellerName:   sellerName,
+		CategoryName: categoryName,
+		Version:      1,
+	}})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := outbox.Enqueue(ctx, tx, productEventsTopic, productID.String(), event); err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("%s: failed to commit: %w", op, err)
+	}
+
 	return productID, nil
 }
+
+func (rep *ProductRepository) UpdateProduct(ctx context.Context, actor models.Actor, id string, update models.ProductUpdate, version int) (*models.ProductView, error) {
+	const op = "product.repository.UpdateProduct"
+
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := checkOwner(ctx, tx, actor, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var categoryID *uuid.UUID
+	if update.CategoryName != nil {
+		var cid uuid.UUID
+		err := tx.QueryRowContext(ctx, `SELECT id FROM categories WHERE name = $1`, *update.CategoryName).Scan(&cid)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%s: %w", op, ErrCategoryNotFound)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to query category: %w", op, err)
+		}
+		categoryID = &cid
+	}
+
+	const queryUpdate = `
+		UPDATE products SET
+			name = COALESCE($3, name),
+			description = COALESCE($4, description),
+			price = COALESCE($5, price),
+			category_id = COALESCE($6, category_id),
+			version = version + 1,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND version = $2
+	`
+
+	res, err := tx.ExecContext(ctx, queryUpdate,
+		id, version, update.Name, update.Description, update.Price, categoryID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to update product: %w", op, err)
+	}
+
+	if err := rep.checkVersionedWrite(ctx, tx, res, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	product, err := viewProduct(ctx, tx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to read updated product: %w", op, err)
+	}
+
+	event, err := models.NewEvent(actor, events.ProductUpdated{Product: events.Product{
+		ProductID:    product.ID,
+		Title:        product.Name,
+		Description:  product.Description,
+		Price:        product.Price,
+		SellerName:   product.SellerName,
+		CategoryName: product.CategoryName,
+		Version:      product.Version,
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := outbox.Enqueue(ctx, tx, productEventsTopic, product.ID, event); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: failed to commit: %w", op, err)
+	}
+
+	return product, nil
+}
+
+func (rep *ProductRepository) DeleteProduct(ctx context.Context, actor models.Actor, id string, version int) error {
+	const op = "product.repository.DeleteProduct"
+
+	tx, err := rep.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := checkOwner(ctx, tx, actor, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM products WHERE id = $1 AND version = $2`, id, version)
+	if err != nil {
+		return fmt.Errorf("%s: failed to delete product: %w", op, err)
+	}
+
+	if err := rep.checkVersionedWrite(ctx, tx, res, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Удаление — следующая версия товара: получатели применяют события не
+	// старше уже применённых и не должны воскресить товар устаревшим update
+	event, err := models.NewEvent(actor, events.ProductDeleted{ProductID: id, Version: version + 1})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := outbox.Enqueue(ctx, tx, productEventsTopic, id, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: failed to commit: %w", op, err)
+	}
+
+	return nil
+}
+
+// checkOwner блокирует строку товара до конца транзакции и проверяет,
+// что actor может его изменять
+func checkOwner(ctx context.Context, tx *sql.Tx, actor models.Actor, id string) error {
+	var sellerID sql.NullString
+	err := tx.QueryRowContext(ctx, `SELECT seller_id FROM products WHERE id = $1 FOR UPDATE`, id).Scan(&sellerID)
+	if err == sql.ErrNoRows {
+		return ErrProductNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query product owner: %w", err)
+	}
+
+	if !actor.CanManage(sellerID.String) {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// checkVersionedWrite различает «товара нет» и «версия устарела»,
+// когда UPDATE/DELETE с условием на version не затронул ни одной строки
+func (rep *ProductRepository) checkVersionedWrite(ctx context.Context, tx *sql.Tx, res sql.Result, id string) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM products WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check product: %w", err)
+	}
+	if !exists {
+		return ErrProductNotFound
+	}
+
+	return ErrVersionConflict
+}
@@ -0,0 +1,164 @@
+package product
+
+import (
+	"catalog/internal/lib/pagination"
+	"catalog/internal/models"
+	"catalog/internal/search"
+	"fmt"
+	"strconv"
+)
+
+// productDocument — документ индекса products
+type productDocument struct {
+	ProductID    string `json:"product_id"`
+	Title        string `json:"title"`
+	Description  string `json:"description,omitempty"`
+	Price        int    `json:"price"`
+	SellerName   string `json:"seller_name"`
+	CategoryName string `json:"category_name,omitempty"`
+}
+
+const (
+	facetCategory = "category"
+	facetSeller   = "seller"
+	facetPrice    = "price"
+
+	facetSize = 20
+
+	suggestProducts   = "products"
+	suggestCategories = "categories"
+
+	// maxResultWindow — ограничение index.max_result_window по умолчанию
+	maxResultWindow = 10000
+)
+
+var priceRanges = []search.AggRange{
+	{Key: "0-5000", To: float(5000)},
+	{Key: "5000-20000", From: float(5000), To: float(20000)},
+	{Key: "20000-50000", From: float(20000), To: float(50000)},
+	{Key: "50000-100000", From: float(50000), To: float(100000)},
+	{Key: "100000+", From: float(100000)},
+}
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func buildSearchRequest(filter models.ProductSearchFilter, page pagination.Request) search.Request {
+	var boolQuery search.BoolQuery
+
+	if filter.Query != "" {
+		boolQuery.Must = append(boolQuery.Must, search.Query{
+			MultiMatch: &search.MultiMatchQuery{
+				Query:     filter.Query,
+				Fields:    []string{"title^2", "description"},
+				Type:      "best_fields",
+				Fuzziness: "AUTO",
+			},
+		})
+	} else {
+		boolQuery.Must = append(boolQuery.Must, search.Query{MatchAll: &struct{}{}})
+	}
+
+	if filter.CategoryName != "" {
+		boolQuery.Filter = append(boolQuery.Filter, search.Query{
+			Term: map[string]any{"category_name": filter.CategoryName},
+		})
+	}
+
+	if filter.SellerName != "" {
+		boolQuery.Filter = append(boolQuery.Filter, search.Query{
+			Term: map[string]any{"seller_name.keyword": filter.SellerName},
+		})
+	}
+
+	if filter.MinPrice != nil || filter.MaxPrice != nil {
+		var r search.RangeQuery
+		if filter.MinPrice != nil {
+			r.GTE = *filter.MinPrice
+		}
+		if filter.MaxPrice != nil {
+			r.LTE = *filter.MaxPrice
+		}
+		boolQuery.Filter = append(boolQuery.Filter, search.Query{
+			Range: map[string]search.RangeQuery{"price": r},
+		})
+	}
+
+	return search.Request{
+		From:  page.Offset,
+		Size:  page.Limit,
+		Query: &search.Query{Bool: &boolQuery},
+		Highlight: &search.Highlight{
+			PreTags:  []string{"<em>"},
+			PostTags: []string{"</em>"},
+			Encoder:  search.EncoderHTML,
+			Fields: map[string]search.HighlightField{
+				"title":       {NumberOfFragments: 0},
+				"description": {FragmentSize: 150, NumberOfFragments: 3},
+			},
+		},
+		Aggs: map[string]search.Aggregation{
+			facetCategory: {Terms: &search.TermsAggregation{Field: "category_name", Size: facetSize}},
+			facetSeller:   {Terms: &search.TermsAggregation{Field: "seller_name.keyword", Size: facetSize}},
+			facetPrice:    {Range: &search.RangeAggregation{Field: "price", Ranges: priceRanges}},
+		},
+		Source: []string{"product_id"},
+	}
+}
+
+// buildSuggestRequest ищет продолжения префикса по completion-подполям
+// title.suggest и category_name.suggest; hits не запрашиваются
+func buildSuggestRequest(prefix string, size int) search.Request {
+	return search.Request{
+		Size:   0,
+		Source: []string{"product_id"},
+		Suggest: map[string]search.Suggester{
+			suggestProducts: {
+				Prefix: prefix,
+				Completion: &search.CompletionSuggester{
+					Field:          "title.suggest",
+					Size:           size,
+					SkipDuplicates: true,
+					Fuzzy:          &search.Fuzzy{Fuzziness: "AUTO"},
+				},
+			},
+			suggestCategories: {
+				Prefix: prefix,
+				Completion: &search.CompletionSuggester{
+					Field:          "category_name.suggest",
+					Size:           size,
+					SkipDuplicates: true,
+				},
+			},
+		},
+	}
+}
+
+func facetsFromAggregations(aggs map[string]search.AggregationResult) map[string][]models.FacetBucket {
+	facets := make(map[string][]models.FacetBucket, len(aggs))
+
+	for name, agg := range aggs {
+		buckets := make([]models.FacetBucket, 0, len(agg.Buckets))
+		for _, b := range agg.Buckets {
+			buckets = append(buckets, models.FacetBucket{
+				Value: bucketKey(b.Key),
+				Count: b.DocCount,
+			})
+		}
+		facets[name] = buckets
+	}
+
+	return facets
+}
+
+func bucketKey(key any) string {
+	switch k := key.(type) {
+	case string:
+		return k
+	case float64:
+		return strconv.FormatFloat(k, 'f', -1, 64)
+	default:
+		return fmt.Sprint(k)
+	}
+}
@@ -0,0 +1,32 @@
+package product
+
+import (
+	"catalog/internal/lib/pagination"
+	"catalog/internal/models"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Товар с разметкой в title: подсветка должна приходить экранированной,
+// поэтому запрос просит у Elasticsearch html-энкодер
+func TestBuildSearchRequestEscapesHighlights(t *testing.T) {
+	filter := models.ProductSearchFilter{Query: "<script>alert(1)</script>"}
+
+	req := buildSearchRequest(filter, pagination.Request{Limit: 10})
+
+	if req.Highlight == nil || req.Highlight.Encoder != "html" {
+		t.Fatalf("highlight = %+v, want html encoder", req.Highlight)
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(body), `"encoder":"html"`) {
+		t.Errorf("request body has no html encoder: %s", body)
+	}
+	if _, ok := req.Highlight.Fields["title"]; !ok {
+		t.Errorf("title is not highlighted: %+v", req.Highlight.Fields)
+	}
+}
@@ -1,31 +1,39 @@
 package product
 
 import (
+	"catalog/internal/lib/pagination"
 	"catalog/internal/models"
 	"context"
+	"events"
 	"fmt"
 	"log/slog"
-	"time"
 
 	"github.com/google/uuid"
 )
 
-type Event struct {
-	URL        string    `json:"url,omitempty"`
-	Ids        []string  `json:"ids,omitempty"`
-	Action     string    `json:"action"`
-	ProductID  string    `json:"product_id,omitempty"`
-	Title      string    `json:"title,omitempty"`
-	SellerName string    `json:"seller_name,omitempty"`
-	Timestamp  time.Time `json:"timestamp,omitempty"`
+type EventProducer interface {
+	Send(ctx context.Context, event events.Envelope, topic string) error
 }
 
-type EventProducer interface {
-	Send(ctx context.Context, event any, topic string) error
+// sendUserAction отправляет действие пользователя в user-events, не
+// задерживая ответ
+func sendUserAction(log *slog.Logger, producer EventProducer, actor models.Actor, action events.UserAction) {
+	event, err := models.NewEvent(actor, action)
+	if err != nil {
+		log.Error("failed to build user event", slog.String("err", err.Error()))
+		return
+	}
+
+	go func() {
+		ctx := context.Background()
+		if err := producer.Send(ctx, event, "user-events"); err != nil {
+			log.Error("failed to send event to Kafka", slog.String("err", err.Error()))
+		}
+	}()
 }
 
 type RepoProductView interface {
-	ViewListProducts(context.Context, int, int) ([]*models.ProductListView, []string, error)
+	ViewListProducts(context.Context, models.ProductFilter, pagination.Request) (*models.Page[*models.ProductListView], []string, error)
 	ViewProduct(context.Context, string) (*models.ProductView, error)
 }
 
@@ -43,33 +51,24 @@ func NewUseacase(log *slog.Logger, repoProductView RepoProductView, eventProduce
 	}
 }
 
-func (u *Useacase) ViewListProducts(ctx context.Context, offset int, limit int) ([]*models.ProductListView, error) {
+func (u *Useacase) ViewListProducts(ctx context.Context, filter models.ProductFilter, page pagination.Request) (*models.Page[*models.ProductListView], error) {
 	const op = "product.usecase.ViewListProducts"
 
-	products, ids, err := u.repoProductView.ViewListProducts(ctx, offset, limit)
+	products, ids, err := u.repoProductView.ViewListProducts(ctx, filter, page)
 	if err != nil {
 		u.log.Error(op+": failed to get products", slog.Any("err", err))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	event := Event{
-		URL:       "products",
-		Ids:       ids,
-		Action:    "visibility",
-		Timestamp: time.Now(),
-	}
-
-	go func() {
-		ctx := context.Background()
-		if err := u.eventProducer.Send(ctx, event, "user-events"); err != nil {
-			u.log.Error("failed to send event to Kafka", slog.String("err", err.Error()))
-		}
-	}()
+	sendUserAction(u.log, u.eventProducer, models.Actor{}, events.UserAction{URL: "products", Action: "visibility", IDs: ids})
 
 	u.log.Info(op+": successfully retrieved products",
-		slog.Int("count", len(products)),
-		slog.Int("offset", offset),
-		slog.Int("limit", limit),
+		slog.Int("count", len(products.Items)),
+		slog.String("category_id", filter.CategoryID),
+		slog.String("sort", filter.Sort),
+		slog.Int("offset", page.Offset),
+		slog.Bool("cursor", page.Cursor != nil),
+		slog.Int("limit", page.Limit),
 	)
 
 	return products, nil
@@ -122,49 +121,87 @@ func NewAddUseacase(log *slog.Logger, repoProductAdder RepoProductAdder, eventPr
 }
 
 type RepoProductAdder interface {
-	AddProduct(context.Context, string, string, string, string, int) (uuid.UUID, error)
+	AddProduct(context.Context, string, string, string, string, string, int) (uuid.UUID, error)
 }
 
-func (u *AddUseacase) AddProduct(ctx context.Context, sellerName, name, categoryName, Description string, price int) error {
+// AddProduct создаёт товар от имени actor: продавец — сам пользователь,
+// имя продавца берётся из подписанных шлюзом заголовков, а не из запроса
+func (u *AddUseacase) AddProduct(ctx context.Context, actor models.Actor, name, description, categoryName string, price int) error {
 	const op = "product.usecase.AddProduct"
-	productID, err := u.repoProductAdder.AddProduct(ctx, sellerName, name, categoryName, Description, price)
+
+	productID, err := u.repoProductAdder.AddProduct(ctx, actor.UserID, actor.Username, name, description, categoryName, price)
 	if err != nil {
-		return fmt.Errorf("%s:: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
+	sendUserAction(u.log, u.eventProducer, actor, events.UserAction{URL: "product", Action: "create"})
+
+	// product_created записывается в outbox репозиторием в той же транзакции
+	u.log.Info(op+": product created", slog.String("product_id", productID.String()))
+
+	return nil
+}
+
+type RepoProductEditor interface {
+	UpdateProduct(context.Context, models.Actor, string, models.ProductUpdate, int) (*models.ProductView, error)
+	DeleteProduct(context.Context, models.Actor, string, int) error
+}
+
+type EditUseacase struct {
+	repoProductEditor RepoProductEditor
+	eventProducer     EventProducer
+	log               *slog.Logger
+}
+
+func NewEditUseacase(log *slog.Logger, repoProductEditor RepoProductEditor, eventProducer EventProducer) *EditUseacase {
+	return &EditUseacase{
+		repoProductEditor: repoProductEditor,
+		eventProducer:     eventProducer,
+		log:               log,
 	}
+}
+
+func (u *EditUseacase) UpdateProduct(ctx context.Context, actor models.Actor, id string, update models.ProductUpdate, version int) (*models.ProductView, error) {
+	const op = "product.usecase.UpdateProduct"
 
-	eventUser := Event{
-		URL:       "product",
-		Action:    "create",
-		Timestamp: time.Now(),
+	// product_updated записывается в outbox репозиторием в той же транзакции
+	product, err := u.repoProductEditor.UpdateProduct(ctx, actor, id, update, version)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	go func() {
-		ctx := context.Background()
-		if err := u.eventProducer.Send(ctx, eventUser, "user-events"); err != nil {
-			u.log.Error("failed to send event to Kafka", slog.String("err", err.Error()))
-		}
-	}()
+	u.sendUserEvent(actor, "update")
+
+	u.log.Info(op+": product updated",
+		slog.String("product_id", id),
+		slog.Int("version", product.Version),
+	)
+
+	return product, nil
+}
 
-	eventProduct := Event{
-		Action:     "product_created",
-		ProductID:  productID.String(),
-		Title:      name,
-		SellerName: sellerName,
+func (u *EditUseacase) DeleteProduct(ctx context.Context, actor models.Actor, id string, version int) error {
+	const op = "product.usecase.DeleteProduct"
+
+	// product_deleted записывается в outbox репозиторием в той же транзакции
+	if err := u.repoProductEditor.DeleteProduct(ctx, actor, id, version); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	go func() {
-		ctx := context.Background()
-		if err := u.eventProducer.Send(ctx, eventProduct, "product-events"); err != nil {
-			u.log.Error("failed to send event to Kafka", slog.String("err", err.Error()))
-		}
-	}()
+	u.sendUserEvent(actor, "delete")
+
+	u.log.Info(op+": product deleted", slog.String("product_id", id))
 
 	return nil
 }
 
+func (u *EditUseacase) sendUserEvent(actor models.Actor, action string) {
+	sendUserAction(u.log, u.eventProducer, actor, events.UserAction{URL: "product", Action: action})
+}
+
 type RepoProductSearch interface {
-	SearchProduct(context.Context, string) ([]*models.ProductView, []string, error)
+	SearchProduct(context.Context, models.ProductSearchFilter, pagination.Request) (*models.ProductSearchResult, []string, error)
+	SuggestProduct(context.Context, string, int) (*models.Suggestions, error)
 }
 
 type SearchUseacase struct {
@@ -181,33 +218,42 @@ func NewSearchUseacase(log *slog.Logger, RepoProductSearch RepoProductSearch, ev
 	}
 }
 
-func (u *SearchUseacase) SearchProduct(ctx context.Context, req string) ([]*models.ProductView, error) {
-	const op = "product.usecase.ViewListProducts"
+func (u *SearchUseacase) SearchProduct(ctx context.Context, filter models.ProductSearchFilter, page pagination.Request) (*models.ProductSearchResult, error) {
+	const op = "product.usecase.SearchProduct"
 
-	products, ids, err := u.RepoProductSearch.SearchProduct(ctx, req)
+	result, ids, err := u.RepoProductSearch.SearchProduct(ctx, filter, page)
 	if err != nil {
 		u.log.Error(op+": failed to get products", slog.Any("err", err))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	event := Event{
-		URL:       "products",
-		Ids:       ids,
-		Action:    "visibility",
-		Timestamp: time.Now(),
-	}
-
-	go func() {
-		ctx := context.Background()
-		if err := u.eventProducer.Send(ctx, event, "user-events"); err != nil {
-			u.log.Error("failed to send event to Kafka", slog.String("err", err.Error()))
-		}
-	}()
+	sendUserAction(u.log, u.eventProducer, models.Actor{}, events.UserAction{URL: "products", Action: "visibility", IDs: ids})
 
 	u.log.Info(op+": successfully retrieved products",
-		slog.Int("count", len(products)),
-		slog.String("search_request", req),
+		slog.Int("count", len(result.Items)),
+		slog.Int("total", result.Total),
+		slog.String("search_request", filter.Query),
 	)
 
-	return products, nil
+	return result, nil
+}
+
+// SuggestProduct вызывается на каждое нажатие клавиши, поэтому событие
+// visibility не отправляется
+func (u *SearchUseacase) SuggestProduct(ctx context.Context, prefix string, size int) (*models.Suggestions, error) {
+	const op = "product.usecase.SuggestProduct"
+
+	suggestions, err := u.RepoProductSearch.SuggestProduct(ctx, prefix, size)
+	if err != nil {
+		u.log.Error(op+": failed to get suggestions", slog.Any("err", err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.log.Debug(op+": successfully retrieved suggestions",
+		slog.String("prefix", prefix),
+		slog.Int("products", len(suggestions.Products)),
+		slog.Int("categories", len(suggestions.Categories)),
+	)
+
+	return suggestions, nil
 }
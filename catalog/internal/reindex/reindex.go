@@ -0,0 +1,265 @@
+package reindex
+
+import (
+	"bytes"
+	"catalog/internal/models"
+	"catalog/internal/search"
+	"context"
+	"encoding/json"
+	"errors"
+	"events"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// catchUpMargin — запас на расхождение часов приложения и Postgres при
+// догоняющем проходе; повторная индексация безопасна благодаря external version
+const catchUpMargin = time.Minute
+
+const defaultBatchSize = 1000
+
+var ErrCountMismatch = errors.New("document count mismatch")
+
+type ProductSource interface {
+	StreamProducts(ctx context.Context, since time.Time, fn func(*models.ProductView) error) error
+}
+
+// document — документ индекса products (см. product.productDocument)
+type document struct {
+	ProductID    string `json:"product_id"`
+	Title        string `json:"title"`
+	Description  string `json:"description,omitempty"`
+	Price        int    `json:"price"`
+	SellerName   string `json:"seller_name"`
+	CategoryName string `json:"category_name,omitempty"`
+}
+
+type Options struct {
+	BatchSize int
+	// DeleteOld удаляет индексы, с которых был снят алиас
+	DeleteOld bool
+}
+
+type Reindexer struct {
+	log    *slog.Logger
+	source ProductSource
+	client *search.Client
+	alias  string
+}
+
+func NewReindexer(log *slog.Logger, source ProductSource, client *search.Client, alias string) *Reindexer {
+	return &Reindexer{log: log, source: source, client: client, alias: alias}
+}
+
+// Run строит новый версионированный индекс из Postgres, сверяет число
+// документов и атомарно переключает на него алиас. Пока индекс строится,
+// поиск продолжает читать старый индекс через алиас.
+func (r *Reindexer) Run(ctx context.Context, opts Options) error {
+	const op = "reindex.Run"
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+
+	startedAt := time.Now()
+	index := fmt.Sprintf("%s_%s", r.alias, startedAt.UTC().Format("20060102150405"))
+
+	log := r.log.With(slog.String("op", op), slog.String("alias", r.alias), slog.String("index", index))
+
+	if err := r.client.CreateIndex(ctx, index, events.ProductsIndexMapping); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("index created")
+
+	indexed, err := r.load(ctx, index, time.Time{}, opts.BatchSize, false)
+	if err == nil {
+		err = r.verify(ctx, index, indexed)
+	}
+	if err != nil {
+		r.dropIndex(index)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("index built", slog.Int("documents", indexed))
+
+	old, err := r.swap(ctx, index)
+	if err != nil {
+		r.dropIndex(index)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("alias switched", slog.Any("previous", old))
+
+	// Товары, изменённые во время построения, индексатор записал в старый
+	// индекс; переносим их в новый. Удаления за это время не переносятся.
+	caught, err := r.load(ctx, r.alias, startedAt.Add(-catchUpMargin), opts.BatchSize, true)
+	if err != nil {
+		return fmt.Errorf("%s: catch-up: %w", op, err)
+	}
+	log.Info("catch-up finished", slog.Int("documents", caught))
+
+	if opts.DeleteOld && len(old) > 0 {
+		if err := r.client.DeleteIndex(ctx, old...); err != nil {
+			return fmt.Errorf("%s: deleting old indices: %w", op, err)
+		}
+		log.Info("old indices deleted", slog.Any("indices", old))
+	}
+
+	return nil
+}
+
+// load индексирует товары из Postgres пачками. На догоняющем проходе
+// конфликт версий означает, что индексатор уже записал более новую версию.
+func (r *Reindexer) load(ctx context.Context, index string, since time.Time, batchSize int, allowConflicts bool) (int, error) {
+	var (
+		buf     bytes.Buffer
+		pending int
+		total   int
+	)
+	enc := json.NewEncoder(&buf)
+
+	flush := func() error {
+		if pending == 0 {
+			return nil
+		}
+		if err := r.bulk(ctx, buf.Bytes(), allowConflicts); err != nil {
+			return err
+		}
+		total += pending
+		pending = 0
+		buf.Reset()
+		return nil
+	}
+
+	err := r.source.StreamProducts(ctx, since, func(p *models.ProductView) error {
+		meta := map[string]any{
+			"index": map[string]any{
+				"_index":       index,
+				"_id":          p.ID,
+				"version":      p.Version,
+				"version_type": "external",
+			},
+		}
+		if err := enc.Encode(meta); err != nil {
+			return err
+		}
+		if err := enc.Encode(document{
+			ProductID:    p.ID,
+			Title:        p.Name,
+			Description:  p.Description,
+			Price:        p.Price,
+			SellerName:   p.SellerName,
+			CategoryName: p.CategoryName,
+		}); err != nil {
+			return err
+		}
+
+		pending++
+		if pending >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return total, err
+	}
+
+	if err := flush(); err != nil {
+		return total, err
+	}
+
+	return total, nil
+}
+
+func (r *Reindexer) bulk(ctx context.Context, body []byte, allowConflicts bool) error {
+	res, err := r.client.Bulk(ctx, body)
+	if err != nil {
+		return err
+	}
+	if !res.Errors {
+		return nil
+	}
+
+	failed := 0
+	var first string
+	for _, item := range res.Items {
+		for _, it := range item {
+			if it.Status < 300 || (allowConflicts && it.Status == http.StatusConflict) {
+				continue
+			}
+			if failed == 0 {
+				first = fmt.Sprintf("%s: status %d", it.ID, it.Status)
+				if it.Error != nil {
+					first += ": " + it.Error.Type + ": " + it.Error.Reason
+				}
+			}
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("bulk: %d operations failed, first: %s", failed, first)
+	}
+
+	return nil
+}
+
+func (r *Reindexer) verify(ctx context.Context, index string, expected int) error {
+	if err := r.client.Refresh(ctx, index); err != nil {
+		return err
+	}
+
+	count, err := r.client.Count(ctx, index)
+	if err != nil {
+		return err
+	}
+
+	if count != expected {
+		return fmt.Errorf("%w: indexed %d, in index %d", ErrCountMismatch, expected, count)
+	}
+
+	return nil
+}
+
+// swap переводит алиас на новый индекс одним запросом _aliases. Если под
+// именем алиаса лежит обычный индекс (созданный до перехода на алиасы),
+// он удаляется в том же запросе.
+func (r *Reindexer) swap(ctx context.Context, index string) ([]string, error) {
+	old, err := r.client.AliasIndices(ctx, r.alias)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := make([]search.AliasAction, 0, len(old)+1)
+	for _, o := range old {
+		actions = append(actions, search.AliasAction{Remove: &search.AliasTarget{Index: o, Alias: r.alias}})
+	}
+
+	if len(old) == 0 {
+		exists, err := r.client.IndexExists(ctx, r.alias)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			actions = append(actions, search.AliasAction{RemoveIndex: &search.AliasTarget{Index: r.alias}})
+		}
+	}
+
+	actions = append(actions, search.AliasAction{Add: &search.AliasTarget{Index: index, Alias: r.alias}})
+
+	if err := r.client.UpdateAliases(ctx, actions); err != nil {
+		return nil, err
+	}
+
+	return old, nil
+}
+
+// dropIndex удаляет недостроенный индекс, чтобы он не копился в кластере
+func (r *Reindexer) dropIndex(index string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := r.client.DeleteIndex(ctx, index); err != nil {
+		r.log.Error("failed to delete unfinished index", slog.String("index", index), slog.Any("err", err))
+	}
+}
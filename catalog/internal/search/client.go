@@ -0,0 +1,134 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+var ErrClientNotInitialized = errors.New("elasticsearch client is not initialized")
+
+type Response[T any] struct {
+	Took         int                          `json:"took"`
+	Hits         Hits[T]                      `json:"hits"`
+	Aggregations map[string]AggregationResult `json:"aggregations,omitempty"`
+	Suggest      map[string][]SuggestEntry[T] `json:"suggest,omitempty"`
+}
+
+type Hits[T any] struct {
+	Total Total    `json:"total"`
+	Hits  []Hit[T] `json:"hits"`
+}
+
+type Total struct {
+	Value    int    `json:"value"`
+	Relation string `json:"relation"`
+}
+
+type Hit[T any] struct {
+	ID        string              `json:"_id"`
+	Score     float64             `json:"_score"`
+	Source    T                   `json:"_source"`
+	Highlight map[string][]string `json:"highlight,omitempty"`
+}
+
+type AggregationResult struct {
+	Buckets []Bucket `json:"buckets"`
+}
+
+type Bucket struct {
+	Key      any      `json:"key"`
+	DocCount int      `json:"doc_count"`
+	From     *float64 `json:"from,omitempty"`
+	To       *float64 `json:"to,omitempty"`
+}
+
+type SuggestEntry[T any] struct {
+	Text    string             `json:"text"`
+	Options []SuggestOption[T] `json:"options"`
+}
+
+type SuggestOption[T any] struct {
+	Text   string  `json:"text"`
+	ID     string  `json:"_id"`
+	Score  float64 `json:"_score"`
+	Source T       `json:"_source"`
+}
+
+// Error — ошибка, которую вернул Elasticsearch
+type Error struct {
+	Status int
+	Type   string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("elasticsearch error: status %d: %s: %s", e.Status, e.Type, e.Reason)
+}
+
+type Client struct {
+	es *elasticsearch.Client
+}
+
+func NewClient(es *elasticsearch.Client) *Client {
+	return &Client{es: es}
+}
+
+// Search выполняет запрос к индексу и декодирует ответ с документами типа T
+func Search[T any](ctx context.Context, c *Client, index string, req Request) (*Response[T], error) {
+	if c == nil || c.es == nil {
+		return nil, ErrClientNotInitialized
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(req); err != nil {
+		return nil, fmt.Errorf("error encoding query: %w", err)
+	}
+
+	res, err := c.es.Search(
+		c.es.Search.WithContext(ctx),
+		c.es.Search.WithIndex(index),
+		c.es.Search.WithBody(&buf),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error searching in elasticsearch: %w", err)
+	}
+	defer res.Body.Close()
+
+	if err := checkResponse(res); err != nil {
+		return nil, err
+	}
+
+	var r Response[T]
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("error parsing the elasticsearch response body: %w", err)
+	}
+
+	return &r, nil
+}
+
+func checkResponse(res *esapi.Response) error {
+	if !res.IsError() {
+		return nil
+	}
+
+	var e struct {
+		Error struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	}
+
+	body, _ := io.ReadAll(res.Body)
+	if err := json.Unmarshal(body, &e); err != nil || e.Error.Type == "" {
+		return &Error{Status: res.StatusCode, Reason: string(body)}
+	}
+
+	return &Error{Status: res.StatusCode, Type: e.Error.Type, Reason: e.Error.Reason}
+}
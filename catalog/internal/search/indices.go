@@ -0,0 +1,218 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// BulkItem — результат одной операции bulk-запроса
+type BulkItem struct {
+	ID     string `json:"_id"`
+	Status int    `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error,omitempty"`
+}
+
+type BulkResponse struct {
+	Errors bool                  `json:"errors"`
+	Items  []map[string]BulkItem `json:"items"`
+}
+
+// AliasAction — одно действие запроса _aliases
+type AliasAction struct {
+	Add         *AliasTarget `json:"add,omitempty"`
+	Remove      *AliasTarget `json:"remove,omitempty"`
+	RemoveIndex *AliasTarget `json:"remove_index,omitempty"`
+}
+
+type AliasTarget struct {
+	Index string `json:"index"`
+	Alias string `json:"alias,omitempty"`
+}
+
+// CreateIndex создаёт индекс с переданными настройками и маппингом
+func (c *Client) CreateIndex(ctx context.Context, index, body string) error {
+	if c == nil || c.es == nil {
+		return ErrClientNotInitialized
+	}
+
+	res, err := c.es.Indices.Create(index,
+		c.es.Indices.Create.WithContext(ctx),
+		c.es.Indices.Create.WithBody(strings.NewReader(body)),
+	)
+	if err != nil {
+		return fmt.Errorf("error creating index: %w", err)
+	}
+	defer res.Body.Close()
+
+	return checkResponse(res)
+}
+
+// DeleteIndex удаляет индексы
+func (c *Client) DeleteIndex(ctx context.Context, indices ...string) error {
+	if c == nil || c.es == nil {
+		return ErrClientNotInitialized
+	}
+
+	res, err := c.es.Indices.Delete(indices, c.es.Indices.Delete.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("error deleting index: %w", err)
+	}
+	defer res.Body.Close()
+
+	return checkResponse(res)
+}
+
+// IndexExists проверяет, есть ли индекс или алиас с таким именем
+func (c *Client) IndexExists(ctx context.Context, name string) (bool, error) {
+	if c == nil || c.es == nil {
+		return false, ErrClientNotInitialized
+	}
+
+	res, err := c.es.Indices.Exists([]string{name}, c.es.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return false, fmt.Errorf("error checking index: %w", err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, &Error{Status: res.StatusCode}
+	}
+}
+
+// AliasIndices возвращает индексы, на которые указывает алиас
+func (c *Client) AliasIndices(ctx context.Context, alias string) ([]string, error) {
+	if c == nil || c.es == nil {
+		return nil, ErrClientNotInitialized
+	}
+
+	res, err := c.es.Indices.GetAlias(
+		c.es.Indices.GetAlias.WithContext(ctx),
+		c.es.Indices.GetAlias.WithName(alias),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting alias: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err := checkResponse(res); err != nil {
+		return nil, err
+	}
+
+	var r map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("error parsing alias response: %w", err)
+	}
+
+	indices := make([]string, 0, len(r))
+	for index := range r {
+		indices = append(indices, index)
+	}
+
+	return indices, nil
+}
+
+// UpdateAliases атомарно применяет действия над алиасами
+func (c *Client) UpdateAliases(ctx context.Context, actions []AliasAction) error {
+	if c == nil || c.es == nil {
+		return ErrClientNotInitialized
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(map[string]any{"actions": actions}); err != nil {
+		return fmt.Errorf("error encoding alias actions: %w", err)
+	}
+
+	res, err := c.es.Indices.UpdateAliases(&buf, c.es.Indices.UpdateAliases.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("error updating aliases: %w", err)
+	}
+	defer res.Body.Close()
+
+	return checkResponse(res)
+}
+
+// Bulk отправляет NDJSON-тело в _bulk
+func (c *Client) Bulk(ctx context.Context, body []byte) (*BulkResponse, error) {
+	if c == nil || c.es == nil {
+		return nil, ErrClientNotInitialized
+	}
+
+	res, err := c.es.Bulk(bytes.NewReader(body), c.es.Bulk.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("error sending bulk request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if err := checkResponse(res); err != nil {
+		return nil, err
+	}
+
+	var r BulkResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("error parsing bulk response: %w", err)
+	}
+
+	return &r, nil
+}
+
+// Refresh делает проиндексированные документы видимыми для поиска
+func (c *Client) Refresh(ctx context.Context, index string) error {
+	if c == nil || c.es == nil {
+		return ErrClientNotInitialized
+	}
+
+	res, err := c.es.Indices.Refresh(
+		c.es.Indices.Refresh.WithContext(ctx),
+		c.es.Indices.Refresh.WithIndex(index),
+	)
+	if err != nil {
+		return fmt.Errorf("error refreshing index: %w", err)
+	}
+	defer res.Body.Close()
+
+	return checkResponse(res)
+}
+
+// Count возвращает число документов в индексе
+func (c *Client) Count(ctx context.Context, index string) (int, error) {
+	if c == nil || c.es == nil {
+		return 0, ErrClientNotInitialized
+	}
+
+	res, err := c.es.Count(
+		c.es.Count.WithContext(ctx),
+		c.es.Count.WithIndex(index),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error counting documents: %w", err)
+	}
+	defer res.Body.Close()
+
+	if err := checkResponse(res); err != nil {
+		return 0, err
+	}
+
+	var r struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return 0, fmt.Errorf("error parsing count response: %w", err)
+	}
+
+	return r.Count, nil
+}
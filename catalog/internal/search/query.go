@@ -0,0 +1,106 @@
+package search
+
+// Типизированное подмножество Query DSL Elasticsearch, которое использует каталог
+
+type Request struct {
+	From      int                    `json:"from"`
+	Size      int                    `json:"size"`
+	Query     *Query                 `json:"query,omitempty"`
+	Highlight *Highlight             `json:"highlight,omitempty"`
+	Aggs      map[string]Aggregation `json:"aggs,omitempty"`
+	Source    []string               `json:"_source,omitempty"`
+	Suggest   map[string]Suggester   `json:"suggest,omitempty"`
+}
+
+// Query — одно из полей должно быть заполнено
+type Query struct {
+	Bool       *BoolQuery            `json:"bool,omitempty"`
+	MultiMatch *MultiMatchQuery      `json:"multi_match,omitempty"`
+	Match      map[string]MatchQuery `json:"match,omitempty"`
+	Term       map[string]any        `json:"term,omitempty"`
+	Terms      map[string][]string   `json:"terms,omitempty"`
+	Range      map[string]RangeQuery `json:"range,omitempty"`
+	MatchAll   *struct{}             `json:"match_all,omitempty"`
+}
+
+type BoolQuery struct {
+	Must    []Query `json:"must,omitempty"`
+	Filter  []Query `json:"filter,omitempty"`
+	Should  []Query `json:"should,omitempty"`
+	MustNot []Query `json:"must_not,omitempty"`
+}
+
+type MultiMatchQuery struct {
+	Query     string   `json:"query"`
+	Fields    []string `json:"fields"`
+	Type      string   `json:"type,omitempty"`
+	Fuzziness string   `json:"fuzziness,omitempty"`
+	Operator  string   `json:"operator,omitempty"`
+}
+
+type MatchQuery struct {
+	Query     string `json:"query"`
+	Fuzziness string `json:"fuzziness,omitempty"`
+	Operator  string `json:"operator,omitempty"`
+}
+
+type RangeQuery struct {
+	GTE any `json:"gte,omitempty"`
+	LTE any `json:"lte,omitempty"`
+}
+
+// EncoderHTML экранирует текст документа перед вставкой тегов подсветки:
+// иначе разметка из title или комментария уйдёт клиенту как есть
+const EncoderHTML = "html"
+
+// Highlight — подсветка совпадений
+type Highlight struct {
+	PreTags  []string                  `json:"pre_tags,omitempty"`
+	PostTags []string                  `json:"post_tags,omitempty"`
+	Encoder  string                    `json:"encoder,omitempty"`
+	Fields   map[string]HighlightField `json:"fields"`
+}
+
+// HighlightField — настройки подсветки поля. NumberOfFragments передаётся
+// всегда: 0 значит «всё поле целиком», а не значение по умолчанию (5).
+type HighlightField struct {
+	FragmentSize      int `json:"fragment_size,omitempty"`
+	NumberOfFragments int `json:"number_of_fragments"`
+}
+
+type Aggregation struct {
+	Terms *TermsAggregation `json:"terms,omitempty"`
+	Range *RangeAggregation `json:"range,omitempty"`
+}
+
+type TermsAggregation struct {
+	Field string `json:"field"`
+	Size  int    `json:"size,omitempty"`
+}
+
+type RangeAggregation struct {
+	Field  string     `json:"field"`
+	Ranges []AggRange `json:"ranges"`
+}
+
+type AggRange struct {
+	Key  string   `json:"key,omitempty"`
+	From *float64 `json:"from,omitempty"`
+	To   *float64 `json:"to,omitempty"`
+}
+
+type Suggester struct {
+	Prefix     string               `json:"prefix"`
+	Completion *CompletionSuggester `json:"completion,omitempty"`
+}
+
+type CompletionSuggester struct {
+	Field          string `json:"field"`
+	Size           int    `json:"size,omitempty"`
+	SkipDuplicates bool   `json:"skip_duplicates,omitempty"`
+	Fuzzy          *Fuzzy `json:"fuzzy,omitempty"`
+}
+
+type Fuzzy struct {
+	Fuzziness string `json:"fuzziness,omitempty"`
+}
@@ -1,16 +1,23 @@
 CREATE TABLE categories (
     id UUID PRIMARY KEY,
-    name TEXT NOT NULL
+    name TEXT NOT NULL UNIQUE,
+    parent_id UUID REFERENCES categories(id) ON DELETE RESTRICT,
+    CHECK (parent_id <> id)
 );
 
+CREATE INDEX categories_parent_id_idx ON categories (parent_id);
+
 CREATE TABLE products (
     id UUID PRIMARY KEY,
     name TEXT NOT NULL,
     description TEXT,
     price INT NOT NULL CHECK (price >= 0),
     seller_name TEXT NOT NULL,
+    seller_id UUID,
     category_id UUID REFERENCES categories(id) ON DELETE SET NULL,
-    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
 CREATE TABLE comments (
@@ -21,12 +28,12 @@ CREATE TABLE comments (
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
-INSERT INTO categories (id, name)
+INSERT INTO categories (id, name, parent_id)
 VALUES 
-  ('11111111-1111-1111-1111-111111111111', 'Смартфоны'),
-  ('22222222-2222-2222-2222-222222222222', 'Бытовая техника'),
-  ('33333333-3333-3333-3333-333333333333', 'Мужская одежда'),
-  ('44444444-4444-4444-4444-444444444444', 'Электроника');
+  ('44444444-4444-4444-4444-444444444444', 'Электроника', NULL),
+  ('11111111-1111-1111-1111-111111111111', 'Смартфоны', '44444444-4444-4444-4444-444444444444'),
+  ('22222222-2222-2222-2222-222222222222', 'Бытовая техника', NULL),
+  ('33333333-3333-3333-3333-333333333333', 'Мужская одежда', NULL);
 
 INSERT INTO products (id, name, description, price, seller_name, category_id)
 VALUES 
@@ -145,3 +152,29 @@ VALUES
 
 
 
+CREATE TABLE outbox (
+    id BIGSERIAL PRIMARY KEY,
+    topic TEXT NOT NULL,
+    message_key TEXT,
+    payload JSONB NOT NULL,
+    trace_context JSONB,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP,
+    -- failed_at — сообщение исчерпало попытки и больше не отправляется
+    failed_at TIMESTAMP
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (next_attempt_at) WHERE delivered_at IS NULL AND failed_at IS NULL;
+CREATE INDEX outbox_failed_idx ON outbox (failed_at) WHERE failed_at IS NOT NULL;
+CREATE INDEX outbox_pending_key_idx ON outbox (message_key, id) WHERE delivered_at IS NULL;
+
+CREATE INDEX products_created_at_id_idx ON products (created_at DESC, id DESC);
+CREATE INDEX products_price_id_idx ON products (price, id);
+CREATE INDEX products_name_id_idx ON products (name, id);
+CREATE INDEX products_category_id_idx ON products (category_id);
+CREATE INDEX products_seller_name_idx ON products (seller_name);
+CREATE INDEX products_seller_id_idx ON products (seller_id);
+CREATE INDEX comments_product_id_created_at_idx ON comments (product_id, created_at DESC, id DESC);
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("consumer")
+
+// row — событие, готовое к записи; link связывает спан записи пачки
+// с трейсом запроса, породившего событие
+type row struct {
+	msg    kafka.Message
+	values []any
+	link   trace.Link
+}
+
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type rowWriter interface {
+	Write(ctx context.Context, rows []row) error
+}
+
+// pipeline — обработка одного топика: разбор, таблица и DLQ
+type pipeline struct {
+	dlqTopic string
+	decode   func(msg kafka.Message) ([]any, error)
+	writer   rowWriter
+}
+
+// run читает события всех топиков пачками. Пачка пишется в ClickHouse
+// (каждый топик в свою таблицу), когда набрала BatchSize событий или когда
+// с первого события прошло FlushInterval.
+// Офсеты коммитятся только после успешной записи, поэтому при падении
+// события будут прочитаны повторно (at-least-once). Битые события и строки,
+// отвергнутые ClickHouse, уходят в DLQ до коммита.
+func run(ctx context.Context, reader messageReader, pipelines map[string]*pipeline, dlq messageWriter, cfg Config) error {
+	var (
+		msgs    []kafka.Message
+		rows    = make(map[string][]row, len(pipelines))
+		written int
+		dead    []deadLetter
+		started time.Time
+	)
+
+	flush := func(ctx context.Context) error {
+		if len(msgs) == 0 {
+			return nil
+		}
+
+		for topic, topicRows := range rows {
+			if len(topicRows) == 0 {
+				continue
+			}
+
+			// Если следующий топик записать не удастся, офсеты не будут
+			// закоммичены и уже записанные строки после перезапуска
+			// запишутся повторно: доставка at-least-once
+			rejected, err := writeRows(ctx, pipelines[topic].writer, topicRows, cfg.MaxBackoff)
+			if err != nil {
+				return fmt.Errorf("topic %s: %w", topic, err)
+			}
+			dead = append(dead, rejected...)
+			written += len(topicRows) - len(rejected)
+			rows[topic] = topicRows[:0]
+		}
+
+		if len(dead) > 0 {
+			now := time.Now()
+			out := make([]kafka.Message, 0, len(dead))
+			for _, d := range dead {
+				log.Printf("event sent to DLQ: topic=%s partition=%d offset=%d: %v\n",
+					d.msg.Topic, d.msg.Partition, d.msg.Offset, d.err)
+				out = append(out, toDLQ(pipelines[d.msg.Topic].dlqTopic, d, now))
+			}
+
+			err := retry(ctx, cfg.MaxBackoff, func() error { return dlq.WriteMessages(ctx, out...) })
+			if err != nil {
+				return fmt.Errorf("writing to DLQ: %w", err)
+			}
+		}
+
+		err := retry(ctx, cfg.MaxBackoff, func() error { return reader.CommitMessages(ctx, msgs...) })
+		if err != nil {
+			return fmt.Errorf("committing offsets: %w", err)
+		}
+
+		log.Printf("batch written: messages=%d rows=%d dead=%d\n", len(msgs), written, len(dead))
+
+		msgs, written, dead = msgs[:0], 0, dead[:0]
+		return nil
+	}
+
+	for {
+		wait := cfg.FlushInterval
+		if len(msgs) > 0 {
+			wait = time.Until(started.Add(cfg.FlushInterval))
+		}
+
+		fetchCtx, cancel := context.WithTimeout(ctx, wait)
+		msg, err := reader.FetchMessage(fetchCtx)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return shutdown(flush, cfg.ShutdownTimeout)
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				if err := flush(ctx); err != nil {
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("fetching message: %w", err)
+		}
+
+		if len(msgs) == 0 {
+			started = time.Now()
+		}
+		msgs = append(msgs, msg)
+
+		p, ok := pipelines[msg.Topic]
+		if !ok {
+			// Не должно случаться: читатель подписан только на топики
+			// pipelines. Сообщение коммитится вместе с пачкой.
+			log.Printf("message from unexpected topic skipped: topic=%s offset=%d\n", msg.Topic, msg.Offset)
+		} else if r, err := decode(p, msg); err != nil {
+			dead = append(dead, deadLetter{msg: msg, err: err})
+		} else {
+			rows[msg.Topic] = append(rows[msg.Topic], r)
+		}
+
+		if len(msgs) >= cfg.BatchSize {
+			if err := flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// shutdown дописывает накопленную пачку после отмены основного контекста
+func shutdown(flush func(context.Context) error, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := flush(ctx); err != nil {
+		return fmt.Errorf("flushing last batch: %w", err)
+	}
+	return context.Canceled
+}
+
+// decode разбирает событие обработчиком топика; ошибка означает, что
+// событие битое
+func decode(p *pipeline, msg kafka.Message) (row, error) {
+	values, err := p.decode(msg)
+	if err != nil {
+		return row{}, err
+	}
+
+	msgCtx := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier(msg.Headers))
+
+	return row{
+		msg:    msg,
+		values: values,
+		link:   trace.LinkFromContext(msgCtx),
+	}, nil
+}
+
+// writeRows пишет пачку, повторяя сетевые ошибки. Если ClickHouse
+// отверг пачку, строки пишутся по одной, чтобы найти виноватые; они
+// возвращаются для DLQ.
+func writeRows(ctx context.Context, writer rowWriter, rows []row, maxBackoff time.Duration) ([]deadLetter, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	err := retryTransient(ctx, maxBackoff, func() error { return writer.Write(ctx, rows) })
+	if err == nil || !isPermanent(err) {
+		return nil, err
+	}
+	if len(rows) == 1 {
+		return []deadLetter{{msg: rows[0].msg, err: err}}, nil
+	}
+
+	log.Printf("clickhouse rejected batch, writing rows one by one: %v\n", err)
+
+	var dead []deadLetter
+	for i := range rows {
+		rejected, err := writeRows(ctx, writer, rows[i:i+1], maxBackoff)
+		if err != nil {
+			return nil, err
+		}
+		dead = append(dead, rejected...)
+	}
+
+	return dead, nil
+}
+
+// retryTransient — как retry, но сразу возвращает постоянную ошибку
+func retryTransient(ctx context.Context, maxBackoff time.Duration, fn func() error) error {
+	var permanentErr error
+
+	err := retry(ctx, maxBackoff, func() error {
+		err := fn()
+		if err != nil && isPermanent(err) {
+			permanentErr = err
+			return nil
+		}
+		return err
+	})
+	if permanentErr != nil {
+		return permanentErr
+	}
+	return err
+}
+
+// retry повторяет fn с экспоненциальной задержкой, пока она возвращает
+// ошибку или пока не отменён контекст
+func retry(ctx context.Context, maxBackoff time.Duration, fn func() error) error {
+	delay := 500 * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("operation failed, retrying: attempt=%d delay=%s: %v\n", attempt, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxBackoff {
+			delay = maxBackoff
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeReader отдаёт заданные сообщения, затем ждёт отмены контекста.
+// При коммите запоминает, сколько строк к этому моменту записано.
+type fakeReader struct {
+	msgs      []kafka.Message
+	writer    *fakeWriter
+	committed []kafka.Message
+	// writtenAtCommit — число записанных строк на момент каждого коммита
+	writtenAtCommit []int
+	// drained закрывается, когда все сообщения прочитаны
+	drained chan struct{}
+	done    chan struct{}
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(r.msgs) > 0 {
+		msg := r.msgs[0]
+		r.msgs = r.msgs[1:]
+		if len(r.msgs) == 0 && r.drained != nil {
+			close(r.drained)
+		}
+		return msg, nil
+	}
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.committed = append(r.committed, msgs...)
+	r.writtenAtCommit = append(r.writtenAtCommit, r.writer.rowCount())
+	if len(r.msgs) == 0 && r.done != nil {
+		close(r.done)
+		r.done = nil
+	}
+	return nil
+}
+
+// fakeWriter отвергает пачки со строкой "reject", как ClickHouse, и
+// transient раз подряд возвращает сетевую ошибку
+type fakeWriter struct {
+	mu        sync.Mutex
+	transient int
+	batches   [][]string
+	written   []string
+}
+
+func (w *fakeWriter) Write(_ context.Context, rows []row) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	values := make([]string, len(rows))
+	for i, r := range rows {
+		values[i] = r.values[0].(string)
+	}
+	w.batches = append(w.batches, values)
+
+	if w.transient > 0 {
+		w.transient--
+		return errors.New("connection reset by peer")
+	}
+	if slices.Contains(values, "reject") {
+		return &clickhouse.Exception{Code: 53, Name: "DB::Exception", Message: "type mismatch"}
+	}
+
+	w.written = append(w.written, values...)
+	return nil
+}
+
+func (w *fakeWriter) rowCount() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.written)
+}
+
+type fakeDLQ struct {
+	msgs []kafka.Message
+}
+
+func (d *fakeDLQ) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	d.msgs = append(d.msgs, msgs...)
+	return nil
+}
+
+const testTopic = "user-events"
+
+func testPipelines(writer *fakeWriter) map[string]*pipeline {
+	return map[string]*pipeline{
+		testTopic: {
+			dlqTopic: testTopic + "-dlq",
+			decode: func(msg kafka.Message) ([]any, error) {
+				if string(msg.Value) == "bad" {
+					return nil, permanent(errors.New("invalid event"))
+				}
+				return []any{string(msg.Value)}, nil
+			},
+			writer: writer,
+		},
+	}
+}
+
+func messages(values ...string) []kafka.Message {
+	msgs := make([]kafka.Message, len(values))
+	for i, v := range values {
+		msgs[i] = kafka.Message{Topic: testTopic, Offset: int64(i + 1), Value: []byte(v)}
+	}
+	return msgs
+}
+
+func startRun(t *testing.T, reader *fakeReader, writer *fakeWriter, dlq *fakeDLQ, cfg Config) (context.CancelFunc, <-chan error) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- run(ctx, reader, testPipelines(writer), dlq, cfg) }()
+
+	return cancel, errCh
+}
+
+func wait(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s: timed out", what)
+	}
+}
+
+func TestRunCommitsAfterWrite(t *testing.T) {
+	// Первая запись пачки обрывается: офсеты не коммитятся, пока повтор
+	// не запишет строки
+	writer := &fakeWriter{transient: 1}
+	done := make(chan struct{})
+	reader := &fakeReader{msgs: messages("a", "bad", "b"), writer: writer, done: done}
+	dlq := &fakeDLQ{}
+
+	cfg := Config{BatchSize: 3, FlushInterval: time.Hour, MaxBackoff: 10 * time.Millisecond, ShutdownTimeout: time.Second}
+	cancel, errCh := startRun(t, reader, writer, dlq, cfg)
+
+	wait(t, done, "commit")
+	cancel()
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Fatalf("run returned %v", err)
+	}
+
+	if len(writer.batches) != 2 || !slices.Equal(writer.written, []string{"a", "b"}) {
+		t.Fatalf("batches = %v, written = %v", writer.batches, writer.written)
+	}
+	if !slices.Equal(reader.writtenAtCommit, []int{2}) {
+		t.Fatalf("rows written at commits = %v, want [2]", reader.writtenAtCommit)
+	}
+	if len(reader.committed) != 3 {
+		t.Fatalf("committed %d messages, want 3", len(reader.committed))
+	}
+	if len(dlq.msgs) != 1 || string(dlq.msgs[0].Value) != "bad" || dlq.msgs[0].Topic != testTopic+"-dlq" {
+		t.Fatalf("dlq = %+v", dlq.msgs)
+	}
+}
+
+func TestRunFlushesOnInterval(t *testing.T) {
+	writer := &fakeWriter{}
+	done := make(chan struct{})
+	reader := &fakeReader{msgs: messages("a", "b"), writer: writer, done: done}
+
+	cfg := Config{BatchSize: 100, FlushInterval: 20 * time.Millisecond, MaxBackoff: 10 * time.Millisecond, ShutdownTimeout: time.Second}
+	started := time.Now()
+	cancel, errCh := startRun(t, reader, writer, &fakeDLQ{}, cfg)
+
+	wait(t, done, "commit")
+	if elapsed := time.Since(started); elapsed < cfg.FlushInterval {
+		t.Errorf("flushed after %s, before FlushInterval", elapsed)
+	}
+	cancel()
+	<-errCh
+
+	if len(writer.batches) != 1 || !slices.Equal(writer.written, []string{"a", "b"}) {
+		t.Fatalf("batches = %v, written = %v", writer.batches, writer.written)
+	}
+	if len(reader.committed) != 2 {
+		t.Fatalf("committed %d messages, want 2", len(reader.committed))
+	}
+}
+
+func TestRunFlushesOnShutdown(t *testing.T) {
+	writer := &fakeWriter{}
+	reader := &fakeReader{msgs: messages("a", "b"), writer: writer, drained: make(chan struct{})}
+
+	cfg := Config{BatchSize: 100, FlushInterval: time.Hour, MaxBackoff: 10 * time.Millisecond, ShutdownTimeout: time.Second}
+	cancel, errCh := startRun(t, reader, writer, &fakeDLQ{}, cfg)
+
+	wait(t, reader.drained, "fetch")
+	cancel()
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Fatalf("run returned %v", err)
+	}
+
+	if !slices.Equal(writer.written, []string{"a", "b"}) || len(reader.committed) != 2 {
+		t.Fatalf("written = %v, committed %d messages", writer.written, len(reader.committed))
+	}
+}
+
+func TestWriteRowsSplitsRejectedBatch(t *testing.T) {
+	writer := &fakeWriter{}
+
+	var rows []row
+	for _, msg := range messages("a", "reject", "b") {
+		rows = append(rows, row{msg: msg, values: []any{string(msg.Value)}})
+	}
+
+	dead, err := writeRows(context.Background(), writer, rows, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("writeRows: %v", err)
+	}
+
+	wantBatches := [][]string{{"a", "reject", "b"}, {"a"}, {"reject"}, {"b"}}
+	if !slices.EqualFunc(writer.batches, wantBatches, slices.Equal[[]string]) {
+		t.Fatalf("batches = %v, want %v", writer.batches, wantBatches)
+	}
+	if !slices.Equal(writer.written, []string{"a", "b"}) {
+		t.Fatalf("written = %v", writer.written)
+	}
+
+	if len(dead) != 1 || string(dead[0].msg.Value) != "reject" {
+		t.Fatalf("dead = %+v", dead)
+	}
+	var exception *clickhouse.Exception
+	if !errors.As(dead[0].err, &exception) {
+		t.Fatalf("dead letter error = %v, want clickhouse exception", dead[0].err)
+	}
+}
+
+func TestWriteRowsStopsOnCancel(t *testing.T) {
+	writer := &fakeWriter{transient: 1 << 10}
+	rows := []row{{msg: messages("a")[0], values: []any{"a"}}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	dead, err := writeRows(ctx, writer, rows, 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) || dead != nil {
+		t.Fatalf("writeRows = %v, %v; want deadline exceeded", dead, err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// clickhouseWriter пишет пачку строк в таблицу подписки
+type clickhouseWriter struct {
+	conn    clickhouse.Conn
+	table   string
+	columns []string
+}
+
+func newClickhouseWriter(conn clickhouse.Conn, database, table string, h handler) *clickhouseWriter {
+	return &clickhouseWriter{
+		conn:    conn,
+		table:   database + "." + table,
+		columns: h.columns,
+	}
+}
+
+// ensureTable создаёт таблицу подписки, если её ещё нет
+func ensureTable(ctx context.Context, conn clickhouse.Conn, database, table string, h handler) error {
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s %s", database, table, h.schema)
+	if err := conn.Exec(ctx, query); err != nil {
+		return fmt.Errorf("creating table %s.%s: %w", database, table, err)
+	}
+	return nil
+}
+
+func (w *clickhouseWriter) Write(ctx context.Context, rows []row) (err error) {
+	links := make([]trace.Link, 0, len(rows))
+	for _, r := range rows {
+		if r.link.SpanContext.IsValid() {
+			links = append(links, r.link)
+		}
+	}
+
+	ctx, span := tracer.Start(ctx, "clickhouse insert "+w.table,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			semconv.DBSystemClickhouse,
+			attribute.Int("db.rows", len(rows)),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	query := fmt.Sprintf("INSERT INTO %s (%s)", w.table, strings.Join(w.columns, ", "))
+	batch, err := w.conn.PrepareBatch(ctx, query)
+	if err != nil {
+		return fmt.Errorf("clickhouse prepare batch error: %w", err)
+	}
+	// Abort освобождает соединение, если Send не был вызван
+	defer batch.Abort() //nolint:errcheck
+
+	for _, r := range rows {
+		if err := batch.Append(r.values...); err != nil {
+			return permanent(fmt.Errorf("clickhouse append batch error: %w", err))
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("clickhouse send batch error: %w", err)
+	}
+
+	return nil
+}
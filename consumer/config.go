@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/caarlos0/env/v6"
+	"gopkg.in/yaml.v3"
+)
+
+// Config — настройки подключения и пачек из переменных окружения. Топики
+// и их таблицы описаны в файле CONSUMER_CONFIG.
+type Config struct {
+	Brokers string `env:"KAFKA_BOOTSTRAP_SERVERS" envDefault:"kafka:9092"`
+	// Topics — на какие топики из файла подписаться; пусто — на все
+	Topics             []string      `env:"KAFKA_TOPICS" envSeparator:","`
+	ConfigFile         string        `env:"CONSUMER_CONFIG" envDefault:"consumer.yaml"`
+	GroupID            string        `env:"KAFKA_GROUP_ID" envDefault:"event-logger-group"`
+	ClickHouseAddr     string        `env:"CLICKHOUSE_ADDR" envDefault:"clickhouse-server:9000"`
+	ClickHouseDatabase string        `env:"CLICKHOUSE_DATABASE" envDefault:"marketplace_analytics"`
+	ClickHouseUser     string        `env:"CLICKHOUSE_USER" envDefault:"default"`
+	ClickHousePassword string        `env:"CLICKHOUSE_PASSWORD"`
+	BatchSize          int           `env:"BATCH_SIZE" envDefault:"1000"`
+	FlushInterval      time.Duration `env:"FLUSH_INTERVAL" envDefault:"1s"`
+	MaxBackoff         time.Duration `env:"MAX_BACKOFF" envDefault:"30s"`
+	// ShutdownTimeout — сколько ждать записи последней пачки при остановке
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"10s"`
+	// AvroSchemaDir — каталог с ревизиями Avro-схем сверх встроенных в
+	// модуль events: новые ревизии кладутся сюда до того, как отправители
+	// начнут по ним писать
+	AvroSchemaDir string `env:"AVRO_SCHEMA_DIR"`
+
+	// Subscriptions заполняется из файла
+	Subscriptions []Subscription `env:"-"`
+}
+
+// Subscription — топик, его обработчик и таблица ClickHouse
+type Subscription struct {
+	Topic   string `yaml:"-"`
+	Handler string `yaml:"handler"`
+	Table   string `yaml:"table"`
+	// DLQTopic по умолчанию — имя топика с суффиксом .dlq
+	DLQTopic string `yaml:"dlq_topic"`
+}
+
+type fileConfig struct {
+	Topics map[string]Subscription `yaml:"topics"`
+}
+
+func loadConfig() (Config, error) {
+	var cfg Config
+	if err := env.Parse(&cfg); err != nil {
+		return Config{}, fmt.Errorf("parsing environment variables: %w", err)
+	}
+	if cfg.BatchSize <= 0 || cfg.FlushInterval <= 0 {
+		return Config{}, errors.New("BATCH_SIZE and FLUSH_INTERVAL must be positive")
+	}
+
+	data, err := os.ReadFile(cfg.ConfigFile)
+	if err != nil {
+		return Config{}, fmt.Errorf("reading %s: %w", cfg.ConfigFile, err)
+	}
+
+	var file fileConfig
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return Config{}, fmt.Errorf("parsing %s: %w", cfg.ConfigFile, err)
+	}
+
+	topics := cfg.Topics
+	if len(topics) == 0 {
+		for topic := range file.Topics {
+			topics = append(topics, topic)
+		}
+		slices.Sort(topics)
+	}
+
+	seen := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		if seen[topic] {
+			continue
+		}
+		seen[topic] = true
+
+		sub, ok := file.Topics[topic]
+		if !ok {
+			return Config{}, fmt.Errorf("topic %q is not described in %s", topic, cfg.ConfigFile)
+		}
+		if _, ok := handlers[sub.Handler]; !ok {
+			return Config{}, fmt.Errorf("topic %q: unknown handler %q", topic, sub.Handler)
+		}
+		if sub.Table == "" {
+			return Config{}, fmt.Errorf("topic %q: table is required", topic)
+		}
+
+		sub.Topic = topic
+		if sub.DLQTopic == "" {
+			sub.DLQTopic = topic + ".dlq"
+		}
+		cfg.Subscriptions = append(cfg.Subscriptions, sub)
+	}
+
+	if len(cfg.Subscriptions) == 0 {
+		return Config{}, fmt.Errorf("no topics configured in %s", cfg.ConfigFile)
+	}
+
+	return cfg, nil
+}
+
+func (c Config) topicNames() []string {
+	topics := make([]string, 0, len(c.Subscriptions))
+	for _, sub := range c.Subscriptions {
+		topics = append(topics, sub.Topic)
+	}
+	return topics
+}
@@ -2,33 +2,46 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/segmentio/kafka-go"
 	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/segmentio/kafka-go"
 )
 
-type Event struct {
-	URL       string   `json:"url"`
-	Ids       []string `json:"ids"`
-	Action    string   `json:"action"`
-	Timestamp string   `json:"timestamp"`
-}
-
 func main() {
-	brokers := []string{"kafka:9092"}
-	topic := "user-events"
-	groupID := "event-logger-group"
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("unable to load config: %v", err)
+	}
+	if cfg.AvroSchemaDir != "" {
+		if formats, err = loadFormats(cfg.AvroSchemaDir); err != nil {
+			log.Fatalf("unable to load avro schemas: %v", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplay(ctx, cfg, os.Args[2:]); err != nil {
+			log.Fatalf("replay failed: %v", err)
+		}
+		return
+	}
 
 	chConn, err := clickhouse.Open(&clickhouse.Options{
-		Addr: []string{"clickhouse-server:9000"},
+		Addr: []string{cfg.ClickHouseAddr},
 		Auth: clickhouse.Auth{
-			Database: "marketplace_analytics",
-			Username: "default",
-			Password: "",
+			Database: cfg.ClickHouseDatabase,
+			Username: cfg.ClickHouseUser,
+			Password: cfg.ClickHousePassword,
 		},
 		Debug: false,
 	})
@@ -37,12 +50,34 @@ func main() {
 	}
 	defer chConn.Close()
 
-	ctx := context.Background()
+	shutdownTracing, err := setupTracing(ctx)
+	if err != nil {
+		log.Fatalf("tracing setup error: %v", err)
+	}
+	defer shutdownTracing(context.Background()) //nolint:errcheck
+
+	pipelines := make(map[string]*pipeline, len(cfg.Subscriptions))
+	for _, sub := range cfg.Subscriptions {
+		h := handlers[sub.Handler]
+
+		err := retry(ctx, cfg.MaxBackoff, func() error {
+			return ensureTable(ctx, chConn, cfg.ClickHouseDatabase, sub.Table, h)
+		})
+		if err != nil {
+			log.Fatalf("clickhouse table setup error: %v", err)
+		}
+
+		pipelines[sub.Topic] = &pipeline{
+			dlqTopic: sub.DLQTopic,
+			decode:   h.decode,
+			writer:   newClickhouseWriter(chConn, cfg.ClickHouseDatabase, sub.Table, h),
+		}
+	}
 
 	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:     brokers,
-		GroupID:     groupID,
-		Topic:       topic,
+		Brokers:     strings.Split(cfg.Brokers, ","),
+		GroupID:     cfg.GroupID,
+		GroupTopics: cfg.topicNames(),
 		StartOffset: kafka.FirstOffset,
 		MinBytes:    1,
 		MaxBytes:    10e6,
@@ -50,48 +85,23 @@ func main() {
 	})
 	defer reader.Close()
 
-	fmt.Println("Kafka consumer started. Listening for events...")
-
-	for {
-		msg, err := reader.ReadMessage(ctx)
-		if err != nil {
-			log.Printf("error while reading message: %v\n", err)
-			continue
-		}
-
-		var event Event
-		err = json.Unmarshal(msg.Value, &event)
-		if err != nil {
-			log.Printf("error while decoding message: %v\n", err)
-			continue
-		}
+	fmt.Printf("Kafka consumer started. Listening for events: %s\n", strings.Join(cfg.topicNames(), ", "))
 
-		// Парсим timestamp в time.Time
-		eventTime, err := time.Parse(time.RFC3339, event.Timestamp)
-		if err != nil {
-			log.Printf("error while parsing timestamp: %v\n", err)
-			eventTime = time.Now()
-		}
+	dlq := newWriter(cfg.Brokers)
+	defer dlq.Close()
 
-		// Пишем событие в ClickHouse
-		batch, err := chConn.PrepareBatch(ctx, "INSERT INTO marketplace_analytics.user_events (event_time, url, action, ids)")
-		if err != nil {
-			log.Printf("clickhouse prepare batch error: %v", err)
-			continue
-		}
-
-		err = batch.Append(eventTime, event.URL, event.Action, event.Ids)
-		if err != nil {
-			log.Printf("clickhouse append batch error: %v", err)
-			continue
-		}
+	if err := run(ctx, reader, pipelines, dlq, cfg); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatalf("consumer stopped with error: %v", err)
+	}
 
-		err = batch.Send()
-		if err != nil {
-			log.Printf("clickhouse send batch error: %v", err)
-			continue
-		}
+	log.Println("Kafka consumer stopped")
+}
 
-		log.Printf("✅ received event: URL=%s, action=%s, timestamp=%s\n", event.URL, event.Action, event.Timestamp)
+func newWriter(brokers string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(strings.Split(brokers, ",")...),
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
 	}
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки, которыми сообщение снабжается при отправке в DLQ
+const (
+	headerDLQError     = "dlq.error"
+	headerDLQTopic     = "dlq.source.topic"
+	headerDLQPartition = "dlq.source.partition"
+	headerDLQOffset    = "dlq.source.offset"
+	headerDLQFailedAt  = "dlq.failed_at"
+	// headerDLQAttempts сохраняется при replay, поэтому показывает, сколько
+	// раз сообщение уже попадало в DLQ
+	headerDLQAttempts = "dlq.attempts"
+)
+
+// permanentError — ошибка, которую повтор не исправит: битое событие или
+// строка, отвергнутая ClickHouse. Такие сообщения уходят в DLQ.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// isPermanent — ошибка данных, а не сети: исключение, пришедшее от сервера
+// ClickHouse, или ошибка преобразования при Append
+func isPermanent(err error) bool {
+	var pe *permanentError
+	if errors.As(err, &pe) {
+		return true
+	}
+	var exception *clickhouse.Exception
+	return errors.As(err, &exception)
+}
+
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// deadLetter — сообщение, которое не удалось обработать, и причина
+type deadLetter struct {
+	msg kafka.Message
+	err error
+}
+
+// toDLQ готовит копию исходного сообщения для топика dlqTopic: ключ, тело
+// и заголовки (в том числе traceparent) сохраняются, добавляются заголовки
+// с ошибкой и положением исходного сообщения
+func toDLQ(dlqTopic string, d deadLetter, now time.Time) kafka.Message {
+	attempts := 1
+	headers := make([]kafka.Header, 0, len(d.msg.Headers)+6)
+	for _, h := range d.msg.Headers {
+		if h.Key == headerDLQAttempts {
+			if n, err := strconv.Atoi(string(h.Value)); err == nil {
+				attempts = n + 1
+			}
+			continue
+		}
+		if strings.HasPrefix(h.Key, "dlq.") {
+			continue
+		}
+		headers = append(headers, h)
+	}
+
+	headers = append(headers,
+		kafka.Header{Key: headerDLQError, Value: []byte(d.err.Error())},
+		kafka.Header{Key: headerDLQTopic, Value: []byte(d.msg.Topic)},
+		kafka.Header{Key: headerDLQPartition, Value: []byte(strconv.Itoa(d.msg.Partition))},
+		kafka.Header{Key: headerDLQOffset, Value: []byte(strconv.FormatInt(d.msg.Offset, 10))},
+		kafka.Header{Key: headerDLQFailedAt, Value: []byte(now.UTC().Format(time.RFC3339))},
+		kafka.Header{Key: headerDLQAttempts, Value: []byte(strconv.Itoa(attempts))},
+	)
+
+	return kafka.Message{
+		Topic:   dlqTopic,
+		Key:     d.msg.Key,
+		Value:   d.msg.Value,
+		Headers: headers,
+	}
+}
+
+func header(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
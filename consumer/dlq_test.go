@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func headerMap(msg kafka.Message) map[string]string {
+	m := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		m[h.Key] = string(h.Value)
+	}
+	return m
+}
+
+func headerKeys(msg kafka.Message) []string {
+	keys := make([]string, len(msg.Headers))
+	for i, h := range msg.Headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+func TestToDLQ(t *testing.T) {
+	failedAt := time.Date(2025, 6, 1, 10, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	tests := []struct {
+		name     string
+		headers  []kafka.Header
+		attempts string
+	}{
+		{
+			name:     "first failure",
+			headers:  []kafka.Header{{Key: "traceparent", Value: []byte("00-abc-01")}, {Key: "content-type", Value: []byte("application/json")}},
+			attempts: "1",
+		},
+		{
+			name: "replayed message failed again",
+			headers: []kafka.Header{
+				{Key: "traceparent", Value: []byte("00-abc-01")},
+				{Key: headerDLQAttempts, Value: []byte("2")},
+			},
+			attempts: "3",
+		},
+		{
+			name: "stale dlq headers are replaced",
+			headers: []kafka.Header{
+				{Key: headerDLQError, Value: []byte("old error")},
+				{Key: headerDLQOffset, Value: []byte("1")},
+				{Key: "traceparent", Value: []byte("00-abc-01")},
+			},
+			attempts: "1",
+		},
+		{
+			name:     "broken attempts counter",
+			headers:  []kafka.Header{{Key: headerDLQAttempts, Value: []byte("many")}},
+			attempts: "1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := kafka.Message{
+				Topic:     "user-events",
+				Partition: 2,
+				Offset:    42,
+				Key:       []byte("key"),
+				Value:     []byte("value"),
+				Headers:   tt.headers,
+			}
+
+			out := toDLQ("user-events-dlq", deadLetter{msg: msg, err: errors.New("invalid event")}, failedAt)
+
+			if out.Topic != "user-events-dlq" || string(out.Key) != "key" || string(out.Value) != "value" {
+				t.Fatalf("message = %s %q %q", out.Topic, out.Key, out.Value)
+			}
+
+			got := headerMap(out)
+			want := map[string]string{
+				headerDLQError:     "invalid event",
+				headerDLQTopic:     "user-events",
+				headerDLQPartition: "2",
+				headerDLQOffset:    "42",
+				headerDLQFailedAt:  "2025-06-01T07:00:00Z",
+				headerDLQAttempts:  tt.attempts,
+			}
+			for _, h := range tt.headers {
+				if _, ok := want[h.Key]; !ok {
+					want[h.Key] = string(h.Value)
+				}
+			}
+			if len(got) != len(want) || len(out.Headers) != len(want) {
+				t.Fatalf("headers = %v, want %v", headerKeys(out), want)
+			}
+			for k, v := range want {
+				if got[k] != v {
+					t.Errorf("%s = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestFromDLQ(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  []kafka.Header
+		fallback string
+		topic    string
+		keys     []string
+	}{
+		{
+			name: "topic from header",
+			headers: []kafka.Header{
+				{Key: "traceparent", Value: []byte("00-abc-01")},
+				{Key: headerDLQError, Value: []byte("invalid event")},
+				{Key: headerDLQTopic, Value: []byte("comment-events")},
+				{Key: headerDLQPartition, Value: []byte("2")},
+				{Key: headerDLQOffset, Value: []byte("42")},
+				{Key: headerDLQFailedAt, Value: []byte("2025-06-01T07:00:00Z")},
+				{Key: headerDLQAttempts, Value: []byte("2")},
+			},
+			fallback: "user-events",
+			topic:    "comment-events",
+			keys:     []string{"traceparent", headerDLQAttempts},
+		},
+		{
+			name: "fallback without source header",
+			headers: []kafka.Header{
+				{Key: headerDLQError, Value: []byte("invalid event")},
+				{Key: "content-type", Value: []byte("application/json")},
+			},
+			fallback: "user-events",
+			topic:    "user-events",
+			keys:     []string{"content-type"},
+		},
+		{
+			name:     "empty source header",
+			headers:  []kafka.Header{{Key: headerDLQTopic, Value: nil}},
+			fallback: "product-events",
+			topic:    "product-events",
+			keys:     []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := kafka.Message{Topic: "dlq", Offset: 7, Key: []byte("key"), Value: []byte("value"), Headers: tt.headers}
+
+			out := fromDLQ(msg, tt.fallback)
+
+			if out.Topic != tt.topic {
+				t.Errorf("topic = %q, want %q", out.Topic, tt.topic)
+			}
+			if string(out.Key) != "key" || string(out.Value) != "value" {
+				t.Errorf("key/value = %q %q", out.Key, out.Value)
+			}
+			if keys := headerKeys(out); !slices.Equal(keys, tt.keys) {
+				t.Errorf("headers = %v, want %v", keys, tt.keys)
+			}
+		})
+	}
+}
+
+// Повторно упавшее после replay сообщение получает следующий номер попытки
+func TestDLQRoundTripCountsAttempts(t *testing.T) {
+	msg := kafka.Message{Topic: "user-events", Value: []byte("bad")}
+	now := time.Now()
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		dead := toDLQ("user-events-dlq", deadLetter{msg: msg, err: errors.New("invalid event")}, now)
+		if got := header(dead, headerDLQAttempts); got != strconv.Itoa(attempt) {
+			t.Fatalf("attempt %d: %s = %q", attempt, headerDLQAttempts, got)
+		}
+		msg = fromDLQ(dead, "")
+		if msg.Topic != "user-events" {
+			t.Fatalf("replayed to %q", msg.Topic)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"events"
+	"fmt"
+	"os"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// handler описывает события топика (см. модуль events): как разобрать
+// сообщение в строку таблицы и какие у таблицы колонки
+type handler struct {
+	columns []string
+	// schema — описание колонок и движка для CREATE TABLE
+	schema string
+	decode func(msg kafka.Message) ([]any, error)
+}
+
+// handlers — обработчики по имени из consumer.yaml
+var handlers = map[string]handler{
+	"user_events": {
+		columns: []string{"event_time", "url", "action", "ids"},
+		schema: `(
+	event_time DateTime,
+	url String,
+	action String,
+	ids Array(String)
+) ENGINE = MergeTree ORDER BY event_time`,
+		decode: decodeUserEvent,
+	},
+	"product_events": {
+		columns: []string{"event_time", "action", "product_id", "title", "price", "seller_name", "category", "version"},
+		schema: `(
+	event_time DateTime64(3),
+	action LowCardinality(String),
+	product_id String,
+	title String,
+	price Int64,
+	seller_name String,
+	category String,
+	version Int64
+) ENGINE = MergeTree ORDER BY (product_id, event_time)`,
+		decode: decodeProductEvent,
+	},
+	"comment_events": {
+		columns: []string{"event_time", "action", "comment_id", "product_id", "user_id", "comment"},
+		schema: `(
+	event_time DateTime64(3),
+	action LowCardinality(String),
+	comment_id String,
+	product_id String,
+	user_id String,
+	comment String
+) ENGINE = MergeTree ORDER BY (product_id, event_time)`,
+		decode: decodeCommentEvent,
+	},
+}
+
+// formats — форматы событий, которые понимает consumer; см. AvroSchemaDir
+var formats = events.DefaultFormats
+
+func loadFormats(avroSchemaDir string) (events.Formats, error) {
+	reg, err := events.LoadAvroRegistry(os.DirFS(avroSchemaDir))
+	if err != nil {
+		return nil, err
+	}
+	return events.NewFormats(events.NewAvro(reg)), nil
+}
+
+// parseEvent разбирает конверт события в формате из заголовка
+// content-type. Сообщения, записанные до перехода на конверты,
+// переводятся в конверт, пока остаются в топиках.
+func parseEvent(msg kafka.Message) (events.Envelope, error) {
+	env, err := formats.Unmarshal(header(msg, events.ContentTypeHeader), msg.Value)
+	if errors.Is(err, events.ErrNotEnvelope) {
+		return events.ParseLegacy(msg.Value)
+	}
+	return env, err
+}
+
+func decodeUserEvent(msg kafka.Message) ([]any, error) {
+	env, err := parseEvent(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := events.Decode[events.UserAction](env)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{event.OccurredAt, event.Payload.URL, event.Payload.Action, event.Payload.IDs}, nil
+}
+
+func decodeProductEvent(msg kafka.Message) ([]any, error) {
+	env, err := parseEvent(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := env.Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	var p events.Product
+	switch e := payload.(type) {
+	case events.ProductCreated:
+		p = e.Product
+	case events.ProductUpdated:
+		p = e.Product
+	case events.ProductDeleted:
+		p = events.Product{ProductID: e.ProductID, Version: e.Version}
+	default:
+		return nil, fmt.Errorf("unexpected event %s in product events", env.Type)
+	}
+
+	return []any{
+		env.OccurredAt, string(env.Type), p.ProductID, p.Title,
+		int64(p.Price), p.SellerName, p.CategoryName, int64(p.Version),
+	}, nil
+}
+
+func decodeCommentEvent(msg kafka.Message) ([]any, error) {
+	env, err := parseEvent(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := env.Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	switch e := payload.(type) {
+	case events.CommentCreated:
+		return []any{env.OccurredAt, string(env.Type), e.CommentID, e.ProductID, e.UserID, e.Comment}, nil
+	case events.CommentUpdated:
+		return []any{env.OccurredAt, string(env.Type), e.CommentID, e.ProductID, e.UserID, e.Comment}, nil
+	case events.CommentDeleted:
+		return []any{env.OccurredAt, string(env.Type), e.CommentID, e.ProductID, e.UserID, ""}, nil
+	default:
+		return nil, fmt.Errorf("unexpected event %s in comment events", env.Type)
+	}
+}
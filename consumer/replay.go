@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// runReplay выполняет подкоманду `consumer replay [-limit N] [-idle D]
+// [-dry-run]`: сообщения из DLQ подписок (их можно сузить KAFKA_TOPICS)
+// публикуются обратно в исходный топик.
+// Отдельная группа запоминает, что уже переиграно, поэтому повторный
+// запуск продолжит с места остановки. Команда завершается, когда новых
+// сообщений нет дольше idle.
+func runReplay(ctx context.Context, cfg Config, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	limit := fs.Int("limit", 0, "replay at most N messages (0 — all)")
+	idle := fs.Duration("idle", 5*time.Second, "stop after no new DLQ messages for this long")
+	dryRun := fs.Bool("dry-run", false, "print messages without publishing or committing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// sources — исходный топик по DLQ на случай, если заголовок потерян
+	sources := make(map[string]string, len(cfg.Subscriptions))
+	dlqTopics := make([]string, 0, len(cfg.Subscriptions))
+	for _, sub := range cfg.Subscriptions {
+		sources[sub.DLQTopic] = sub.Topic
+		dlqTopics = append(dlqTopics, sub.DLQTopic)
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     strings.Split(cfg.Brokers, ","),
+		GroupID:     cfg.GroupID + "-replay",
+		GroupTopics: dlqTopics,
+		StartOffset: kafka.FirstOffset,
+		MaxWait:     time.Second,
+	})
+	defer reader.Close()
+
+	writer := newWriter(cfg.Brokers)
+	defer writer.Close()
+
+	replayed := 0
+	for *limit == 0 || replayed < *limit {
+		fetchCtx, cancel := context.WithTimeout(ctx, *idle)
+		msg, err := reader.FetchMessage(fetchCtx)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				break
+			}
+			return fmt.Errorf("fetching DLQ message: %w", err)
+		}
+
+		out := fromDLQ(msg, sources[msg.Topic])
+
+		log.Printf("replaying: dlq=%s offset=%d -> topic=%s (source partition=%s offset=%s, attempts=%s, error=%q)\n",
+			msg.Topic, msg.Offset, out.Topic,
+			header(msg, headerDLQPartition), header(msg, headerDLQOffset),
+			header(msg, headerDLQAttempts), header(msg, headerDLQError))
+
+		if !*dryRun {
+			if err := writer.WriteMessages(ctx, out); err != nil {
+				return fmt.Errorf("publishing to %s: %w", out.Topic, err)
+			}
+			if err := reader.CommitMessages(ctx, msg); err != nil {
+				return fmt.Errorf("committing DLQ offset: %w", err)
+			}
+		}
+		replayed++
+	}
+
+	log.Printf("replay finished: messages=%d dry_run=%t\n", replayed, *dryRun)
+	return nil
+}
+
+// fromDLQ восстанавливает исходное сообщение: топик берётся из заголовка
+// (по умолчанию — fallbackTopic), служебные заголовки DLQ снимаются, кроме
+// счётчика попыток
+func fromDLQ(msg kafka.Message, fallbackTopic string) kafka.Message {
+	topic := header(msg, headerDLQTopic)
+	if topic == "" {
+		topic = fallbackTopic
+	}
+
+	headers := make([]kafka.Header, 0, len(msg.Headers))
+	for _, h := range msg.Headers {
+		if strings.HasPrefix(h.Key, "dlq.") && h.Key != headerDLQAttempts {
+			continue
+		}
+		headers = append(headers, h)
+	}
+
+	return kafka.Message{
+		Topic:   topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	}
+}
@@ -34,12 +34,34 @@ INDEX_MAPPING = {
                     'keyword': {
                         'type': 'keyword',
                         'ignore_above': 256
+                    },
+                    'suggest': {
+                        'type': 'completion'
                     }
                 }
             },
-            'seller_name': {
+            'description': {
                 'type': 'text',
                 'analyzer': 'russian_analyzer'
+            },
+            'price': {'type': 'integer'},
+            'category_name': {
+                'type': 'keyword',
+                'fields': {
+                    'suggest': {
+                        'type': 'completion'
+                    }
+                }
+            },
+            'seller_name': {
+                'type': 'text',
+                'analyzer': 'russian_analyzer',
+                'fields': {
+                    'keyword': {
+                        'type': 'keyword',
+                        'ignore_above': 256
+                    }
+                }
             }
         }
     }
@@ -58,6 +80,7 @@ COMMENT_INDEX_MAPPING = {
     'mappings': {
         'properties': {
             'comment_id': {'type': 'keyword'},
+            'product_id': {'type': 'keyword'},
             'comment': {
                 'type': 'text',
                 'analyzer': 'russian_analyzer'
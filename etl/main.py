@@ -45,6 +45,9 @@ class ETLService:
             transformed_data = {
                 'product_id': data.get('product_id'),
                 'title': data.get('title', data.get('name', '')),
+                'description': data.get('description', ''),
+                'price': data.get('price', 0),
+                'category_name': data.get('category_name') or None,
                 'seller_name': data.get('seller_name', '')
             }
             
@@ -58,6 +61,11 @@ class ETLService:
         try:
             logger.info(f"RAW incoming data: {json.dumps(data, ensure_ascii=False)}")
             logger.info(f"Starting to process product data: {json.dumps(data, ensure_ascii=False)}")
+
+            if data.get('action') == 'product_deleted':
+                if not self.es_service.delete_product(data.get('product_id')):
+                    logger.error(f"Failed to delete product {data.get('product_id')}")
+                return
             
             transformed_data = self.transform_product_data(data)
             if not transformed_data:
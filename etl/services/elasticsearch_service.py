@@ -89,6 +89,9 @@ class ElasticsearchService:
                 document = {
                     'product_id': product_id,
                     'title': product_data.get('title', ''),
+                    'description': product_data.get('description', ''),
+                    'price': product_data.get('price', 0),
+                    'category_name': product_data.get('category_name') or None,
                     'seller_name': product_data.get('seller_name', '')
                 }
 
@@ -140,6 +143,7 @@ class ElasticsearchService:
 
                 document = {
                     'comment_id': comment_id,
+                    'product_id': comment_data.get('product_id'),
                     'comment': comment_data.get('comment', '')
                 }
 
@@ -171,6 +175,32 @@ class ElasticsearchService:
                 logger.error(f"Error indexing comment {comment_data.get('comment_id')}: {str(e)}")
                 return False
 
+    def delete_product(self, product_id):
+        if not self.es:
+            logger.info("Elasticsearch connection lost, reinitializing...")
+            self._initialize_elasticsearch()
+
+        try:
+            self.es.delete(index=self.index, id=product_id, refresh=True, ignore=[404])
+            logger.info(f"Successfully deleted product {product_id}")
+            return True
+        except Exception as e:
+            logger.error(f"Error deleting product {product_id}: {str(e)}")
+            return False
+
+    def delete_comment(self, comment_id):
+        if not self.es:
+            logger.info("Elasticsearch connection lost, reinitializing...")
+            self._initialize_elasticsearch()
+
+        try:
+            self.es.delete(index=self.comment_index, id=comment_id, refresh=True, ignore=[404])
+            logger.info(f"Successfully deleted comment {comment_id}")
+            return True
+        except Exception as e:
+            logger.error(f"Error deleting comment {comment_id}: {str(e)}")
+            return False
+
     def close(self):
         if self.es:
             try:
@@ -6,6 +6,41 @@ from config import KAFKA_CONFIG
 
 logger = logging.getLogger(__name__)
 
+JSON_CONTENT_TYPE = 'application/json'
+
+# События, которые ETL переносит в Elasticsearch
+HANDLED_ACTIONS = (
+    'product_created',
+    'product_updated',
+    'product_deleted',
+    'comment_created',
+    'comment_updated',
+    'comment_deleted',
+)
+
+
+# Конверт события (см. модуль events) приводится к плоскому виду, который
+# ожидают обработчики: поля payload и action из type (product.created ->
+# product_created). Сообщения старого формата возвращаются как есть.
+def unwrap_event(data):
+    if 'type' in data and isinstance(data.get('payload'), dict):
+        flat = dict(data['payload'])
+        flat['action'] = data['type'].replace('.', '_')
+        flat['timestamp'] = data.get('occurred_at')
+        return flat
+    return data
+
+
+# Формат события из заголовка content-type; сообщения без заголовка
+# записаны в JSON. Avro ETL не читает: с KAFKA_EVENT_FORMAT=avro каталог
+# пишет в Avro и comment-events, такие сообщения пропускаются с warning.
+def content_type(message):
+    for key, value in message.headers or []:
+        if key == 'content-type':
+            return value.decode('utf-8')
+    return JSON_CONTENT_TYPE
+
+
 class KafkaService:
     def __init__(self, topic=None):
         self.config = KAFKA_CONFIG
@@ -25,7 +60,6 @@ class KafkaService:
                     bootstrap_servers=self.config['bootstrap_servers'],
                     group_id=self.config['group_id'],
                     auto_offset_reset='earliest',
-                    value_deserializer=lambda x: json.loads(x.decode('utf-8-sig')),
                     enable_auto_commit=True,
                     auto_commit_interval_ms=5000,
                     session_timeout_ms=30000,
@@ -59,14 +93,21 @@ class KafkaService:
                             logger.warning("Received empty message, skipping...")
                             continue
 
-                        data = message.value
+                        message_format = content_type(message)
+                        if not message_format.startswith(JSON_CONTENT_TYPE):
+                            logger.warning(f"Skipping message in unsupported format {message_format}")
+                            continue
+
+                        data = json.loads(message.value.decode('utf-8-sig'))
                         logger.info(f"Message content: {json.dumps(data, ensure_ascii=False)}")
 
                         if not isinstance(data, dict):
                             logger.warning(f"Received invalid message format: {data}")
                             continue
 
-                        if data.get('action') in ['product_created', 'comment_created']:
+                        data = unwrap_event(data)
+
+                        if data.get('action') in HANDLED_ACTIONS:
                             logger.info(f"Processing {data.get('action')} event: {json.dumps(data, ensure_ascii=False)}")
                             callback(data)
                         else:
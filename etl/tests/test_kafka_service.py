@@ -0,0 +1,83 @@
+import json
+import os
+import sys
+import unittest
+from unittest import mock
+
+# Клиенты Kafka и Elasticsearch в тестах не нужны: подменяем модули до импорта
+sys.path.insert(0, os.path.dirname(os.path.dirname(os.path.abspath(__file__))))
+for name in ('kafka', 'elasticsearch', 'elasticsearch.exceptions', 'dotenv'):
+    sys.modules.setdefault(name, mock.MagicMock())
+
+from comment_etl import CommentETLService  # noqa: E402
+from services.kafka_service import KafkaService  # noqa: E402
+
+
+class StopConsuming(BaseException):
+    """Прерывает бесконечный цикл consume_messages после последнего сообщения"""
+
+
+class FakeConsumer:
+    def __init__(self, messages):
+        self.messages = messages
+
+    def __iter__(self):
+        yield from self.messages
+        raise StopConsuming()
+
+
+def envelope(event_type, payload, headers=None):
+    value = json.dumps({
+        'id': 'evt-1',
+        'type': event_type,
+        'version': 1,
+        'source': 'catalog',
+        'occurred_at': '2025-06-01T10:00:00Z',
+        'payload': payload,
+    }).encode('utf-8')
+    return mock.Mock(topic='comment-events', partition=0, offset=1, value=value,
+                     headers=headers or [('content-type', b'application/json')])
+
+
+def consume(messages):
+    service = CommentETLService()
+    service.es_service = mock.Mock()
+
+    with mock.patch.object(KafkaService, '_initialize_consumer'):
+        kafka_service = KafkaService(topic='comment-events')
+    kafka_service.consumer = FakeConsumer(messages)
+
+    try:
+        kafka_service.consume_messages(service.process_comment)
+    except StopConsuming:
+        pass
+    return service.es_service
+
+
+class ConsumeMessagesTest(unittest.TestCase):
+    def test_comment_deleted_removes_document(self):
+        es = consume([envelope('comment.deleted', {
+            'comment_id': 'c1', 'product_id': 'p1', 'user_id': 'u1',
+        })])
+
+        es.delete_comment.assert_called_once_with('c1')
+        es.index_comment.assert_not_called()
+
+    def test_comment_updated_reindexes_document(self):
+        es = consume([envelope('comment.updated', {
+            'comment_id': 'c1', 'product_id': 'p1', 'user_id': 'u1', 'comment': 'исправлено',
+        })])
+
+        es.index_comment.assert_called_once_with({
+            'comment_id': 'c1', 'product_id': 'p1', 'comment': 'исправлено',
+        })
+
+    def test_unknown_action_is_ignored(self):
+        es = consume([envelope('comment.liked', {'comment_id': 'c1'})])
+
+        es.index_comment.assert_not_called()
+        es.delete_comment.assert_not_called()
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,422 @@
+package events
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/soe"
+)
+
+// Avro-схемы конвертов лежат в каталоге avro, по файлу на ревизию:
+// <type>.v<version>.r<revision>.avsc. Их строит из JSON Schema go test
+// -update; совместимое изменение payload даёт новую ревизию, старые не
+// удаляются — по ним читаются уже записанные сообщения.
+//
+//go:embed avro/*.avsc
+var avroFiles embed.FS
+
+const avroDir = "avro"
+
+var avroFileName = regexp.MustCompile(`^(.+)\.v(\d+)\.r(\d+)\.avsc$`)
+
+// AvroRevision — ревизия Avro-схемы события
+type AvroRevision struct {
+	SchemaRef
+	Revision int
+}
+
+// FileName — имя файла ревизии в реестре
+func (r AvroRevision) FileName() string {
+	return fmt.Sprintf("%s.v%d.r%d.avsc", r.Type, r.Version, r.Revision)
+}
+
+type avroSchema struct {
+	revision AvroRevision
+	schema   avro.Schema
+	// header — маркер single-object encoding и отпечаток схемы, с него
+	// начинается каждое сообщение
+	header []byte
+}
+
+// AvroRegistry — файловый реестр Avro-схем. Сообщение начинается с
+// отпечатка схемы писателя (single-object encoding), по нему читатель
+// находит схему без сервиса реестра. Новую ревизию достаточно положить в
+// каталог получателя раньше, чем её начнёт писать отправитель.
+type AvroRegistry struct {
+	byFingerprint map[string]*avroSchema
+	byRevision    map[AvroRevision]*avroSchema
+	latest        map[key]*avroSchema
+}
+
+// LoadAvroRegistry загружает встроенные схемы и схемы из каталогов dirs
+func LoadAvroRegistry(dirs ...fs.FS) (*AvroRegistry, error) {
+	embedded, err := fs.Sub(avroFiles, avroDir)
+	if err != nil {
+		return nil, err
+	}
+
+	reg := &AvroRegistry{
+		byFingerprint: make(map[string]*avroSchema),
+		byRevision:    make(map[AvroRevision]*avroSchema),
+		latest:        make(map[key]*avroSchema),
+	}
+	for _, dir := range append([]fs.FS{embedded}, dirs...) {
+		if err := reg.load(dir); err != nil {
+			return nil, err
+		}
+	}
+	return reg, nil
+}
+
+func (r *AvroRegistry) load(dir fs.FS) error {
+	files, err := fs.Glob(dir, "*.avsc")
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		m := avroFileName.FindStringSubmatch(file)
+		if m == nil {
+			return fmt.Errorf("avro registry: unexpected file name %s", file)
+		}
+		version, _ := strconv.Atoi(m[2])
+		revision, _ := strconv.Atoi(m[3])
+		rev := AvroRevision{SchemaRef{Type(m[1]), version}, revision}
+
+		data, err := fs.ReadFile(dir, file)
+		if err != nil {
+			return fmt.Errorf("avro registry: %w", err)
+		}
+		// Ревизии одного типа называют записи одинаково, поэтому у каждой
+		// свой кэш имён
+		schema, err := avro.ParseBytesWithCache(data, "", &avro.SchemaCache{})
+		if err != nil {
+			return fmt.Errorf("avro registry: %s: %w", file, err)
+		}
+		fingerprint, err := soe.ComputeFingerprint(schema)
+		if err != nil {
+			return fmt.Errorf("avro registry: %s: %w", file, err)
+		}
+		header, err := soe.BuildHeaderForFingerprint(fingerprint)
+		if err != nil {
+			return fmt.Errorf("avro registry: %s: %w", file, err)
+		}
+
+		if prev, ok := r.byRevision[rev]; ok {
+			if !slices.Equal(prev.header, header) {
+				return fmt.Errorf("avro registry: %s differs from the already loaded revision", file)
+			}
+			continue
+		}
+		if prev, ok := r.byFingerprint[string(fingerprint)]; ok {
+			return fmt.Errorf("avro registry: %s repeats %s", file, prev.revision.FileName())
+		}
+
+		s := &avroSchema{revision: rev, schema: schema, header: header}
+		r.byFingerprint[string(fingerprint)] = s
+		r.byRevision[rev] = s
+
+		k := key{rev.Type, rev.Version}
+		if latest, ok := r.latest[k]; !ok || latest.revision.Revision < rev.Revision {
+			r.latest[k] = s
+		}
+	}
+
+	return nil
+}
+
+// Revisions перечисляет загруженные ревизии
+func (r *AvroRegistry) Revisions() []AvroRevision {
+	revs := make([]AvroRevision, 0, len(r.byRevision))
+	for rev := range r.byRevision {
+		revs = append(revs, rev)
+	}
+	slices.SortFunc(revs, func(a, b AvroRevision) int {
+		if c := strings.Compare(string(a.Type), string(b.Type)); c != 0 {
+			return c
+		}
+		if a.Version != b.Version {
+			return a.Version - b.Version
+		}
+		return a.Revision - b.Revision
+	})
+	return revs
+}
+
+// Avro — Avro со встроенным реестром схем
+var Avro = NewAvro(mustLoadAvroRegistry())
+
+func mustLoadAvroRegistry() *AvroRegistry {
+	reg, err := LoadAvroRegistry()
+	if err != nil {
+		panic(fmt.Sprintf("events: %v", err))
+	}
+	return reg
+}
+
+// NewAvro возвращает формат Avro с реестром reg. Пишет по последней
+// ревизии схемы типа, читает по ревизии из отпечатка в сообщении; поля
+// type и version в сообщение не пишутся, их задаёт схема. occurred_at
+// хранится с точностью до микросекунд.
+func NewAvro(reg *AvroRegistry) Format {
+	return avroFormat{reg: reg}
+}
+
+type avroFormat struct {
+	reg *AvroRegistry
+}
+
+func (avroFormat) ContentType() string { return ContentTypeAvro }
+
+func (f avroFormat) Marshal(env Envelope) ([]byte, error) {
+	s, ok := f.reg.latest[key{env.Type, env.Version}]
+	if !ok {
+		return nil, fmt.Errorf("%w: no avro schema for %s v%d", ErrUnknownType, env.Type, env.Version)
+	}
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling %s event: %w", env.Type, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("marshaling %s event: %w", env.Type, err)
+	}
+
+	value, err := toAvro(s.schema, doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s v%d: %v", ErrInvalid, env.Type, env.Version, err)
+	}
+	body, err := avro.Marshal(s.schema, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s v%d: %v", ErrInvalid, env.Type, env.Version, err)
+	}
+
+	return append(slices.Clone(s.header), body...), nil
+}
+
+func (f avroFormat) Unmarshal(data []byte) (Envelope, error) {
+	fingerprint, body, err := soe.ParseHeader(data)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	s, ok := f.reg.byFingerprint[string(fingerprint)]
+	if !ok {
+		return Envelope{}, fmt.Errorf("%w: avro schema %x is not in the registry", ErrUnknownType, fingerprint)
+	}
+
+	var value any
+	if err := avro.Unmarshal(s.schema, body, &value); err != nil {
+		return Envelope{}, fmt.Errorf("%w: %s: %v", ErrInvalid, s.revision.FileName(), err)
+	}
+
+	doc, err := fromAvro(s.schema, value)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("%w: %s: %v", ErrInvalid, s.revision.FileName(), err)
+	}
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return Envelope{}, fmt.Errorf("%w: %s is not a record", ErrInvalid, s.revision.FileName())
+	}
+	obj["type"] = s.revision.Type
+	obj["version"] = s.revision.Version
+
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return Parse(raw)
+}
+
+// toAvro переводит разобранный JSON (числа — json.Number) в значение
+// для avro.Marshal по схеме
+func toAvro(schema avro.Schema, v any) (any, error) {
+	switch s := schema.(type) {
+	case *avro.RecordSchema:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: want object, got %T", s.FullName(), v)
+		}
+		rec := make(map[string]any, len(s.Fields()))
+		for _, field := range s.Fields() {
+			value, err := toAvro(field.Type(), obj[field.Name()])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field.Name(), err)
+			}
+			rec[field.Name()] = value
+		}
+		return rec, nil
+
+	case *avro.UnionSchema:
+		if v == nil {
+			return nil, nil
+		}
+		branch, err := nonNull(s)
+		if err != nil {
+			return nil, err
+		}
+		value, err := toAvro(branch, v)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{unionName(branch): value}, nil
+
+	case *avro.ArraySchema:
+		items, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("want array, got %T", v)
+		}
+		out := make([]any, len(items))
+		for i, item := range items {
+			value, err := toAvro(s.Items(), item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			out[i] = value
+		}
+		return out, nil
+
+	case *avro.PrimitiveSchema:
+		return primitiveToAvro(s, v)
+	}
+
+	return nil, fmt.Errorf("unsupported avro type %s", schema.Type())
+}
+
+func primitiveToAvro(s *avro.PrimitiveSchema, v any) (any, error) {
+	if v == nil {
+		return nil, fmt.Errorf("missing %s value", s.Type())
+	}
+
+	switch s.Type() {
+	case avro.String:
+		if str, ok := v.(string); ok {
+			return str, nil
+		}
+	case avro.Boolean:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case avro.Long:
+		if s.Logical() != nil && s.Logical().Type() == avro.TimestampMicros {
+			str, ok := v.(string)
+			if !ok {
+				break
+			}
+			return time.Parse(time.RFC3339Nano, str)
+		}
+		if n, ok := v.(json.Number); ok {
+			return n.Int64()
+		}
+	case avro.Int:
+		if n, ok := v.(json.Number); ok {
+			i, err := strconv.ParseInt(n.String(), 10, 32)
+			return int(i), err
+		}
+	case avro.Double:
+		if n, ok := v.(json.Number); ok {
+			return n.Float64()
+		}
+	}
+
+	return nil, fmt.Errorf("want %s, got %T", s.Type(), v)
+}
+
+// fromAvro переводит результат avro.Unmarshal обратно в JSON-значение;
+// null в необязательных полях означает отсутствие поля
+func fromAvro(schema avro.Schema, v any) (any, error) {
+	switch s := schema.(type) {
+	case *avro.RecordSchema:
+		rec, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: want record, got %T", s.FullName(), v)
+		}
+		obj := make(map[string]any, len(rec))
+		for _, field := range s.Fields() {
+			value, err := fromAvro(field.Type(), rec[field.Name()])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field.Name(), err)
+			}
+			if value != nil {
+				obj[field.Name()] = value
+			}
+		}
+		return obj, nil
+
+	case *avro.UnionSchema:
+		if v == nil {
+			return nil, nil
+		}
+		branch, err := nonNull(s)
+		if err != nil {
+			return nil, err
+		}
+		// Значения составных типов приходят обёрнутыми в {имя типа: значение}
+		if wrapped, ok := v.(map[string]any); ok && len(wrapped) == 1 {
+			if inner, ok := wrapped[unionName(branch)]; ok {
+				v = inner
+			}
+		}
+		return fromAvro(branch, v)
+
+	case *avro.ArraySchema:
+		items, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("want array, got %T", v)
+		}
+		out := make([]any, len(items))
+		for i, item := range items {
+			value, err := fromAvro(s.Items(), item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			out[i] = value
+		}
+		return out, nil
+
+	case *avro.PrimitiveSchema:
+		if ts, ok := v.(time.Time); ok {
+			return ts.UTC().Format(time.RFC3339Nano), nil
+		}
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("unsupported avro type %s", schema.Type())
+}
+
+// nonNull возвращает тип необязательного поля: схемы реестра используют
+// union только вида ["null", T]
+func nonNull(s *avro.UnionSchema) (avro.Schema, error) {
+	types := s.Types()
+	if len(types) != 2 || !s.Nullable() {
+		return nil, fmt.Errorf("unsupported union %s", s)
+	}
+	if types[0].Type() == avro.Null {
+		return types[1], nil
+	}
+	return types[0], nil
+}
+
+// unionName — имя ветки union, под которым hamba/avro ждёт значение
+func unionName(s avro.Schema) string {
+	if named, ok := s.(avro.NamedSchema); ok {
+		return named.FullName()
+	}
+	name := string(s.Type())
+	if p, ok := s.(avro.LogicalTypeSchema); ok && p.Logical() != nil {
+		name += "." + string(p.Logical().Type())
+	}
+	return name
+}
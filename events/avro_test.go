@@ -0,0 +1,347 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	"github.com/invopop/jsonschema"
+)
+
+// Файлы в каталоге avro — реестр, по которому читаются уже записанные
+// сообщения. Изменение схемы добавляет ревизию; go test -update пишет её,
+// если новая схема читает все прежние ревизии.
+func TestAvroSchemasCompatible(t *testing.T) {
+	reg, err := LoadAvroRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range Registered() {
+		t.Run(ref.FileName(), func(t *testing.T) {
+			jsonSchema, _ := Schema(ref.Type, ref.Version)
+			current := avroSchemaOf(t, ref, jsonSchema)
+
+			var revisions []AvroRevision
+			for _, rev := range reg.Revisions() {
+				if rev.SchemaRef == ref {
+					revisions = append(revisions, rev)
+				}
+			}
+			if len(revisions) == 0 {
+				if !*update {
+					t.Fatalf("%s has no avro schema; run go test -update to add it", ref.FileName())
+				}
+				writeSchema(t, filepath.Join(avroDir, AvroRevision{ref, 1}.FileName()), current)
+				return
+			}
+
+			latest := revisions[len(revisions)-1]
+			published := readAvroRevision(t, latest)
+			if bytes.Equal(published, current) {
+				return
+			}
+
+			schema := parseAvro(t, current)
+			for _, rev := range revisions {
+				old := parseAvro(t, readAvroRevision(t, rev))
+				if err := avro.NewSchemaCompatibility().Compatible(schema, old); err != nil {
+					t.Fatalf("new avro schema of %s v%d cannot read %s; add a new version instead: %v",
+						ref.Type, ref.Version, rev.FileName(), err)
+				}
+			}
+
+			next := AvroRevision{ref, latest.Revision + 1}
+			if !*update {
+				t.Fatalf("%s is out of date; run go test -update to add %s", latest.FileName(), next.FileName())
+			}
+			writeSchema(t, filepath.Join(avroDir, next.FileName()), current)
+		})
+	}
+}
+
+func TestAvroRoundTrip(t *testing.T) {
+	for _, ref := range Registered() {
+		t.Run(ref.FileName(), func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "fixtures", ref.FileName()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			env, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			encoded, err := Avro.Marshal(env)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			asJSON, _ := JSON.Marshal(env)
+			if len(encoded) >= len(asJSON) {
+				t.Errorf("avro message is %d bytes, json is %d", len(encoded), len(asJSON))
+			}
+
+			got, err := Unmarshal(ContentTypeAvro, encoded)
+			if err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if got.ID != env.ID || got.Type != env.Type || got.Version != env.Version ||
+				!got.OccurredAt.Equal(env.OccurredAt) || got.Producer != env.Producer ||
+				!reflect.DeepEqual(got.Actor, env.Actor) {
+				t.Fatalf("envelope = %+v, want %+v", got, env)
+			}
+
+			want, _ := env.Decode()
+			payload, err := got.Decode()
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !reflect.DeepEqual(payload, want) {
+				t.Fatalf("payload = %+v, want %+v", payload, want)
+			}
+		})
+	}
+}
+
+// Ревизия из каталога получателя: отправитель уже пишет событие с новым
+// необязательным полем, а встроенного реестра для него недостаточно
+func TestAvroRevisionFromDirectory(t *testing.T) {
+	jsonSchema, _ := Schema(TypeUserAction, 1)
+	var schema jsonschema.Schema
+	if err := json.Unmarshal(jsonSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := schema.Properties.Get("payload")
+	payload.Properties.Set("referrer", &jsonschema.Schema{Type: "string"})
+	changed, err := json.Marshal(&schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg, err := LoadAvroRegistry(fstest.MapFS{
+		"user.action.v1.r2.avsc": {Data: avroSchemaOf(t, SchemaRef{TypeUserAction, 1}, changed)},
+	})
+	if err != nil {
+		t.Fatalf("LoadAvroRegistry: %v", err)
+	}
+	format := NewAvro(reg)
+
+	occurredAt, err := time.Parse(time.RFC3339Nano, "2025-06-01T10:00:00.123456Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := Envelope{
+		ID:         "0d7c1b1e-8a4f-4f5e-9a43-6f0e3a2b1c55",
+		Type:       TypeUserAction,
+		Version:    1,
+		OccurredAt: occurredAt,
+		Producer:   "catalog",
+		Payload:    json.RawMessage(`{"url":"products","action":"open","referrer":"search"}`),
+	}
+	data, err := format.Marshal(env)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	if _, err := Avro.Unmarshal(data); !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("embedded registry: err = %v, want ErrUnknownType", err)
+	}
+
+	got, err := format.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !strings.Contains(string(got.Payload), `"referrer":"search"`) {
+		t.Fatalf("payload = %s", got.Payload)
+	}
+	decoded, err := got.Decode()
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if want := (UserAction{URL: "products", Action: "open"}); !reflect.DeepEqual(decoded, want) {
+		t.Fatalf("payload = %+v, want %+v", decoded, want)
+	}
+
+	_, err = LoadAvroRegistry(fstest.MapFS{
+		"user.action.v1.r1.avsc": {Data: avroSchemaOf(t, SchemaRef{TypeUserAction, 1}, changed)},
+	})
+	if err == nil {
+		t.Fatal("LoadAvroRegistry accepted a changed copy of a published revision")
+	}
+}
+
+func TestFormatsUnmarshal(t *testing.T) {
+	env, err := New("catalog", nil, UserAction{URL: "products", Action: "visibility", IDs: []string{"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	asJSON, _ := JSON.Marshal(env)
+	asAvro, err := Avro.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		contentType string
+		data        []byte
+		err         error
+	}{
+		{contentType: "", data: asJSON},
+		{contentType: "application/json; charset=utf-8", data: asJSON},
+		{contentType: ContentTypeAvro, data: asAvro},
+		{contentType: ContentTypeAvro, data: asJSON, err: ErrInvalid},
+		{contentType: ContentTypeJSON, data: asAvro, err: ErrNotEnvelope},
+		{contentType: "application/x-protobuf", data: asAvro, err: ErrUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			got, err := Unmarshal(tt.contentType, tt.data)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if got.ID != env.ID {
+				t.Fatalf("id = %q, want %q", got.ID, env.ID)
+			}
+		})
+	}
+}
+
+func readAvroRevision(t *testing.T, rev AvroRevision) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(avroDir, rev.FileName()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func parseAvro(t *testing.T, data []byte) avro.Schema {
+	t.Helper()
+
+	schema, err := avro.ParseBytesWithCache(data, "", &avro.SchemaCache{})
+	if err != nil {
+		t.Fatalf("parsing avro schema: %v", err)
+	}
+	return schema
+}
+
+// avroSchemaOf строит Avro-схему конверта по его JSON Schema. Поля с const
+// (type, version) в сообщение не пишутся; необязательные поля — union с
+// null, чтобы их можно было добавлять в новых ревизиях.
+func avroSchemaOf(t *testing.T, ref SchemaRef, jsonSchema []byte) []byte {
+	t.Helper()
+
+	var schema jsonschema.Schema
+	if err := json.Unmarshal(jsonSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	record := avroRecord(t, "Event", &schema)
+	record.Namespace = fmt.Sprintf("events.%s.v%d", strings.ReplaceAll(string(ref.Type), ".", "_"), ref.Version)
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(data, '\n')
+}
+
+type avroRecordSchema struct {
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Fields    []avroField `json:"fields"`
+}
+
+type avroField struct {
+	Name    string          `json:"name"`
+	Type    any             `json:"type"`
+	Default json.RawMessage `json:"default,omitempty"`
+}
+
+type avroLogicalSchema struct {
+	Type        string `json:"type"`
+	LogicalType string `json:"logicalType"`
+}
+
+type avroArraySchema struct {
+	Type  string `json:"type"`
+	Items any    `json:"items"`
+}
+
+func avroRecord(t *testing.T, name string, schema *jsonschema.Schema) avroRecordSchema {
+	t.Helper()
+
+	record := avroRecordSchema{Type: "record", Name: name, Fields: []avroField{}}
+	if schema.Properties == nil {
+		return record
+	}
+
+	for pair := schema.Properties.Oldest(); pair != nil; pair = pair.Next() {
+		if pair.Value.Const != nil {
+			continue
+		}
+
+		field := avroField{Name: pair.Key, Type: avroType(t, pair.Key, pair.Value)}
+		if !slices.Contains(schema.Required, pair.Key) {
+			field.Type = []any{"null", field.Type}
+			field.Default = json.RawMessage("null")
+		}
+		record.Fields = append(record.Fields, field)
+	}
+	return record
+}
+
+func avroType(t *testing.T, name string, schema *jsonschema.Schema) any {
+	t.Helper()
+
+	switch schema.Type {
+	case "string":
+		if schema.Format == "date-time" {
+			return avroLogicalSchema{Type: "long", LogicalType: "timestamp-micros"}
+		}
+		return "string"
+	case "integer":
+		return "long"
+	case "number":
+		return "double"
+	case "boolean":
+		return "boolean"
+	case "array":
+		return avroArraySchema{Type: "array", Items: avroType(t, name, schema.Items)}
+	case "object":
+		return avroRecord(t, recordName(name), schema)
+	}
+
+	t.Fatalf("%s: no avro type for json schema type %q", name, schema.Type)
+	return nil
+}
+
+// recordName переводит имя поля в имя записи: seller_info -> SellerInfo
+func recordName(field string) string {
+	parts := strings.Split(field, "_")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
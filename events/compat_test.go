@@ -0,0 +1,292 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Схемы в каталоге schemas — опубликованный контракт: по ним уже пишутся
+// и читаются события. go test -update перезаписывает их текущими, но
+// только если изменение совместимо.
+var update = flag.Bool("update", false, "rewrite schemas/ with the current schemas")
+
+const schemasDir = "schemas"
+
+func TestSchemasCompatible(t *testing.T) {
+	for _, ref := range Registered() {
+		t.Run(ref.FileName(), func(t *testing.T) {
+			current, _ := Schema(ref.Type, ref.Version)
+			path := filepath.Join(schemasDir, ref.FileName())
+
+			published, err := os.ReadFile(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				if !*update {
+					t.Fatalf("%s is not published; run go test -update to add it", path)
+				}
+				writeSchema(t, path, current)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if problems := breakingChanges(decodeSchema(t, published), decodeSchema(t, current)); len(problems) > 0 {
+				t.Fatalf("incompatible change of %s v%d; add a new version instead:\n  %s",
+					ref.Type, ref.Version, strings.Join(problems, "\n  "))
+			}
+
+			if !bytes.Equal(published, current) {
+				if !*update {
+					t.Fatalf("%s is out of date; run go test -update", path)
+				}
+				writeSchema(t, path, current)
+			}
+		})
+	}
+}
+
+// Удалить версию нельзя, пока её события могут лежать в топиках
+func TestPublishedSchemasRegistered(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(schemasDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registered := make(map[string]bool)
+	for _, ref := range Registered() {
+		registered[ref.FileName()] = true
+	}
+
+	for _, file := range files {
+		if !registered[filepath.Base(file)] {
+			t.Errorf("%s is published but no longer registered", file)
+		}
+	}
+}
+
+// testdata/fixtures — события в том виде, в каком их писали отправители.
+// Они должны разбираться текущим кодом, иначе получатели не прочитают
+// сообщения, уже лежащие в топиках.
+func TestFixturesDecode(t *testing.T) {
+	for _, ref := range Registered() {
+		t.Run(ref.FileName(), func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "fixtures", ref.FileName()))
+			if err != nil {
+				t.Fatalf("every event version needs a fixture: %v", err)
+			}
+
+			env, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if env.Type != ref.Type || env.Version != ref.Version {
+				t.Fatalf("fixture is %s v%d", env.Type, env.Version)
+			}
+			if _, err := env.Decode(); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+		})
+	}
+}
+
+// Проверка самой проверки: типичные ломающие изменения должны находиться
+func TestBreakingChangesDetected(t *testing.T) {
+	base := `{"type":"object","required":["id","price"],"properties":{
+		"id":{"type":"string"},
+		"price":{"type":"integer"},
+		"note":{"type":"string"},
+		"tags":{"type":"array","items":{"type":"string"}}}}`
+
+	tests := []struct {
+		name    string
+		changed string
+		broken  bool
+	}{
+		{
+			name: "optional field added",
+			changed: `{"type":"object","required":["id","price"],"properties":{
+				"id":{"type":"string"},"price":{"type":"integer"},"note":{"type":"string"},
+				"tags":{"type":"array","items":{"type":"string"}},"color":{"type":"string"}}}`,
+		},
+		{
+			name: "field removed",
+			changed: `{"type":"object","required":["id","price"],"properties":{
+				"id":{"type":"string"},"price":{"type":"integer"},
+				"tags":{"type":"array","items":{"type":"string"}}}}`,
+			broken: true,
+		},
+		{
+			name: "field type changed",
+			changed: `{"type":"object","required":["id","price"],"properties":{
+				"id":{"type":"string"},"price":{"type":"number"},"note":{"type":"string"},
+				"tags":{"type":"array","items":{"type":"string"}}}}`,
+			broken: true,
+		},
+		{
+			name: "new required field",
+			changed: `{"type":"object","required":["id","price","note"],"properties":{
+				"id":{"type":"string"},"price":{"type":"integer"},"note":{"type":"string"},
+				"tags":{"type":"array","items":{"type":"string"}}}}`,
+			broken: true,
+		},
+		{
+			name: "required field became optional",
+			changed: `{"type":"object","required":["id"],"properties":{
+				"id":{"type":"string"},"price":{"type":"integer"},"note":{"type":"string"},
+				"tags":{"type":"array","items":{"type":"string"}}}}`,
+			broken: true,
+		},
+		{
+			name: "array item type changed",
+			changed: `{"type":"object","required":["id","price"],"properties":{
+				"id":{"type":"string"},"price":{"type":"integer"},"note":{"type":"string"},
+				"tags":{"type":"array","items":{"type":"integer"}}}}`,
+			broken: true,
+		},
+		{
+			name: "constraint tightened",
+			changed: `{"type":"object","required":["id","price"],"properties":{
+				"id":{"type":"string","minLength":36},"price":{"type":"integer"},"note":{"type":"string"},
+				"tags":{"type":"array","items":{"type":"string"}}}}`,
+			broken: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := breakingChanges(decodeSchema(t, []byte(base)), decodeSchema(t, []byte(tt.changed)))
+			if broken := len(problems) > 0; broken != tt.broken {
+				t.Fatalf("broken = %t, want %t (%v)", broken, tt.broken, problems)
+			}
+		})
+	}
+}
+
+// breakingChanges сравнивает опубликованную схему с новой. Изменение
+// совместимо, если старые сообщения проходят новую схему, а новые — старую:
+// поля не удаляются, не меняют тип и обязательность, ограничения не
+// ужесточаются, новые поля необязательны.
+func breakingChanges(old, new map[string]any) []string {
+	var problems []string
+	compareSchemas("", old, new, &problems)
+	return problems
+}
+
+func compareSchemas(path string, old, new map[string]any, problems *[]string) {
+	report := func(format string, args ...any) {
+		where := path
+		if where == "" {
+			where = "(root)"
+		}
+		*problems = append(*problems, where+": "+fmt.Sprintf(format, args...))
+	}
+
+	for _, keyword := range []string{"type", "const", "format"} {
+		if fmt.Sprint(old[keyword]) != fmt.Sprint(new[keyword]) {
+			report("%s changed from %v to %v", keyword, old[keyword], new[keyword])
+		}
+	}
+
+	for _, keyword := range []string{"minLength", "minimum", "minItems"} {
+		if tightened(old[keyword], new[keyword], func(o, n float64) bool { return n > o }) {
+			report("%s tightened from %v to %v", keyword, old[keyword], new[keyword])
+		}
+	}
+	for _, keyword := range []string{"maxLength", "maximum", "maxItems"} {
+		if tightened(old[keyword], new[keyword], func(o, n float64) bool { return n < o }) {
+			report("%s tightened from %v to %v", keyword, old[keyword], new[keyword])
+		}
+	}
+
+	oldRequired, newRequired := stringSet(old["required"]), stringSet(new["required"])
+	for name := range oldRequired {
+		if !newRequired[name] {
+			report("field %q is no longer required", name)
+		}
+	}
+	for name := range newRequired {
+		if !oldRequired[name] {
+			report("field %q became required", name)
+		}
+	}
+
+	oldProps, _ := old["properties"].(map[string]any)
+	newProps, _ := new["properties"].(map[string]any)
+	names := make([]string, 0, len(oldProps))
+	for name := range oldProps {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		newProp, ok := newProps[name].(map[string]any)
+		if !ok {
+			report("field %q removed", name)
+			continue
+		}
+		oldProp, _ := oldProps[name].(map[string]any)
+		compareSchemas(join(path, name), oldProp, newProp, problems)
+	}
+
+	if oldItems, ok := old["items"].(map[string]any); ok {
+		newItems, _ := new["items"].(map[string]any)
+		compareSchemas(path+"[]", oldItems, newItems, problems)
+	}
+}
+
+func tightened(old, new any, stricter func(o, n float64) bool) bool {
+	n, ok := new.(float64)
+	if !ok {
+		return false
+	}
+	o, ok := old.(float64)
+	return !ok || stricter(o, n)
+}
+
+func stringSet(v any) map[string]bool {
+	list, _ := v.([]any)
+	set := make(map[string]bool, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			set[s] = true
+		}
+	}
+	return set
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func decodeSchema(t *testing.T, data []byte) map[string]any {
+	t.Helper()
+
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("decoding schema: %v", err)
+	}
+	return schema
+}
+
+func writeSchema(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,172 @@
+// Package events описывает события, которыми сервисы обмениваются через
+// Kafka: версионированный конверт (id, type, version, occurred_at,
+// producer, actor) и типизированные payload. Каждой паре type+version
+// соответствует JSON Schema (см. каталог schemas); конверт проверяется по
+// ней при создании и при разборе.
+//
+// Изменения payload внутри версии допускаются только совместимые:
+// новые необязательные поля. Всё остальное — новая версия типа. Тесты
+// сравнивают схемы с опубликованными в schemas; после совместимого
+// изменения их обновляет go test -update.
+//
+// В сообщение Kafka конверт пишется в одном из форматов (см. Format):
+// JSON или Avro со схемами из файлового реестра в каталоге avro. Формат
+// указан в заголовке content-type.
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type — тип события, например product.created
+type Type string
+
+// Payload — данные события конкретного типа и версии
+type Payload interface {
+	EventType() Type
+	EventVersion() int
+}
+
+// Actor — пользователь, действие которого породило событие
+type Actor struct {
+	UserID string `json:"user_id" jsonschema:"minLength=1"`
+}
+
+// Envelope — событие в том виде, в каком оно лежит в Kafka. Payload
+// разбирается по типу и версии: Decode или Payload.
+type Envelope struct {
+	ID         string          `json:"id"`
+	Type       Type            `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Producer   string          `json:"producer"`
+	Actor      *Actor          `json:"actor,omitempty"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Event — конверт с разобранным payload
+type Event[P Payload] struct {
+	ID         string    `json:"id" jsonschema:"format=uuid"`
+	Type       Type      `json:"type"`
+	Version    int       `json:"version"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Producer   string    `json:"producer" jsonschema:"minLength=1"`
+	Actor      *Actor    `json:"actor,omitempty"`
+	Payload    P         `json:"payload"`
+}
+
+var (
+	// ErrNotEnvelope — сообщение не похоже на конверт; такие сообщения
+	// писались до перехода на конверты, см. ParseLegacy
+	ErrNotEnvelope = errors.New("message is not an event envelope")
+	// ErrUnknownType — тип или версия события не зарегистрированы
+	ErrUnknownType = errors.New("unknown event type or version")
+	// ErrInvalid — событие не соответствует схеме
+	ErrInvalid = errors.New("event does not match schema")
+)
+
+// New создаёт конверт события от имени producer; actor может быть nil.
+// Событие проверяется по схеме, поэтому ошибка означает ошибку в коде
+// отправителя.
+func New(producer string, actor *Actor, payload Payload) (Envelope, error) {
+	return newEnvelope(uuid.NewString(), time.Now().UTC(), producer, actor, payload)
+}
+
+func newEnvelope(id string, occurredAt time.Time, producer string, actor *Actor, payload Payload) (Envelope, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("marshaling %s payload: %w", payload.EventType(), err)
+	}
+
+	env := Envelope{
+		ID:         id,
+		Type:       payload.EventType(),
+		Version:    payload.EventVersion(),
+		OccurredAt: occurredAt,
+		Producer:   producer,
+		Actor:      actor,
+		Payload:    raw,
+	}
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("marshaling %s event: %w", env.Type, err)
+	}
+	if err := validate(data); err != nil {
+		return Envelope{}, err
+	}
+
+	return env, nil
+}
+
+// Parse разбирает и проверяет по схеме конверт из сообщения Kafka
+func Parse(data []byte) (Envelope, error) {
+	if err := validate(data); err != nil {
+		return Envelope{}, err
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return Envelope{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	return env, nil
+}
+
+// Decode разбирает payload конверта в тип P. Тип и версия события должны
+// совпадать с P.
+func Decode[P Payload](env Envelope) (Event[P], error) {
+	var payload P
+	if env.Type != payload.EventType() || env.Version != payload.EventVersion() {
+		return Event[P]{}, fmt.Errorf("%w: got %s v%d, want %s v%d", ErrUnknownType,
+			env.Type, env.Version, payload.EventType(), payload.EventVersion())
+	}
+
+	if err := json.Unmarshal(env.Payload, &payload); err != nil {
+		return Event[P]{}, fmt.Errorf("%w: %s payload: %v", ErrInvalid, env.Type, err)
+	}
+
+	return Event[P]{
+		ID:         env.ID,
+		Type:       env.Type,
+		Version:    env.Version,
+		OccurredAt: env.OccurredAt,
+		Producer:   env.Producer,
+		Actor:      env.Actor,
+		Payload:    payload,
+	}, nil
+}
+
+// Decode разбирает payload в зарегистрированный для типа и версии тип;
+// удобно для type switch по нескольким типам событий
+func (env Envelope) Decode() (Payload, error) {
+	entry, ok := registry[key{env.Type, env.Version}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnknownType, env.Type, env.Version)
+	}
+
+	payload, err := entry.decode(env.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s payload: %v", ErrInvalid, env.Type, err)
+	}
+	return payload, nil
+}
+
+// isEnvelope отличает конверт от старых плоских событий по полям type и
+// payload
+func isEnvelope(data []byte) bool {
+	var probe struct {
+		Type    *string         `json:"type"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	return probe.Type != nil && len(bytes.TrimSpace(probe.Payload)) > 0
+}
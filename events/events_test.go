@@ -0,0 +1,188 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewParseDecode(t *testing.T) {
+	payload := ProductCreated{Product{
+		ProductID:  "7b0c6f4e-3c56-4c55-8f0b-5d7f2b9e0a11",
+		Title:      "Кофемолка",
+		Price:      2500,
+		SellerName: "alice",
+		Version:    1,
+	}}
+
+	env, err := New("catalog", &Actor{UserID: "u-1"}, payload)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if parsed.ID != env.ID || parsed.Type != TypeProductCreated || parsed.Version != 1 || parsed.Actor.UserID != "u-1" {
+		t.Fatalf("parsed envelope = %+v", parsed)
+	}
+
+	event, err := Decode[ProductCreated](parsed)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if event.Payload != payload {
+		t.Fatalf("payload = %+v, want %+v", event.Payload, payload)
+	}
+
+	if _, err := Decode[ProductDeleted](parsed); !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("Decode to another type: err = %v, want ErrUnknownType", err)
+	}
+
+	decoded, err := parsed.Decode()
+	if err != nil {
+		t.Fatalf("Envelope.Decode: %v", err)
+	}
+	if _, ok := decoded.(ProductCreated); !ok {
+		t.Fatalf("Envelope.Decode returned %T", decoded)
+	}
+}
+
+func TestNewValidatesPayload(t *testing.T) {
+	_, err := New("catalog", nil, ProductDeleted{Version: 2})
+	if !errors.Is(err, ErrInvalid) {
+		t.Fatalf("err = %v, want ErrInvalid", err)
+	}
+
+	_, err = New("", nil, UserAction{URL: "products", Action: "visibility"})
+	if !errors.Is(err, ErrInvalid) {
+		t.Fatalf("empty producer: err = %v, want ErrInvalid", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  error
+	}{
+		{
+			name: "valid",
+			data: `{"id":"0d7c1b1e-8a4f-4f5e-9a43-6f0e3a2b1c55","type":"user.action","version":1,
+				"occurred_at":"2025-06-01T10:00:00Z","producer":"catalog",
+				"payload":{"url":"products","action":"visibility","ids":["1","2"]}}`,
+		},
+		{
+			name: "unknown fields are allowed",
+			data: `{"id":"0d7c1b1e-8a4f-4f5e-9a43-6f0e3a2b1c55","type":"user.action","version":1,
+				"occurred_at":"2025-06-01T10:00:00Z","producer":"catalog","trace":"x",
+				"payload":{"url":"products","action":"open","referrer":"search"}}`,
+		},
+		{
+			name: "legacy flat event",
+			data: `{"url":"products","action":"visibility","timestamp":"2025-06-01T10:00:00Z"}`,
+			err:  ErrNotEnvelope,
+		},
+		{
+			name: "not json",
+			data: `oops`,
+			err:  ErrNotEnvelope,
+		},
+		{
+			name: "unknown version",
+			data: `{"id":"0d7c1b1e-8a4f-4f5e-9a43-6f0e3a2b1c55","type":"user.action","version":7,
+				"occurred_at":"2025-06-01T10:00:00Z","producer":"catalog","payload":{"url":"products","action":"open"}}`,
+			err: ErrUnknownType,
+		},
+		{
+			name: "missing required payload field",
+			data: `{"id":"0d7c1b1e-8a4f-4f5e-9a43-6f0e3a2b1c55","type":"product.deleted","version":1,
+				"occurred_at":"2025-06-01T10:00:00Z","producer":"catalog","payload":{"product_id":"p-1"}}`,
+			err: ErrInvalid,
+		},
+		{
+			name: "bad timestamp",
+			data: `{"id":"0d7c1b1e-8a4f-4f5e-9a43-6f0e3a2b1c55","type":"user.action","version":1,
+				"occurred_at":"yesterday","producer":"catalog","payload":{"url":"products","action":"open"}}`,
+			err: ErrInvalid,
+		},
+		{
+			name: "wrong field type",
+			data: `{"id":"0d7c1b1e-8a4f-4f5e-9a43-6f0e3a2b1c55","type":"product.deleted","version":1,
+				"occurred_at":"2025-06-01T10:00:00Z","producer":"catalog","payload":{"product_id":"p-1","version":"2"}}`,
+			err: ErrInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			if tt.err == nil && err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseLegacy(t *testing.T) {
+	tests := []struct {
+		data string
+		want Payload
+	}{
+		{
+			data: `{"url":"products","ids":["1","2"],"action":"visibility","timestamp":"2025-06-01T10:00:00.123456+03:00"}`,
+			want: UserAction{URL: "products", Action: "visibility", IDs: []string{"1", "2"}},
+		},
+		{
+			data: `{"action":"product_updated","product_id":"p-1","title":"Чайник","price":990,"seller_name":"bob","category_name":"Кухня","version":3,"timestamp":"2025-06-01T10:00:00Z"}`,
+			want: ProductUpdated{Product{ProductID: "p-1", Title: "Чайник", Price: 990, SellerName: "bob", CategoryName: "Кухня", Version: 3}},
+		},
+		{
+			data: `{"action":"product_deleted","product_id":"p-1","version":4,"timestamp":"2025-06-01T10:00:00Z"}`,
+			want: ProductDeleted{ProductID: "p-1", Version: 4},
+		},
+		{
+			data: `{"action":"comment_deleted","comment_id":"c-1","product_id":"p-1","user_id":"u-1","timestamp":"2025-06-01T10:00:00Z"}`,
+			want: CommentDeleted{CommentID: "c-1", ProductID: "p-1", UserID: "u-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.want.EventType()), func(t *testing.T) {
+			env, err := ParseLegacy([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("ParseLegacy: %v", err)
+			}
+
+			got, err := env.Decode()
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("payload = %+v, want %+v", got, tt.want)
+			}
+
+			again, err := ParseLegacy([]byte(tt.data))
+			if err != nil || again.ID != env.ID {
+				t.Fatalf("legacy id is not stable: %q then %q (%v)", env.ID, again.ID, err)
+			}
+		})
+	}
+
+	if _, err := ParseLegacy([]byte(`{"action":"product_archived","timestamp":"2025-06-01T10:00:00Z"}`)); !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("unknown legacy action: err = %v, want ErrUnknownType", err)
+	}
+	if _, err := ParseLegacy([]byte(`{"url":"products","action":"open","timestamp":"now"}`)); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("bad legacy timestamp: err = %v, want ErrInvalid", err)
+	}
+}
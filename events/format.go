@@ -0,0 +1,97 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ContentTypeHeader — заголовок сообщения Kafka с форматом события.
+// Сообщения без заголовка записаны в JSON.
+const ContentTypeHeader = "content-type"
+
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeAvro = "application/avro"
+)
+
+// ErrUnknownFormat — формат сообщения не поддерживается
+var ErrUnknownFormat = errors.New("unknown event format")
+
+// Format — формат события в сообщении Kafka
+type Format interface {
+	// ContentType — значение заголовка ContentTypeHeader
+	ContentType() string
+	Marshal(env Envelope) ([]byte, error)
+	// Unmarshal разбирает и проверяет по схеме конверт из сообщения
+	Unmarshal(data []byte) (Envelope, error)
+}
+
+// JSON — конверт в JSON, формат по умолчанию
+var JSON Format = jsonFormat{}
+
+type jsonFormat struct{}
+
+func (jsonFormat) ContentType() string { return ContentTypeJSON }
+
+func (jsonFormat) Marshal(env Envelope) ([]byte, error) {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling %s event: %w", env.Type, err)
+	}
+	return data, nil
+}
+
+func (jsonFormat) Unmarshal(data []byte) (Envelope, error) {
+	return Parse(data)
+}
+
+// FormatByName возвращает формат по имени из конфигурации: json или avro.
+// Avro пишет по последним ревизиям встроенных схем.
+func FormatByName(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", "json":
+		return JSON, nil
+	case "avro":
+		return Avro, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+	}
+}
+
+// Formats — форматы, которые умеет читать получатель, по content-type
+type Formats map[string]Format
+
+// NewFormats собирает форматы для чтения; JSON есть всегда
+func NewFormats(formats ...Format) Formats {
+	fs := Formats{ContentTypeJSON: JSON}
+	for _, f := range formats {
+		fs[f.ContentType()] = f
+	}
+	return fs
+}
+
+// DefaultFormats читает JSON и Avro со встроенными схемами
+var DefaultFormats = NewFormats(JSON, Avro)
+
+// Unmarshal разбирает сообщение в формате из заголовка contentType;
+// пустой contentType означает JSON
+func (fs Formats) Unmarshal(contentType string, data []byte) (Envelope, error) {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" {
+		mediaType = ContentTypeJSON
+	}
+
+	f, ok := fs[mediaType]
+	if !ok {
+		return Envelope{}, fmt.Errorf("%w: %q", ErrUnknownFormat, contentType)
+	}
+	return f.Unmarshal(data)
+}
+
+// Unmarshal разбирает сообщение форматами DefaultFormats
+func Unmarshal(contentType string, data []byte) (Envelope, error) {
+	return DefaultFormats.Unmarshal(contentType, data)
+}
@@ -0,0 +1,84 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// legacyProducer — до конвертов все события писал каталог
+const legacyProducer = "catalog"
+
+// legacyNamespace — пространство имён UUID для id старых событий: id
+// выводится из тела сообщения и не меняется при повторном чтении
+var legacyNamespace = uuid.MustParse("5d1c3f1e-7a43-4c1b-9a55-2f0c8d0e6b41")
+
+// legacyEvent — объединение полей плоских событий, которые каталог писал
+// до перехода на конверты
+type legacyEvent struct {
+	Action       string   `json:"action"`
+	URL          string   `json:"url"`
+	Ids          []string `json:"ids"`
+	ProductID    string   `json:"product_id"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	Price        int      `json:"price"`
+	SellerName   string   `json:"seller_name"`
+	CategoryName string   `json:"category_name"`
+	Version      int      `json:"version"`
+	CommentID    string   `json:"comment_id"`
+	UserID       string   `json:"user_id"`
+	Comment      string   `json:"comment"`
+	Timestamp    string   `json:"timestamp"`
+}
+
+// ParseLegacy переводит плоское событие старого формата в конверт версии
+// 1. Нужен, пока в топиках остаются сообщения, записанные до перехода на
+// конверты.
+func ParseLegacy(data []byte) (Envelope, error) {
+	var e legacyEvent
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Envelope{}, fmt.Errorf("%w: legacy event: %v", ErrInvalid, err)
+	}
+
+	occurredAt, err := time.Parse(time.RFC3339, e.Timestamp)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("%w: legacy event timestamp: %v", ErrInvalid, err)
+	}
+
+	var payload Payload
+	switch e.Action {
+	case "product_created", "product_updated":
+		product := Product{
+			ProductID:    e.ProductID,
+			Title:        e.Title,
+			Description:  e.Description,
+			Price:        e.Price,
+			SellerName:   e.SellerName,
+			CategoryName: e.CategoryName,
+			Version:      e.Version,
+		}
+		if e.Action == "product_created" {
+			payload = ProductCreated{product}
+		} else {
+			payload = ProductUpdated{product}
+		}
+	case "product_deleted":
+		payload = ProductDeleted{ProductID: e.ProductID, Version: e.Version}
+	case "comment_created":
+		payload = CommentCreated{CommentID: e.CommentID, ProductID: e.ProductID, UserID: e.UserID, Comment: e.Comment}
+	case "comment_deleted":
+		payload = CommentDeleted{CommentID: e.CommentID, ProductID: e.ProductID, UserID: e.UserID}
+	default:
+		if strings.Contains(e.Action, "_") {
+			return Envelope{}, fmt.Errorf("%w: legacy action %q", ErrUnknownType, e.Action)
+		}
+		payload = UserAction{URL: e.URL, Action: e.Action, IDs: e.Ids}
+	}
+
+	id := uuid.NewSHA1(legacyNamespace, data).String()
+	return newEnvelope(id, occurredAt, legacyProducer, nil, payload)
+}
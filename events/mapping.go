@@ -0,0 +1,46 @@
+package events
+
+// ProductsIndexMapping — маппинг индекса products в Elasticsearch. Документ
+// индекса — поля Product без version; title и category_name дополнительно
+// индексируются как completion для подсказок поиска. Индекс создают indexer
+// и переиндексация каталога, поэтому маппинг общий.
+const ProductsIndexMapping = `{
+  "settings": {
+    "analysis": {
+      "analyzer": {
+        "russian_analyzer": {
+          "type": "russian"
+        }
+      }
+    }
+  },
+  "mappings": {
+    "dynamic": "strict",
+    "properties": {
+      "product_id": {"type": "keyword"},
+      "title": {
+        "type": "text",
+        "analyzer": "russian_analyzer",
+        "fields": {
+          "keyword": {"type": "keyword", "ignore_above": 256},
+          "suggest": {"type": "completion"}
+        }
+      },
+      "description": {"type": "text", "analyzer": "russian_analyzer"},
+      "price": {"type": "integer"},
+      "category_name": {
+        "type": "keyword",
+        "fields": {
+          "suggest": {"type": "completion"}
+        }
+      },
+      "seller_name": {
+        "type": "text",
+        "analyzer": "russian_analyzer",
+        "fields": {
+          "keyword": {"type": "keyword", "ignore_above": 256}
+        }
+      }
+    }
+  }
+}`
@@ -0,0 +1,104 @@
+package events
+
+// Типы событий. Топик, в который пишется событие, указан в комментарии.
+const (
+	// TypeUserAction — действие пользователя на сайте (user-events)
+	TypeUserAction Type = "user.action"
+
+	// События товаров (product-events), ключ сообщения — product_id
+	TypeProductCreated Type = "product.created"
+	TypeProductUpdated Type = "product.updated"
+	TypeProductDeleted Type = "product.deleted"
+
+	// События комментариев (comment-events), ключ сообщения — comment_id
+	TypeCommentCreated Type = "comment.created"
+	TypeCommentUpdated Type = "comment.updated"
+	TypeCommentDeleted Type = "comment.deleted"
+)
+
+func init() {
+	register[UserAction]()
+	register[ProductCreated]()
+	register[ProductUpdated]()
+	register[ProductDeleted]()
+	register[CommentCreated]()
+	register[CommentUpdated]()
+	register[CommentDeleted]()
+}
+
+// UserAction — пользователь открыл страницу или выполнил действие; ids —
+// товары, которые он при этом увидел
+type UserAction struct {
+	URL    string   `json:"url"`
+	Action string   `json:"action" jsonschema:"minLength=1"`
+	IDs    []string `json:"ids,omitempty"`
+}
+
+func (UserAction) EventType() Type   { return TypeUserAction }
+func (UserAction) EventVersion() int { return 1 }
+
+// Product — состояние товара после изменения. Version — версия строки
+// товара: получатели применяют события не старше уже применённых.
+type Product struct {
+	ProductID    string `json:"product_id" jsonschema:"minLength=1"`
+	Title        string `json:"title"`
+	Description  string `json:"description,omitempty"`
+	Price        int    `json:"price"`
+	SellerName   string `json:"seller_name"`
+	CategoryName string `json:"category_name,omitempty"`
+	Version      int    `json:"version" jsonschema:"minimum=1"`
+}
+
+type ProductCreated struct {
+	Product
+}
+
+func (ProductCreated) EventType() Type   { return TypeProductCreated }
+func (ProductCreated) EventVersion() int { return 1 }
+
+type ProductUpdated struct {
+	Product
+}
+
+func (ProductUpdated) EventType() Type   { return TypeProductUpdated }
+func (ProductUpdated) EventVersion() int { return 1 }
+
+// ProductDeleted — товар удалён; Version — следующая после последнего
+// изменения версия
+type ProductDeleted struct {
+	ProductID string `json:"product_id" jsonschema:"minLength=1"`
+	Version   int    `json:"version" jsonschema:"minimum=1"`
+}
+
+func (ProductDeleted) EventType() Type   { return TypeProductDeleted }
+func (ProductDeleted) EventVersion() int { return 1 }
+
+type CommentCreated struct {
+	CommentID string `json:"comment_id" jsonschema:"minLength=1"`
+	ProductID string `json:"product_id" jsonschema:"minLength=1"`
+	UserID    string `json:"user_id" jsonschema:"minLength=1"`
+	Comment   string `json:"comment"`
+}
+
+func (CommentCreated) EventType() Type   { return TypeCommentCreated }
+func (CommentCreated) EventVersion() int { return 1 }
+
+// CommentUpdated — автор изменил текст комментария; Comment — новый текст
+type CommentUpdated struct {
+	CommentID string `json:"comment_id" jsonschema:"minLength=1"`
+	ProductID string `json:"product_id" jsonschema:"minLength=1"`
+	UserID    string `json:"user_id" jsonschema:"minLength=1"`
+	Comment   string `json:"comment"`
+}
+
+func (CommentUpdated) EventType() Type   { return TypeCommentUpdated }
+func (CommentUpdated) EventVersion() int { return 1 }
+
+type CommentDeleted struct {
+	CommentID string `json:"comment_id" jsonschema:"minLength=1"`
+	ProductID string `json:"product_id" jsonschema:"minLength=1"`
+	UserID    string `json:"user_id" jsonschema:"minLength=1"`
+}
+
+func (CommentDeleted) EventType() Type   { return TypeCommentDeleted }
+func (CommentDeleted) EventVersion() int { return 1 }
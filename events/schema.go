@@ -0,0 +1,149 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	validator "github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+type key struct {
+	typ     Type
+	version int
+}
+
+// SchemaRef — зарегистрированные тип и версия события
+type SchemaRef struct {
+	Type    Type
+	Version int
+}
+
+// FileName — имя файла схемы в каталоге schemas
+func (r SchemaRef) FileName() string {
+	return fmt.Sprintf("%s.v%d.json", r.Type, r.Version)
+}
+
+type entry struct {
+	schema    []byte
+	validator *validator.Schema
+	decode    func(data []byte) (Payload, error)
+}
+
+var registry = map[key]*entry{}
+
+// register добавляет тип события: строит схему конверта с payload P и
+// компилирует её для проверки. Ошибка здесь — ошибка в описании типа,
+// поэтому panic при инициализации пакета.
+func register[P Payload]() {
+	var zero P
+	k := key{zero.EventType(), zero.EventVersion()}
+	if _, ok := registry[k]; ok {
+		panic(fmt.Sprintf("events: %s v%d registered twice", k.typ, k.version))
+	}
+
+	reflector := jsonschema.Reflector{
+		// Неизвестные поля разрешены: получатель должен принимать события
+		// с новыми необязательными полями
+		AllowAdditionalProperties: true,
+		DoNotReference:            true,
+		ExpandedStruct:            true,
+	}
+	schema := reflector.Reflect(Event[P]{})
+	schema.Title = fmt.Sprintf("%s v%d", k.typ, k.version)
+
+	typeProp, _ := schema.Properties.Get("type")
+	typeProp.Const = string(k.typ)
+	versionProp, _ := schema.Properties.Get("version")
+	versionProp.Const = k.version
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		panic(fmt.Sprintf("events: marshaling %s v%d schema: %v", k.typ, k.version, err))
+	}
+
+	doc, err := validator.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		panic(fmt.Sprintf("events: parsing %s v%d schema: %v", k.typ, k.version, err))
+	}
+
+	url := "mem://events/" + SchemaRef{k.typ, k.version}.FileName()
+	compiler := validator.NewCompiler()
+	compiler.AssertFormat()
+	if err := compiler.AddResource(url, doc); err != nil {
+		panic(fmt.Sprintf("events: adding %s v%d schema: %v", k.typ, k.version, err))
+	}
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		panic(fmt.Sprintf("events: compiling %s v%d schema: %v", k.typ, k.version, err))
+	}
+
+	registry[k] = &entry{
+		schema:    append(data, '\n'),
+		validator: compiled,
+		decode: func(data []byte) (Payload, error) {
+			var payload P
+			if err := json.Unmarshal(data, &payload); err != nil {
+				return nil, err
+			}
+			return payload, nil
+		},
+	}
+}
+
+// Registered перечисляет зарегистрированные типы и версии событий
+func Registered() []SchemaRef {
+	refs := make([]SchemaRef, 0, len(registry))
+	for k := range registry {
+		refs = append(refs, SchemaRef{Type: k.typ, Version: k.version})
+	}
+	slices.SortFunc(refs, func(a, b SchemaRef) int {
+		if c := strings.Compare(string(a.Type), string(b.Type)); c != 0 {
+			return c
+		}
+		return a.Version - b.Version
+	})
+	return refs
+}
+
+// Schema возвращает JSON Schema конверта события типа t версии version
+func Schema(t Type, version int) ([]byte, bool) {
+	entry, ok := registry[key{t, version}]
+	if !ok {
+		return nil, false
+	}
+	return slices.Clone(entry.schema), true
+}
+
+// validate проверяет конверт по схеме его типа и версии
+func validate(data []byte) error {
+	if !isEnvelope(data) {
+		return ErrNotEnvelope
+	}
+
+	var head struct {
+		Type    Type `json:"type"`
+		Version int  `json:"version"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	entry, ok := registry[key{head.Type, head.Version}]
+	if !ok {
+		return fmt.Errorf("%w: %s v%d", ErrUnknownType, head.Type, head.Version)
+	}
+
+	doc, err := validator.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := entry.validator.Validate(doc); err != nil {
+		return fmt.Errorf("%w: %s v%d: %v", ErrInvalid, head.Type, head.Version, err)
+	}
+
+	return nil
+}
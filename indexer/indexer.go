@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"events"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v7"
+)
+
+// productDocument — документ индекса products, его читает поиск каталога
+type productDocument struct {
+	ProductID    string `json:"product_id"`
+	Title        string `json:"title"`
+	Description  string `json:"description,omitempty"`
+	Price        int    `json:"price"`
+	SellerName   string `json:"seller_name"`
+	CategoryName string `json:"category_name,omitempty"`
+}
+
+// ErrSkipEvent — событие не относится к индексу или повреждено
+var ErrSkipEvent = errors.New("event skipped")
+
+// operation — одна операция bulk-запроса
+type operation struct {
+	action   string // index | delete
+	id       string
+	version  int
+	document *productDocument
+}
+
+// formats — форматы событий, которые понимает индексатор; main добавляет
+// ревизии Avro-схем из AVRO_SCHEMA_DIR
+var formats = events.DefaultFormats
+
+// decodeEvent разбирает событие из product-events в формате contentType.
+// Сообщения, записанные до перехода на конверты, переводятся в конверт,
+// пока остаются в топике.
+func decodeEvent(contentType string, data []byte) (events.Payload, error) {
+	env, err := formats.Unmarshal(contentType, data)
+	if errors.Is(err, events.ErrNotEnvelope) {
+		env, err = events.ParseLegacy(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return env.Decode()
+}
+
+// toOperation переводит событие в операцию над индексом
+func toOperation(payload events.Payload) (operation, error) {
+	switch e := payload.(type) {
+	case events.ProductCreated:
+		return indexOperation(e.Product)
+	case events.ProductUpdated:
+		return indexOperation(e.Product)
+	case events.ProductDeleted:
+		if e.ProductID == "" {
+			return operation{}, fmt.Errorf("%w: product_id is empty", ErrSkipEvent)
+		}
+		return operation{
+			action:  "delete",
+			id:      e.ProductID,
+			version: e.Version,
+		}, nil
+	default:
+		return operation{}, fmt.Errorf("%w: unexpected event %s", ErrSkipEvent, payload.EventType())
+	}
+}
+
+func indexOperation(p events.Product) (operation, error) {
+	if p.ProductID == "" {
+		return operation{}, fmt.Errorf("%w: product_id is empty", ErrSkipEvent)
+	}
+
+	return operation{
+		action:  "index",
+		id:      p.ProductID,
+		version: p.Version,
+		document: &productDocument{
+			ProductID:    p.ProductID,
+			Title:        p.Title,
+			Description:  p.Description,
+			Price:        p.Price,
+			SellerName:   p.SellerName,
+			CategoryName: p.CategoryName,
+		},
+	}, nil
+}
+
+type Indexer struct {
+	es    *elasticsearch.Client
+	index string
+	log   *slog.Logger
+}
+
+func NewIndexer(log *slog.Logger, es *elasticsearch.Client, index string) *Indexer {
+	return &Indexer{es: es, index: index, log: log}
+}
+
+// EnsureIndex создаёт индекс с маппингом, если ни индекса, ни алиаса
+// с таким именем ещё нет
+func (ix *Indexer) EnsureIndex(ctx context.Context) error {
+	res, err := ix.es.Indices.Exists([]string{ix.index}, ix.es.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("checking index: %w", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+	if res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("checking index: unexpected status %d", res.StatusCode)
+	}
+
+	res, err = ix.es.Indices.Create(ix.index,
+		ix.es.Indices.Create.WithContext(ctx),
+		ix.es.Indices.Create.WithBody(strings.NewReader(events.ProductsIndexMapping)),
+	)
+	if err != nil {
+		return fmt.Errorf("creating index: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		// Индекс мог создать параллельно запущенный экземпляр
+		if bytes.Contains(body, []byte("resource_already_exists_exception")) {
+			return nil
+		}
+		return fmt.Errorf("creating index: status %d: %s", res.StatusCode, body)
+	}
+
+	ix.log.Info("index created", slog.String("index", ix.index))
+
+	return nil
+}
+
+// bulkBody собирает NDJSON для _bulk. Версия из Postgres передаётся как
+// external version, поэтому устаревшие и повторные события отклоняются
+// самим Elasticsearch.
+func bulkBody(index string, ops []operation) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	for _, op := range ops {
+		meta := map[string]any{
+			"_index": index,
+			"_id":    op.id,
+		}
+		if op.version > 0 {
+			meta["version"] = op.version
+			meta["version_type"] = "external"
+		}
+
+		if err := enc.Encode(map[string]any{op.action: meta}); err != nil {
+			return nil, err
+		}
+
+		if op.document != nil {
+			if err := enc.Encode(op.document); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+type bulkResponse struct {
+	Errors bool                          `json:"errors"`
+	Items  []map[string]bulkResponseItem `json:"items"`
+}
+
+type bulkResponseItem struct {
+	ID     string `json:"_id"`
+	Status int    `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error,omitempty"`
+}
+
+// ErrRetryable — часть операций не применилась по временной причине,
+// пачку нужно повторить
+var ErrRetryable = errors.New("bulk request must be retried")
+
+// Apply отправляет операции одним bulk-запросом. Конфликт версий и удаление
+// отсутствующего документа считаются успехом, ошибки в данных логируются и
+// пропускаются, временные ошибки возвращаются как ErrRetryable.
+func (ix *Indexer) Apply(ctx context.Context, ops []operation) error {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	body, err := bulkBody(ix.index, ops)
+	if err != nil {
+		return fmt.Errorf("encoding bulk body: %w", err)
+	}
+
+	res, err := ix.es.Bulk(bytes.NewReader(body), ix.es.Bulk.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRetryable, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		msg, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("%w: bulk status %d: %s", ErrRetryable, res.StatusCode, msg)
+	}
+
+	var br bulkResponse
+	if err := json.NewDecoder(res.Body).Decode(&br); err != nil {
+		return fmt.Errorf("%w: decoding bulk response: %v", ErrRetryable, err)
+	}
+
+	if !br.Errors {
+		return nil
+	}
+
+	retry := 0
+	for _, item := range br.Items {
+		for action, r := range item {
+			switch {
+			case r.Status < 300:
+			case r.Status == http.StatusConflict:
+				ix.log.Debug("stale event ignored", slog.String("id", r.ID), slog.String("action", action))
+			case action == "delete" && r.Status == http.StatusNotFound:
+			case r.Status == http.StatusTooManyRequests || r.Status >= 500:
+				retry++
+			default:
+				reason := ""
+				if r.Error != nil {
+					reason = r.Error.Type + ": " + r.Error.Reason
+				}
+				ix.log.Error("operation rejected",
+					slog.String("id", r.ID),
+					slog.String("action", action),
+					slog.Int("status", r.Status),
+					slog.String("reason", reason),
+				)
+			}
+		}
+	}
+
+	if retry > 0 {
+		return fmt.Errorf("%w: %d of %d operations failed", ErrRetryable, retry, len(ops))
+	}
+
+	return nil
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"events"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeES — минимальный HTTP-сервер, отвечающий как Elasticsearch
+type fakeES struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	requests []recordedRequest
+
+	indexExists bool
+	bulkStatus  int
+	bulkItems   func(ops []map[string]map[string]any) []map[string]any
+}
+
+type recordedRequest struct {
+	method string
+	path   string
+	body   []byte
+}
+
+func (f *fakeES) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, recordedRequest{method: r.Method, path: r.URL.Path, body: body})
+	f.mu.Unlock()
+
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/":
+		// клиент проверяет, что на том конце действительно Elasticsearch
+		io.WriteString(w, `{"version":{"number":"7.17.14","build_flavor":"default"},"tagline":"You Know, for Search"}`)
+	case r.Method == http.MethodHead:
+		if f.indexExists {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.Method == http.MethodPut:
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, `{"acknowledged":true}`)
+	case strings.HasSuffix(r.URL.Path, "/_bulk"):
+		if f.bulkStatus != 0 && f.bulkStatus != http.StatusOK {
+			w.WriteHeader(f.bulkStatus)
+			io.WriteString(w, `{"error":"unavailable"}`)
+			return
+		}
+
+		ops := parseBulkActions(f.t, body)
+		var items []map[string]any
+		if f.bulkItems != nil {
+			items = f.bulkItems(ops)
+		} else {
+			for _, op := range ops {
+				for action := range op {
+					items = append(items, map[string]any{action: map[string]any{"status": 200}})
+				}
+			}
+		}
+
+		hasErrors := false
+		for _, item := range items {
+			for _, v := range item {
+				if v.(map[string]any)["status"].(int) >= 300 {
+					hasErrors = true
+				}
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"errors": hasErrors, "items": items})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeES) bulkRequests() []recordedRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var res []recordedRequest
+	for _, r := range f.requests {
+		if strings.HasSuffix(r.path, "/_bulk") {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// parseBulkActions возвращает строки-действия NDJSON, пропуская документы
+func parseBulkActions(t *testing.T, body []byte) []map[string]map[string]any {
+	t.Helper()
+
+	var actions []map[string]map[string]any
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for sc.Scan() {
+		var line map[string]json.RawMessage
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", sc.Text(), err)
+		}
+
+		raw, ok := line["index"]
+		action := "index"
+		if !ok {
+			raw, ok = line["delete"]
+			action = "delete"
+		}
+		if !ok {
+			// строка с документом
+			continue
+		}
+
+		var meta map[string]any
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			t.Fatalf("invalid action meta: %v", err)
+		}
+		actions = append(actions, map[string]map[string]any{action: meta})
+	}
+	return actions
+}
+
+func newTestIndexer(t *testing.T, f *fakeES) *Indexer {
+	t.Helper()
+
+	f.t = t
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	return NewIndexer(slog.New(slog.NewTextHandler(io.Discard, nil)), es, "products")
+}
+
+func TestToOperation(t *testing.T) {
+	tests := []struct {
+		name        string
+		event       events.Payload
+		wantAction  string
+		wantID      string
+		wantVersion int
+		wantTitle   string
+		wantSkip    bool
+	}{
+		{
+			name:        "created",
+			event:       events.ProductCreated{Product: events.Product{ProductID: "p1", Title: "Phone", Price: 100, Version: 1}},
+			wantAction:  "index",
+			wantID:      "p1",
+			wantVersion: 1,
+			wantTitle:   "Phone",
+		},
+		{
+			name:        "updated",
+			event:       events.ProductUpdated{Product: events.Product{ProductID: "p1", Title: "Phone 2", Version: 2}},
+			wantAction:  "index",
+			wantID:      "p1",
+			wantVersion: 2,
+			wantTitle:   "Phone 2",
+		},
+		{
+			name:        "deleted",
+			event:       events.ProductDeleted{ProductID: "p1", Version: 3},
+			wantAction:  "delete",
+			wantID:      "p1",
+			wantVersion: 3,
+		},
+		{
+			name:     "other event type",
+			event:    events.UserAction{URL: "product", Action: "create"},
+			wantSkip: true,
+		},
+		{
+			name:     "missing product id",
+			event:    events.ProductCreated{},
+			wantSkip: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := toOperation(tt.event)
+			if tt.wantSkip {
+				if !errors.Is(err, ErrSkipEvent) {
+					t.Fatalf("expected ErrSkipEvent, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if op.action != tt.wantAction {
+				t.Errorf("action = %q, want %q", op.action, tt.wantAction)
+			}
+			if wantDoc := tt.wantAction == "index"; (op.document != nil) != wantDoc {
+				t.Errorf("document present = %v, want %v", op.document != nil, wantDoc)
+			}
+			if op.id != tt.wantID || op.version != tt.wantVersion {
+				t.Errorf("id/version = %q/%d, want %q/%d", op.id, op.version, tt.wantID, tt.wantVersion)
+			}
+			if op.document != nil && op.document.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", op.document.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestEnsureIndex(t *testing.T) {
+	t.Run("creates missing index with mapping", func(t *testing.T) {
+		f := &fakeES{}
+		ix := newTestIndexer(t, f)
+
+		if err := ix.EnsureIndex(context.Background()); err != nil {
+			t.Fatalf("EnsureIndex: %v", err)
+		}
+
+		var put *recordedRequest
+		for i := range f.requests {
+			if f.requests[i].method == http.MethodPut {
+				put = &f.requests[i]
+			}
+		}
+		if put == nil {
+			t.Fatal("expected index creation request")
+		}
+		if put.path != "/products" {
+			t.Errorf("path = %q, want /products", put.path)
+		}
+
+		var mapping struct {
+			Mappings struct {
+				Dynamic    string                     `json:"dynamic"`
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"mappings"`
+		}
+		if err := json.Unmarshal(put.body, &mapping); err != nil {
+			t.Fatalf("mapping is not valid JSON: %v", err)
+		}
+		if mapping.Mappings.Dynamic != "strict" {
+			t.Errorf("dynamic = %q, want strict", mapping.Mappings.Dynamic)
+		}
+
+		// Каждое поле документа должно быть описано в маппинге
+		docJSON, _ := json.Marshal(productDocument{Description: "d", CategoryName: "c"})
+		var doc map[string]any
+		json.Unmarshal(docJSON, &doc)
+		for field := range doc {
+			if _, ok := mapping.Mappings.Properties[field]; !ok {
+				t.Errorf("field %q is missing in mapping", field)
+			}
+		}
+	})
+
+	t.Run("keeps existing index", func(t *testing.T) {
+		f := &fakeES{indexExists: true}
+		ix := newTestIndexer(t, f)
+
+		if err := ix.EnsureIndex(context.Background()); err != nil {
+			t.Fatalf("EnsureIndex: %v", err)
+		}
+		for _, r := range f.requests {
+			if r.method == http.MethodPut {
+				t.Fatal("index must not be recreated")
+			}
+		}
+	})
+}
+
+func TestApply(t *testing.T) {
+	ops := []operation{
+		{action: "index", id: "p1", version: 2, document: &productDocument{ProductID: "p1", Title: "Phone"}},
+		{action: "delete", id: "p2", version: 5},
+		{action: "index", id: "p3", document: &productDocument{ProductID: "p3", Title: "Legacy"}},
+	}
+
+	statuses := func(codes ...int) func([]map[string]map[string]any) []map[string]any {
+		return func(actions []map[string]map[string]any) []map[string]any {
+			var items []map[string]any
+			for i, a := range actions {
+				for action, meta := range a {
+					items = append(items, map[string]any{action: map[string]any{
+						"_id":    meta["_id"],
+						"status": codes[i],
+					}})
+				}
+			}
+			return items
+		}
+	}
+
+	tests := []struct {
+		name       string
+		bulkStatus int
+		items      func([]map[string]map[string]any) []map[string]any
+		wantRetry  bool
+	}{
+		{name: "all applied"},
+		{name: "version conflict is ignored", items: statuses(409, 200, 201)},
+		{name: "deleting missing document is ignored", items: statuses(200, 404, 200)},
+		{name: "mapping error is skipped", items: statuses(400, 200, 200)},
+		{name: "rejected by queue is retried", items: statuses(200, 429, 200), wantRetry: true},
+		{name: "shard failure is retried", items: statuses(503, 200, 200), wantRetry: true},
+		{name: "bulk endpoint unavailable", bulkStatus: http.StatusServiceUnavailable, wantRetry: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeES{bulkStatus: tt.bulkStatus, bulkItems: tt.items}
+			ix := newTestIndexer(t, f)
+
+			err := ix.Apply(context.Background(), ops)
+			if tt.wantRetry {
+				if !errors.Is(err, ErrRetryable) {
+					t.Fatalf("expected ErrRetryable, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			reqs := f.bulkRequests()
+			if len(reqs) != 1 {
+				t.Fatalf("bulk requests = %d, want 1", len(reqs))
+			}
+
+			actions := parseBulkActions(t, reqs[0].body)
+			if len(actions) != len(ops) {
+				t.Fatalf("actions = %d, want %d", len(actions), len(ops))
+			}
+
+			idx := actions[0]["index"]
+			if idx["_index"] != "products" || idx["_id"] != "p1" ||
+				idx["version"] != float64(2) || idx["version_type"] != "external" {
+				t.Errorf("unexpected index meta: %v", idx)
+			}
+
+			del := actions[1]["delete"]
+			if del["_id"] != "p2" || del["version"] != float64(5) {
+				t.Errorf("unexpected delete meta: %v", del)
+			}
+
+			if _, ok := actions[2]["index"]["version_type"]; ok {
+				t.Errorf("unversioned event must not use external versioning: %v", actions[2])
+			}
+		})
+	}
+}
+
+// fakeReader отдаёт заданные сообщения (с паузой delay перед каждым),
+// затем ждёт отмены контекста
+type fakeReader struct {
+	msgs      []kafka.Message
+	delay     time.Duration
+	committed []kafka.Message
+	// pendingAtCommit — сколько сообщений ещё не прочитано на момент коммита
+	pendingAtCommit []int
+	done            chan struct{}
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(r.msgs) > 0 {
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-time.After(r.delay):
+		}
+		msg := r.msgs[0]
+		r.msgs = r.msgs[1:]
+		return msg, nil
+	}
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.committed = append(r.committed, msgs...)
+	r.pendingAtCommit = append(r.pendingAtCommit, len(r.msgs))
+	if len(r.msgs) == 0 && r.done != nil {
+		close(r.done)
+		r.done = nil
+	}
+	return nil
+}
+
+func TestRunCommitsAfterIndexing(t *testing.T) {
+	f := &fakeES{}
+	ix := newTestIndexer(t, f)
+
+	event := func(payload events.Payload) []byte {
+		env, err := events.New("catalog", nil, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := json.Marshal(env)
+		return b
+	}
+	avroEnv, err := events.New("catalog", nil, events.ProductUpdated{Product: events.Product{ProductID: "p2", Title: "t", SellerName: "s", Version: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	avroEvent, err := events.Avro.Marshal(avroEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	reader := &fakeReader{
+		msgs: []kafka.Message{
+			{Offset: 1, Value: event(events.ProductCreated{Product: events.Product{ProductID: "p1", Title: "t", SellerName: "s", Version: 1}})},
+			{Offset: 2, Value: []byte("not json")},
+			// Событие старого формата, записанное до перехода на конверты
+			{Offset: 3, Value: []byte(`{"action":"product_deleted","product_id":"p1","version":2,"timestamp":"2025-06-01T10:00:00Z"}`)},
+			{Offset: 4, Value: avroEvent, Headers: []kafka.Header{{Key: events.ContentTypeHeader, Value: []byte(events.ContentTypeAvro)}}},
+		},
+		done: done,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := Config{BatchSize: 3, FlushInterval: 50 * time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- run(ctx, ix.log, reader, ix, cfg) }()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("messages were not committed")
+	}
+	cancel()
+
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Fatalf("run returned %v", err)
+	}
+
+	if len(reader.committed) != 4 {
+		t.Fatalf("committed = %d, want 4 (undecodable message must not block the partition)", len(reader.committed))
+	}
+
+	reqs := f.bulkRequests()
+	if len(reqs) != 2 {
+		t.Fatalf("bulk requests = %d, want 2 (size flush and timer flush)", len(reqs))
+	}
+	if actions := parseBulkActions(t, reqs[1].body); len(actions) != 1 || actions[0]["index"]["_id"] != "p2" {
+		t.Fatalf("avro event was not indexed: %v", actions)
+	}
+}
+
+// Сообщения приходят чаще FlushInterval: пачка всё равно уходит через
+// FlushInterval после первого сообщения, не дожидаясь паузы в потоке
+func TestRunFlushesTrickleOnInterval(t *testing.T) {
+	f := &fakeES{}
+	ix := newTestIndexer(t, f)
+
+	var msgs []kafka.Message
+	for i := range 20 {
+		msgs = append(msgs, kafka.Message{
+			Offset: int64(i + 1),
+			Value:  []byte(`{"action":"product_deleted","product_id":"p1","version":1,"timestamp":"2025-06-01T10:00:00Z"}`),
+		})
+	}
+	done := make(chan struct{})
+	reader := &fakeReader{msgs: msgs, delay: 20 * time.Millisecond, done: done}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := Config{BatchSize: 100, FlushInterval: 50 * time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- run(ctx, ix.log, reader, ix, cfg) }()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("messages were not committed")
+	}
+	cancel()
+	<-errCh
+
+	if len(reader.committed) != len(msgs) {
+		t.Fatalf("committed = %d, want %d", len(reader.committed), len(msgs))
+	}
+	if len(reader.pendingAtCommit) < 2 || reader.pendingAtCommit[0] == 0 {
+		t.Fatalf("pending messages at commits = %v, want a flush while messages keep coming", reader.pendingAtCommit)
+	}
+}
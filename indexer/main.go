@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"events"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/segmentio/kafka-go"
+)
+
+type Config struct {
+	Brokers       string        `env:"KAFKA_BOOTSTRAP_SERVERS" envDefault:"kafka:9092"`
+	Topic         string        `env:"KAFKA_TOPIC" envDefault:"product-events"`
+	GroupID       string        `env:"KAFKA_GROUP_ID" envDefault:"product-indexer-group"`
+	ESHost        string        `env:"ELASTICSEARCH_HOST" envDefault:"elasticsearch:9200"`
+	Index         string        `env:"ELASTICSEARCH_INDEX" envDefault:"products"`
+	BatchSize     int           `env:"BATCH_SIZE" envDefault:"500"`
+	FlushInterval time.Duration `env:"FLUSH_INTERVAL" envDefault:"1s"`
+	MaxBackoff    time.Duration `env:"MAX_BACKOFF" envDefault:"30s"`
+	// AvroSchemaDir — ревизии Avro-схем сверх встроенных в модуль events
+	AvroSchemaDir string `env:"AVRO_SCHEMA_DIR"`
+}
+
+func main() {
+	log := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	var cfg Config
+	if err := env.Parse(&cfg); err != nil {
+		log.Error("unable to parse environment variables", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	if cfg.AvroSchemaDir != "" {
+		reg, err := events.LoadAvroRegistry(os.DirFS(cfg.AvroSchemaDir))
+		if err != nil {
+			log.Error("failed to load avro schemas", slog.Any("err", err))
+			os.Exit(1)
+		}
+		formats = events.NewFormats(events.NewAvro(reg))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{
+		Addresses: []string{fmt.Sprintf("http://%s", cfg.ESHost)},
+	})
+	if err != nil {
+		log.Error("failed to create Elasticsearch client", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	ix := NewIndexer(log, es, cfg.Index)
+
+	if err := retry(ctx, log, cfg.MaxBackoff, func() error { return ix.EnsureIndex(ctx) }); err != nil {
+		log.Error("failed to prepare index", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     strings.Split(cfg.Brokers, ","),
+		GroupID:     cfg.GroupID,
+		Topic:       cfg.Topic,
+		StartOffset: kafka.FirstOffset,
+		MinBytes:    1,
+		MaxBytes:    10e6,
+		MaxWait:     time.Second,
+	})
+	defer reader.Close()
+
+	log.Info("product indexer started",
+		slog.String("topic", cfg.Topic),
+		slog.String("index", cfg.Index),
+	)
+
+	if err := run(ctx, log, reader, ix, cfg); err != nil && !errors.Is(err, context.Canceled) {
+		log.Error("indexer stopped with error", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	log.Info("product indexer stopped")
+}
+
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// run читает события пачками: пачка отправляется в ES по размеру или по
+// таймеру, офсеты коммитятся только после успешного применения
+func run(ctx context.Context, log *slog.Logger, reader messageReader, ix *Indexer, cfg Config) error {
+	var (
+		msgs []kafka.Message
+		ops  []operation
+		// started — когда в пачку попало первое сообщение: таймер считается
+		// от него, а не от последнего чтения
+		started time.Time
+	)
+
+	flush := func() error {
+		if len(msgs) == 0 {
+			return nil
+		}
+
+		err := retry(ctx, log, cfg.MaxBackoff, func() error { return ix.Apply(ctx, ops) })
+		if err != nil {
+			return err
+		}
+
+		if err := reader.CommitMessages(ctx, msgs...); err != nil {
+			return fmt.Errorf("committing offsets: %w", err)
+		}
+
+		log.Info("batch indexed", slog.Int("messages", len(msgs)), slog.Int("operations", len(ops)))
+
+		msgs, ops = msgs[:0], ops[:0]
+		return nil
+	}
+
+	for {
+		wait := cfg.FlushInterval
+		if len(msgs) > 0 {
+			wait = time.Until(started.Add(cfg.FlushInterval))
+		}
+
+		fetchCtx, cancel := context.WithTimeout(ctx, wait)
+		msg, err := reader.FetchMessage(fetchCtx)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				if err := flush(); err != nil {
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("fetching message: %w", err)
+		}
+
+		if len(msgs) == 0 {
+			started = time.Now()
+		}
+		msgs = append(msgs, msg)
+
+		event, err := decodeEvent(contentType(msg), msg.Value)
+		if err != nil {
+			log.Error("failed to decode event",
+				slog.Int("partition", msg.Partition),
+				slog.Int64("offset", msg.Offset),
+				slog.Any("err", err),
+			)
+		} else if op, err := toOperation(event); err != nil {
+			log.Warn("event skipped", slog.Int64("offset", msg.Offset), slog.Any("err", err))
+		} else {
+			ops = append(ops, op)
+		}
+
+		if len(msgs) >= cfg.BatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// contentType — формат события из заголовка сообщения
+func contentType(msg kafka.Message) string {
+	for _, h := range msg.Headers {
+		if h.Key == events.ContentTypeHeader {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// retry повторяет fn с экспоненциальной задержкой, пока она возвращает
+// временную ошибку или пока не отменён контекст
+func retry(ctx context.Context, log *slog.Logger, maxBackoff time.Duration, fn func() error) error {
+	delay := 500 * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		log.Warn("operation failed, retrying",
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+			slog.Any("err", err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxBackoff {
+			delay = maxBackoff
+		}
+	}
+}